@@ -0,0 +1,163 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"smart-choice/models"
+	"smart-choice/services"
+	"smart-choice/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+type AddressRequest struct {
+	Label             string `json:"label"`
+	RecipientName     string `json:"recipient_name"`
+	CEP               string `json:"cep" binding:"required"`
+	Logradouro        string `json:"logradouro" binding:"required"`
+	Numero            string `json:"numero" binding:"required"`
+	Complemento       string `json:"complemento"`
+	Bairro            string `json:"bairro" binding:"required"`
+	Cidade            string `json:"cidade" binding:"required"`
+	UF                string `json:"uf" binding:"required"`
+	IsDefaultShipping bool   `json:"is_default_shipping"`
+	IsDefaultBilling  bool   `json:"is_default_billing"`
+}
+
+func (r AddressRequest) toModel() models.Address {
+	return models.Address{
+		Label:             r.Label,
+		RecipientName:     r.RecipientName,
+		CEP:               r.CEP,
+		Logradouro:        r.Logradouro,
+		Numero:            r.Numero,
+		Complemento:       r.Complemento,
+		Bairro:            r.Bairro,
+		Cidade:            r.Cidade,
+		UF:                r.UF,
+		IsDefaultShipping: r.IsDefaultShipping,
+		IsDefaultBilling:  r.IsDefaultBilling,
+	}
+}
+
+func bindAddressRequest(c *gin.Context) (*AddressRequest, bool) {
+	var req AddressRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+
+	if errs := utils.ValidateAddress(req.CEP, req.Logradouro, req.Numero, req.Bairro, req.Cidade, req.UF); len(errs) > 0 {
+		utils.HandleValidationError(c, errs)
+		return nil, false
+	}
+
+	return &req, true
+}
+
+func ListAddresses(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	addresses, err := services.ListAddresses(user.ID)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to list addresses")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list addresses"})
+		return
+	}
+
+	c.JSON(http.StatusOK, addresses)
+}
+
+func GetAddress(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	id, ok := parseIDParam(c, "id", "Invalid address ID")
+	if !ok {
+		return
+	}
+
+	address, err := services.GetAddress(user.ID, id)
+	if err != nil {
+		respondAddressError(c, err, "Failed to get address")
+		return
+	}
+
+	c.JSON(http.StatusOK, address)
+}
+
+func CreateAddress(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	req, ok := bindAddressRequest(c)
+	if !ok {
+		return
+	}
+
+	address := req.toModel()
+	if err := services.CreateAddress(user.ID, &address); err != nil {
+		log.Error().Err(err).Msg("Failed to create address")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create address"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, address)
+}
+
+func UpdateAddress(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	id, ok := parseIDParam(c, "id", "Invalid address ID")
+	if !ok {
+		return
+	}
+	req, ok := bindAddressRequest(c)
+	if !ok {
+		return
+	}
+
+	address, err := services.UpdateAddress(user.ID, id, req.toModel())
+	if err != nil {
+		respondAddressError(c, err, "Failed to update address")
+		return
+	}
+
+	c.JSON(http.StatusOK, address)
+}
+
+func DeleteAddress(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	id, ok := parseIDParam(c, "id", "Invalid address ID")
+	if !ok {
+		return
+	}
+
+	if err := services.DeleteAddress(user.ID, id); err != nil {
+		respondAddressError(c, err, "Failed to delete address")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Address deleted successfully"})
+}
+
+func respondAddressError(c *gin.Context, err error, message string) {
+	if errors.Is(err, services.ErrAddressNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Address not found"})
+		return
+	}
+	log.Error().Err(err).Msg(message)
+	c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+}
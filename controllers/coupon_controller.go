@@ -1,30 +1,269 @@
 package controllers
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
 	"net/http"
+	"net/url"
+	"strings"
+	"time"
 
+	"smart-choice/models"
 	"smart-choice/services"
+	"smart-choice/utils"
 
 	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
 )
 
 type ValidateCouponInput struct {
-	Code   string  `json:"code" binding:"required"`
-	Amount float64 `json:"amount" binding:"required"`
+	Code   string                      `json:"code" binding:"required"`
+	Amount float64                     `json:"amount" binding:"required_without=Items"`
+	Items  []services.OrderItemRequest `json:"items" binding:"dive"`
 }
 
 func ValidateCoupon(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
 	var input ValidateCouponInput
 	if err := c.ShouldBindJSON(&input); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	coupon, err := services.ValidateCoupon(input.Code, input.Amount)
+	quote, err := services.ValidateCoupon(user.ID, input.Code, input.Amount, input.Items)
+	if err != nil {
+		if respondCouponRejection(c, err) {
+			return
+		}
+		if errors.Is(err, services.ErrProductNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		log.Error().Err(err).Msg("Failed to validate coupon")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to validate coupon"})
+		return
+	}
+
+	c.JSON(http.StatusOK, quote)
+}
+
+// respondCouponRejection writes the response for a coupon rule failure,
+// including its error code. It reports whether err was one.
+func respondCouponRejection(c *gin.Context, err error) bool {
+	var couponErr *services.CouponError
+	if !errors.As(err, &couponErr) {
+		return false
+	}
+
+	status := http.StatusBadRequest
+	if couponErr == services.ErrCouponExhausted {
+		status = http.StatusConflict
+	}
+	c.JSON(status, gin.H{"error": couponErr.Message, "code": couponErr.Code})
+	return true
+}
+
+type CouponRequest struct {
+	Code           string     `json:"code" binding:"required"`
+	DiscountType   string     `json:"discount_type"`
+	Discount       float64    `json:"discount"`
+	MaxDiscount    float64    `json:"max_discount"`
+	StartsAt       *time.Time `json:"starts_at"`
+	ValidUntil     time.Time  `json:"valid_until" binding:"required"`
+	MaxUses        uint       `json:"max_uses" binding:"required"`
+	MaxUsesPerUser uint       `json:"max_uses_per_user"`
+	MinAmount      float64    `json:"min_amount"`
+	FirstOrderOnly bool       `json:"first_order_only"`
+	Campaign       string     `json:"campaign"`
+
+	IncludedProducts   []uint   `json:"included_products"`
+	ExcludedProducts   []uint   `json:"excluded_products"`
+	IncludedCategories []string `json:"included_categories"`
+	ExcludedCategories []string `json:"excluded_categories"`
+}
+
+func (r CouponRequest) toModel() models.Coupon {
+	discountType := r.DiscountType
+	if discountType == "" {
+		discountType = models.CouponTypePercentage
+	}
+
+	return models.Coupon{
+		Code:               services.NormalizeCouponCode(r.Code),
+		DiscountType:       discountType,
+		Discount:           r.Discount,
+		MaxDiscount:        r.MaxDiscount,
+		StartsAt:           r.StartsAt,
+		ValidUntil:         r.ValidUntil,
+		MaxUses:            r.MaxUses,
+		MaxUsesPerUser:     r.MaxUsesPerUser,
+		MinAmount:          r.MinAmount,
+		FirstOrderOnly:     r.FirstOrderOnly,
+		Campaign:           strings.TrimSpace(r.Campaign),
+		IncludedProducts:   r.IncludedProducts,
+		ExcludedProducts:   r.ExcludedProducts,
+		IncludedCategories: r.IncludedCategories,
+		ExcludedCategories: r.ExcludedCategories,
+	}
+}
+
+func bindCouponRequest(c *gin.Context) (*models.Coupon, bool) {
+	var req CouponRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+
+	coupon := req.toModel()
+	errs := utils.ValidateCoupon(coupon.Code, coupon.DiscountType, coupon.Discount, coupon.ValidUntil, coupon.MaxUses, coupon.MinAmount)
+	errs = append(errs, utils.ValidateCouponLimits(coupon.MaxDiscount, coupon.StartsAt, coupon.ValidUntil)...)
+	if len(errs) > 0 {
+		utils.HandleValidationError(c, errs)
+		return nil, false
+	}
+
+	return &coupon, true
+}
+
+func ListCoupons(c *gin.Context) {
+	pagination := utils.GeneratePaginationFromRequest(c)
+
+	coupons, err := services.ListCoupons(&pagination, c.Query("campaign"), c.Query("active"), c.Query("code"))
+	if err != nil {
+		respondCouponError(c, err, "Failed to list coupons")
+		return
+	}
+
+	pagination.Rows = coupons
+	c.JSON(http.StatusOK, pagination)
+}
+
+func GetCoupon(c *gin.Context) {
+	id, ok := parseIDParam(c, "id", "Invalid coupon ID")
+	if !ok {
+		return
+	}
+
+	coupon, err := services.GetCoupon(id)
+	if err != nil {
+		respondCouponError(c, err, "Failed to get coupon")
+		return
+	}
+
+	c.JSON(http.StatusOK, coupon)
+}
+
+func CreateCoupon(c *gin.Context) {
+	coupon, ok := bindCouponRequest(c)
+	if !ok {
+		return
+	}
+
+	if err := services.CreateCoupon(coupon); err != nil {
+		respondCouponError(c, err, "Failed to create coupon")
+		return
+	}
+
+	c.JSON(http.StatusCreated, coupon)
+}
+
+func UpdateCoupon(c *gin.Context) {
+	id, ok := parseIDParam(c, "id", "Invalid coupon ID")
+	if !ok {
+		return
+	}
+	changes, ok := bindCouponRequest(c)
+	if !ok {
+		return
+	}
+
+	coupon, err := services.UpdateCoupon(id, *changes)
+	if err != nil {
+		respondCouponError(c, err, "Failed to update coupon")
+		return
+	}
+
+	c.JSON(http.StatusOK, coupon)
+}
+
+func DeactivateCoupon(c *gin.Context) {
+	id, ok := parseIDParam(c, "id", "Invalid coupon ID")
+	if !ok {
+		return
+	}
+
+	coupon, err := services.SetCouponActive(id, false)
+	if err != nil {
+		respondCouponError(c, err, "Failed to deactivate coupon")
+		return
+	}
+
+	c.JSON(http.StatusOK, coupon)
+}
+
+func GetCouponUsage(c *gin.Context) {
+	id, ok := parseIDParam(c, "id", "Invalid coupon ID")
+	if !ok {
+		return
+	}
+
+	usage, err := services.GetCouponUsage(id)
 	if err != nil {
+		respondCouponError(c, err, "Failed to get coupon usage")
+		return
+	}
+
+	c.JSON(http.StatusOK, usage)
+}
+
+func GenerateCoupons(c *gin.Context) {
+	var req services.CouponBatchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"coupon": coupon})
+	coupons, err := services.GenerateCouponBatch(req)
+	if err != nil {
+		respondCouponError(c, err, "Failed to generate coupons")
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"campaign": req.Campaign, "count": len(coupons), "coupons": coupons})
+}
+
+func ExportCoupons(c *gin.Context) {
+	campaign := c.Query("campaign")
+
+	var buf bytes.Buffer
+	if err := services.WriteCouponsCSV(&buf, campaign); err != nil {
+		respondCouponError(c, err, "Failed to export coupons")
+		return
+	}
+
+	filename := "cupons.csv"
+	if campaign != "" {
+		filename = fmt.Sprintf("cupons-%s.csv", url.PathEscape(campaign))
+	}
+	c.Header("Content-Disposition", "attachment; filename="+filename)
+	c.Data(http.StatusOK, "text/csv; charset=utf-8", buf.Bytes())
+}
+
+func respondCouponError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, services.ErrCouponNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrCouponCodeTaken):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidCouponBatch):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		log.Error().Err(err).Msg(message)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
 }
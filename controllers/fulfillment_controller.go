@@ -0,0 +1,56 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"smart-choice/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+func ShipOrder(c *gin.Context) {
+	admin, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	id, ok := parseIDParam(c, "id", "Invalid order ID")
+	if !ok {
+		return
+	}
+
+	order, err := services.ShipOrder(admin, id)
+	if err != nil {
+		respondFulfillmentError(c, err, "Failed to ship order")
+		return
+	}
+
+	c.JSON(http.StatusOK, order)
+}
+
+func DeliverOrder(c *gin.Context) {
+	admin, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	id, ok := parseIDParam(c, "id", "Invalid order ID")
+	if !ok {
+		return
+	}
+
+	order, err := services.DeliverOrder(admin, id)
+	if err != nil {
+		respondFulfillmentError(c, err, "Failed to deliver order")
+		return
+	}
+
+	c.JSON(http.StatusOK, order)
+}
+
+func respondFulfillmentError(c *gin.Context, err error, message string) {
+	if errors.Is(err, services.ErrInvalidOrderTransition) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	respondOrderError(c, err, message)
+}
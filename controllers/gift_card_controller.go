@@ -0,0 +1,145 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"smart-choice/services"
+	"smart-choice/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+func IssueGiftCard(c *gin.Context) {
+	admin, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	var req services.GiftCardRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	card, err := services.IssueGiftCard(admin, req)
+	if err != nil {
+		respondBalanceError(c, err, "Failed to issue gift card")
+		return
+	}
+
+	c.JSON(http.StatusCreated, card)
+}
+
+func ListGiftCards(c *gin.Context) {
+	pagination := utils.GeneratePaginationFromRequest(c)
+
+	cards, err := services.ListGiftCards(&pagination, c.Query("active"))
+	if err != nil {
+		respondBalanceError(c, err, "Failed to list gift cards")
+		return
+	}
+
+	pagination.Rows = cards
+	c.JSON(http.StatusOK, pagination)
+}
+
+func DeactivateGiftCard(c *gin.Context) {
+	id, ok := parseIDParam(c, "id", "Invalid gift card ID")
+	if !ok {
+		return
+	}
+
+	card, err := services.DeactivateGiftCard(id)
+	if err != nil {
+		respondBalanceError(c, err, "Failed to deactivate gift card")
+		return
+	}
+
+	c.JSON(http.StatusOK, card)
+}
+
+func GetGiftCardBalance(c *gin.Context) {
+	balance, err := services.GetGiftCardBalance(c.Param("code"))
+	if err != nil {
+		respondBalanceError(c, err, "Failed to get gift card balance")
+		return
+	}
+
+	c.JSON(http.StatusOK, balance)
+}
+
+func GetStoreCredit(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	statement, err := services.GetStoreCreditStatement(user.ID)
+	if err != nil {
+		respondBalanceError(c, err, "Failed to get store credit")
+		return
+	}
+
+	c.JSON(http.StatusOK, statement)
+}
+
+func GetUserStoreCredit(c *gin.Context) {
+	id, ok := parseIDParam(c, "id", "Invalid user ID")
+	if !ok {
+		return
+	}
+
+	statement, err := services.GetStoreCreditStatement(id)
+	if err != nil {
+		respondBalanceError(c, err, "Failed to get store credit")
+		return
+	}
+
+	c.JSON(http.StatusOK, statement)
+}
+
+func AdjustStoreCredit(c *gin.Context) {
+	admin, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	id, ok := parseIDParam(c, "id", "Invalid user ID")
+	if !ok {
+		return
+	}
+
+	var req services.StoreCreditAdjustment
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	entry, err := services.AdjustStoreCredit(admin, id, req)
+	if err != nil {
+		respondBalanceError(c, err, "Failed to adjust store credit")
+		return
+	}
+
+	c.JSON(http.StatusCreated, entry)
+}
+
+func respondBalanceError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, services.ErrGiftCardNotFound),
+		errors.Is(err, services.ErrUserNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrGiftCardCodeTaken),
+		errors.Is(err, services.ErrInsufficientBalance):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidGiftCardBalance),
+		errors.Is(err, services.ErrInvalidGiftCardExpiry),
+		errors.Is(err, services.ErrStoreCreditAmountRequired),
+		errors.Is(err, services.ErrStoreCreditReasonRequired):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		log.Error().Err(err).Msg(message)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
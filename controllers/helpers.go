@@ -0,0 +1,28 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+
+	"smart-choice/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+func currentUser(c *gin.Context) (*models.User, bool) {
+	userCtx, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found in context"})
+		return nil, false
+	}
+	return userCtx.(*models.User), true
+}
+
+func parseIDParam(c *gin.Context, name, message string) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param(name), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": message})
+		return 0, false
+	}
+	return uint(id), true
+}
@@ -0,0 +1,45 @@
+package controllers
+
+import (
+	"net/http"
+
+	"smart-choice/repository"
+	"smart-choice/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+func GetProductInstallments(c *gin.Context) {
+	id, ok := parseIDParam(c, "id", "Invalid product ID")
+	if !ok {
+		return
+	}
+
+	product, err := repository.GetProductByID(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"product_id":   product.ID,
+		"price":        product.Price,
+		"installments": services.CalculateInstallments(product.Price, services.DefaultInstallmentRules()),
+	})
+}
+
+func PreviewCart(c *gin.Context) {
+	var req services.CartPreviewRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	preview, err := services.PreviewCart(req)
+	if err != nil {
+		respondOrderError(c, err, "Failed to preview cart")
+		return
+	}
+
+	c.JSON(http.StatusOK, preview)
+}
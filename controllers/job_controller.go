@@ -0,0 +1,109 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"smart-choice/services"
+	"smart-choice/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+func ListJobs(c *gin.Context) {
+	pagination := utils.GeneratePaginationFromRequest(c)
+
+	state := c.DefaultQuery("state", services.JobStateQueued)
+	jobs, err := services.ListJobs(c.Request.Context(), state, &pagination)
+	if err != nil {
+		respondJobError(c, err, "Failed to list jobs")
+		return
+	}
+
+	pagination.Rows = jobs
+	c.JSON(http.StatusOK, pagination)
+}
+
+func GetJobStats(c *gin.Context) {
+	stats, err := services.GetJobStats(c.Request.Context())
+	if err != nil {
+		respondJobError(c, err, "Failed to get job stats")
+		return
+	}
+
+	c.JSON(http.StatusOK, stats)
+}
+
+func RetryDeadJob(c *gin.Context) {
+	if err := services.RetryDeadJob(c.Request.Context(), c.Param("id")); err != nil {
+		respondJobError(c, err, "Failed to retry job")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Job queued for retry"})
+}
+
+func DiscardDeadJob(c *gin.Context) {
+	if err := services.DiscardDeadJob(c.Request.Context(), c.Param("id")); err != nil {
+		respondJobError(c, err, "Failed to discard job")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Job discarded"})
+}
+
+func RetryDeadJobs(c *gin.Context) {
+	count, err := services.RetryDeadJobs(c.Request.Context(), services.JobType(c.Query("type")))
+	if err != nil {
+		respondJobError(c, err, "Failed to retry jobs")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"retried": count})
+}
+
+func DiscardDeadJobs(c *gin.Context) {
+	count, err := services.DiscardDeadJobs(c.Request.Context(), services.JobType(c.Query("type")))
+	if err != nil {
+		respondJobError(c, err, "Failed to discard jobs")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"discarded": count})
+}
+
+func PauseJobType(c *gin.Context) {
+	jobType := services.JobType(c.Param("type"))
+	if err := services.PauseJobType(c.Request.Context(), jobType); err != nil {
+		respondJobError(c, err, "Failed to pause job type")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"type": jobType, "paused": true})
+}
+
+func ResumeJobType(c *gin.Context) {
+	jobType := services.JobType(c.Param("type"))
+	if err := services.ResumeJobType(c.Request.Context(), jobType); err != nil {
+		respondJobError(c, err, "Failed to resume job type")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"type": jobType, "paused": false})
+}
+
+func respondJobError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, services.ErrJobNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidJobState),
+		errors.Is(err, services.ErrUnknownJobType):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrJobQueueUnavailable):
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+	default:
+		log.Error().Err(err).Msg(message)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
@@ -0,0 +1,105 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"smart-choice/models"
+	"smart-choice/services"
+	"smart-choice/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+type LoyaltyRuleRequest struct {
+	Name         string     `json:"name" binding:"required"`
+	Type         string     `json:"type" binding:"required"`
+	Category     string     `json:"category"`
+	BonusPerReal float64    `json:"bonus_per_real"`
+	Multiplier   float64    `json:"multiplier"`
+	StartsAt     *time.Time `json:"starts_at"`
+	EndsAt       *time.Time `json:"ends_at"`
+	Active       *bool      `json:"active"`
+}
+
+func GetLoyalty(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	statement, err := services.GetLoyaltyStatement(user.ID)
+	if err != nil {
+		respondLoyaltyError(c, err, "Failed to get loyalty points")
+		return
+	}
+
+	c.JSON(http.StatusOK, statement)
+}
+
+func ListLoyaltyRules(c *gin.Context) {
+	rules, err := services.ListLoyaltyRules()
+	if err != nil {
+		respondLoyaltyError(c, err, "Failed to list loyalty rules")
+		return
+	}
+
+	c.JSON(http.StatusOK, rules)
+}
+
+func CreateLoyaltyRule(c *gin.Context) {
+	var req LoyaltyRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if errs := utils.ValidateLoyaltyRule(req.Name, req.Type, req.Category, req.BonusPerReal, req.Multiplier, req.StartsAt, req.EndsAt); len(errs) > 0 {
+		utils.HandleValidationError(c, errs)
+		return
+	}
+
+	rule := models.LoyaltyRule{
+		Name:         strings.TrimSpace(req.Name),
+		Type:         req.Type,
+		Category:     strings.TrimSpace(req.Category),
+		BonusPerReal: req.BonusPerReal,
+		Multiplier:   req.Multiplier,
+		StartsAt:     req.StartsAt,
+		EndsAt:       req.EndsAt,
+		Active:       req.Active == nil || *req.Active,
+	}
+	if err := services.CreateLoyaltyRule(&rule); err != nil {
+		respondLoyaltyError(c, err, "Failed to create loyalty rule")
+		return
+	}
+
+	c.JSON(http.StatusCreated, rule)
+}
+
+func DeleteLoyaltyRule(c *gin.Context) {
+	id, ok := parseIDParam(c, "id", "Invalid loyalty rule ID")
+	if !ok {
+		return
+	}
+
+	if err := services.DeleteLoyaltyRule(id); err != nil {
+		respondLoyaltyError(c, err, "Failed to delete loyalty rule")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Loyalty rule deleted successfully"})
+}
+
+func respondLoyaltyError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, services.ErrLoyaltyRuleNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	default:
+		log.Error().Err(err).Msg(message)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
@@ -0,0 +1,94 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"smart-choice/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+func CreateOrder(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	var req services.PlaceOrderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	order, err := services.PlaceOrder(user, req)
+	if err != nil {
+		respondOrderError(c, err, "Failed to place order")
+		return
+	}
+
+	c.JSON(http.StatusCreated, order)
+}
+
+func ListOrders(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	orders, err := services.ListOrders(user.ID)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to list orders")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list orders"})
+		return
+	}
+
+	c.JSON(http.StatusOK, orders)
+}
+
+func GetOrder(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	id, ok := parseIDParam(c, "id", "Invalid order ID")
+	if !ok {
+		return
+	}
+
+	order, err := services.GetOrder(user.ID, id)
+	if err != nil {
+		respondOrderError(c, err, "Failed to get order")
+		return
+	}
+
+	c.JSON(http.StatusOK, order)
+}
+
+func respondOrderError(c *gin.Context, err error, message string) {
+	if respondCouponRejection(c, err) {
+		return
+	}
+
+	switch {
+	case errors.Is(err, services.ErrOrderNotFound),
+		errors.Is(err, services.ErrProductNotFound),
+		errors.Is(err, services.ErrAddressNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInsufficientStock):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrEmptyOrder),
+		errors.Is(err, services.ErrInvalidItemQuantity),
+		errors.Is(err, services.ErrDuplicateOrderItem),
+		errors.Is(err, services.ErrShippingAddressRequired),
+		errors.Is(err, services.ErrTaxDocumentRequired),
+		errors.Is(err, services.ErrInstallmentsUnavailable),
+		errors.Is(err, services.ErrInvalidLoyaltyPoints),
+		errors.Is(err, services.ErrLoyaltyPointsUnavailable):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		log.Error().Err(err).Msg(message)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
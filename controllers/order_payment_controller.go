@@ -0,0 +1,82 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"smart-choice/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+func PayOrder(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	id, ok := parseIDParam(c, "id", "Invalid order ID")
+	if !ok {
+		return
+	}
+
+	var input services.OrderPaymentRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	payment, err := services.StartOrderPayment(c.Request.Context(), user, id, input)
+	if err != nil {
+		respondPaymentError(c, err, "Failed to start payment")
+		return
+	}
+
+	c.JSON(http.StatusCreated, payment)
+}
+
+func ListOrderPayments(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	id, ok := parseIDParam(c, "id", "Invalid order ID")
+	if !ok {
+		return
+	}
+
+	payments, err := services.ListOrderPayments(c.Request.Context(), user.ID, id)
+	if err != nil {
+		respondPaymentError(c, err, "Failed to list payments")
+		return
+	}
+
+	c.JSON(http.StatusOK, payments)
+}
+
+func respondPaymentError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, services.ErrOrderNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrGiftCardNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrOrderNotPayable),
+		errors.Is(err, services.ErrPaymentInProgress),
+		errors.Is(err, services.ErrInsufficientBalance):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrGiftCardUnusable),
+		errors.Is(err, services.ErrGiftCardEmpty),
+		errors.Is(err, services.ErrStoreCreditEmpty),
+		errors.Is(err, services.ErrInstallmentsUnavailable),
+		errors.Is(err, services.ErrUnsupportedPaymentMethod):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrUnknownPaymentProvider):
+		log.Error().Err(err).Msg("Payment provider misconfigured")
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Payments are temporarily unavailable"})
+	default:
+		log.Error().Err(err).Msg(message)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
@@ -1,97 +1,102 @@
 package controllers
 
 import (
-	"crypto/hmac"
-	"crypto/sha256"
-	"encoding/hex"
+	"errors"
+	"io"
 	"net/http"
 	"strconv"
 	"time"
 
-	"smart-choice/database"
-	"smart-choice/models"
+	"smart-choice/services"
 
 	"github.com/gin-gonic/gin"
 	"github.com/rs/zerolog/log"
-	"gorm.io/gorm"
 )
 
-type PaymentWebhookPayload struct {
-	OrderID   uint    `json:"order_id"`
-	Status    string  `json:"status"`
-	Amount    float64 `json:"amount"`
-	Signature string  `json:"signature"`
-	Timestamp int64   `json:"timestamp"`
+func PaymentWebhook(c *gin.Context) {
+	handleSignedWebhook(c, "payment")
 }
 
-func PaymentWebhook(c *gin.Context) {
-	var payload PaymentWebhookPayload
+func PixWebhook(c *gin.Context) {
+	handleSignedWebhook(c, "pix")
+}
 
-	if err := c.ShouldBindJSON(&payload); err != nil {
-		log.Error().Err(err).Msg("Invalid webhook payload structure")
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid payload"})
-		return
-	}
+func BoletoWebhook(c *gin.Context) {
+	handleSignedWebhook(c, "boleto")
+}
 
-	webhookSecret := c.GetHeader("X-Webhook-Secret")
-	if webhookSecret == "" {
-		log.Warn().Msg("Missing webhook secret header")
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Missing webhook secret"})
+// handleSignedWebhook authenticates the raw body with the verifier registered
+// for the route and hands the delivery to the webhook event store. Schemes
+// without a signed timestamp take the delivery time from X-Webhook-Timestamp
+// (Unix seconds); replays with a fresh timestamp are caught by the event
+// store, which identifies events by their signed payload.
+func handleSignedWebhook(c *gin.Context, provider string) {
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid payload"})
 		return
 	}
 
-	expectedSignature := generateSignature(payload, webhookSecret)
-	if !hmac.Equal([]byte(payload.Signature), []byte(expectedSignature)) {
-		log.Warn().Msg("Invalid webhook signature - potential attack")
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid signature"})
+	timestamp, err := services.VerifyWebhook(provider, body, c.Request.Header)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrMissingSignature):
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Missing webhook signature"})
+		case errors.Is(err, services.ErrInvalidSignature), errors.Is(err, services.ErrMalformedSignatureHeader):
+			log.Warn().Str("webhook", provider).Msg("Invalid webhook signature - potential attack")
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid signature"})
+		default:
+			log.Error().Err(err).Str("webhook", provider).Msg("Webhook verification misconfigured")
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Webhook verification unavailable"})
+		}
 		return
 	}
 
-	// Log only non-sensitive information
-	log.Info().
-		Uint64("order_id", uint64(payload.OrderID)).
-		Str("status", payload.Status).
-		Msg("Payment webhook received")
-
-	err := database.DB.Transaction(func(tx *gorm.DB) error {
-		var order models.Order
-		if err := tx.First(&order, payload.OrderID).Error; err != nil {
-			return err
-		}
-
-		if order.Status != payload.Status {
-			order.Status = payload.Status
-			if err := tx.Save(&order).Error; err != nil {
-				return err
-			}
-
-			activityLog := models.ActivityLog{
-				UserID:    order.UserID,
-				Action:    "Payment status updated to " + payload.Status,
-				Timestamp: time.Now(),
-			}
-			if err := tx.Create(&activityLog).Error; err != nil {
-				return err
-			}
+	if timestamp.IsZero() {
+		unix, err := strconv.ParseInt(c.GetHeader("X-Webhook-Timestamp"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Missing or invalid webhook timestamp"})
+			return
 		}
+		timestamp = time.Unix(unix, 0)
+	}
 
-		return nil
+	receiveWebhook(c, services.WebhookEnvelope{
+		Provider:  provider,
+		Timestamp: timestamp,
+		Payload:   string(body),
 	})
+}
 
+func receiveWebhook(c *gin.Context, envelope services.WebhookEnvelope) {
+	event, duplicate, err := services.ReceiveWebhookEvent(envelope)
 	if err != nil {
-		log.Error().Err(err).Uint64("order_id", uint64(payload.OrderID)).Msg("Failed to process webhook")
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process webhook"})
+		respondWebhookError(c, envelope.Provider, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Webhook processed successfully"})
-}
-
-func generateSignature(payload PaymentWebhookPayload, secret string) string {
-	h := hmac.New(sha256.New, []byte(secret))
+	if duplicate {
+		c.JSON(http.StatusOK, gin.H{"message": "Event already processed", "event_id": event.EventID})
+		return
+	}
 
-	data := strconv.FormatUint(uint64(payload.OrderID), 10) + payload.Status + strconv.FormatFloat(payload.Amount, 'f', 2, 64) + strconv.FormatInt(payload.Timestamp, 10)
-	h.Write([]byte(data))
+	c.JSON(http.StatusOK, gin.H{"message": "Webhook processed successfully", "event_id": event.EventID})
+}
 
-	return hex.EncodeToString(h.Sum(nil))
+func respondWebhookError(c *gin.Context, provider string, err error) {
+	switch {
+	case errors.Is(err, services.ErrWebhookTimestampOutOfRange):
+		log.Warn().Str("webhook", provider).Msg("Webhook timestamp outside tolerance - possible replay")
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrPaymentNotFound), errors.Is(err, services.ErrOrderNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Charge not found"})
+	case errors.Is(err, services.ErrUnsupportedPaymentStatus):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidOrderTransition):
+		log.Warn().Err(err).Str("webhook", provider).Msg("Payment confirmation rejected")
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		log.Error().Err(err).Str("webhook", provider).Msg("Failed to process webhook")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process webhook"})
+	}
 }
@@ -0,0 +1,63 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"smart-choice/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+type ResolvePaymentReviewInput struct {
+	Action string `json:"action" binding:"required"`
+	Note   string `json:"note"`
+}
+
+func ListPaymentReviews(c *gin.Context) {
+	reviews, err := services.ListPaymentReviews(c.Query("include_resolved") == "true")
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to list payment reviews")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list payment reviews"})
+		return
+	}
+
+	c.JSON(http.StatusOK, reviews)
+}
+
+func ResolvePaymentReview(c *gin.Context) {
+	admin, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	id, ok := parseIDParam(c, "id", "Invalid review ID")
+	if !ok {
+		return
+	}
+
+	var input ResolvePaymentReviewInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	review, err := services.ResolvePaymentReview(admin, id, input.Action, input.Note)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrPaymentReviewNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrInvalidPaymentReviewAction):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrPaymentReviewResolved),
+			errors.Is(err, services.ErrOrderNotInPaymentReview):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			log.Error().Err(err).Msg("Failed to resolve payment review")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to resolve payment review"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, review)
+}
@@ -0,0 +1,111 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"smart-choice/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+func RequestDataExport(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	request, err := services.RequestDataExport(c.Request.Context(), user)
+	if err != nil {
+		if errors.Is(err, services.ErrJobQueueUnavailable) {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Data export is temporarily unavailable"})
+			return
+		}
+		log.Error().Err(err).Msg("Failed to request data export")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to request data export"})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, request)
+}
+
+func GetDataExport(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	id, ok := parseIDParam(c, "id", "Invalid request ID")
+	if !ok {
+		return
+	}
+
+	request, err := services.GetPrivacyRequest(user.ID, id)
+	if err != nil {
+		respondPrivacyError(c, err, "Failed to get data export")
+		return
+	}
+
+	c.JSON(http.StatusOK, request)
+}
+
+func DownloadDataExport(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	id, ok := parseIDParam(c, "id", "Invalid request ID")
+	if !ok {
+		return
+	}
+
+	path, err := services.GetDataExportFile(user.ID, id)
+	if err != nil {
+		respondPrivacyError(c, err, "Failed to download data export")
+		return
+	}
+
+	c.FileAttachment(path, fmt.Sprintf("smart-choice-dados-%d.zip", id))
+}
+
+type EraseAccountInput struct {
+	Password string `json:"password" binding:"required"`
+}
+
+func EraseAccount(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	var input EraseAccountInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := services.EraseAccount(user, input.Password); err != nil {
+		if errors.Is(err, services.ErrInvalidPassword) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+			return
+		}
+		log.Error().Err(err).Msg("Failed to erase account")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to erase account"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Account data erased successfully"})
+}
+
+func respondPrivacyError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, services.ErrPrivacyRequestNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Request not found"})
+	case errors.Is(err, services.ErrExportNotReady):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		log.Error().Err(err).Msg(message)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
@@ -3,6 +3,7 @@ package controllers
 import (
 	"net/http"
 	"strconv"
+	"strings"
 
 	"smart-choice/database"
 	"smart-choice/models"
@@ -19,6 +20,7 @@ type CreateProductRequest struct {
 	Price       float64 `json:"price" binding:"required,gt=0"`
 	Stock       uint    `json:"stock" binding:"required"`
 	StockLimit  uint    `json:"stock_limit"`
+	Category    string  `json:"category"`
 }
 
 func CreateProduct(c *gin.Context) {
@@ -47,6 +49,7 @@ func CreateProduct(c *gin.Context) {
 		Price:       req.Price,
 		Stock:       req.Stock,
 		StockLimit:  req.StockLimit,
+		Category:    strings.TrimSpace(req.Category),
 	}
 
 	if req.StockLimit == 0 {
@@ -69,8 +72,9 @@ func GetProducts(c *gin.Context) {
 	minPrice := c.Query("min_price")
 	maxPrice := c.Query("max_price")
 	inStock := c.Query("in_stock")
+	category := c.Query("category")
 
-	products, err := repository.GetProductsWithFilters(&pagination, name, minPrice, maxPrice, inStock)
+	products, err := repository.GetProductsWithFilters(&pagination, name, minPrice, maxPrice, inStock, category)
 	if err != nil {
 		log.Error().Err(err).Msg("Failed to get products")
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get products"})
@@ -119,6 +123,7 @@ func UpdateProduct(c *gin.Context) {
 	product.Description = req.Description
 	product.Price = req.Price
 	product.Stock = req.Stock
+	product.Category = strings.TrimSpace(req.Category)
 	if req.StockLimit > 0 {
 		product.StockLimit = req.StockLimit
 	}
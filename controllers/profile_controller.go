@@ -0,0 +1,56 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"smart-choice/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+func GetProfile(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+type TaxDocumentInput struct {
+	CPF  string `json:"cpf"`
+	CNPJ string `json:"cnpj"`
+}
+
+func UpdateTaxDocument(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	var input TaxDocumentInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := services.SetTaxDocument(user, input.CPF, input.CNPJ); err != nil {
+		switch {
+		case errors.Is(err, services.ErrTaxDocumentInUse):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrInvalidCPF),
+			errors.Is(err, services.ErrInvalidCNPJ),
+			errors.Is(err, services.ErrTaxDocumentConflict),
+			errors.Is(err, services.ErrTaxDocumentMissing):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			log.Error().Err(err).Msg("Failed to update tax document")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update tax document"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
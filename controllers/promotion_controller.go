@@ -0,0 +1,153 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"smart-choice/models"
+	"smart-choice/services"
+	"smart-choice/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+type PromotionRequest struct {
+	Name      string     `json:"name" binding:"required"`
+	Type      string     `json:"type" binding:"required"`
+	Active    *bool      `json:"active"`
+	Priority  int        `json:"priority"`
+	Exclusive bool       `json:"exclusive"`
+	Stackable bool       `json:"stackable"`
+	StartsAt  *time.Time `json:"starts_at"`
+	EndsAt    *time.Time `json:"ends_at"`
+
+	ProductIDs    []uint   `json:"product_ids"`
+	Categories    []string `json:"categories"`
+	BuyQuantity   uint     `json:"buy_quantity"`
+	PayQuantity   uint     `json:"pay_quantity"`
+	MinAmount     float64  `json:"min_amount"`
+	Percentage    float64  `json:"percentage"`
+	FreeProductID *uint    `json:"free_product_id"`
+	FreeQuantity  uint     `json:"free_quantity"`
+}
+
+func bindPromotionRequest(c *gin.Context) (*models.Promotion, bool) {
+	var req PromotionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+
+	if errs := utils.ValidatePromotion(req.Name, req.Type, req.BuyQuantity, req.PayQuantity, req.Percentage, req.FreeProductID, req.StartsAt, req.EndsAt); len(errs) > 0 {
+		utils.HandleValidationError(c, errs)
+		return nil, false
+	}
+
+	promotion := models.Promotion{
+		Name:          strings.TrimSpace(req.Name),
+		Type:          req.Type,
+		Active:        req.Active == nil || *req.Active,
+		Priority:      req.Priority,
+		Exclusive:     req.Exclusive,
+		Stackable:     req.Stackable,
+		StartsAt:      req.StartsAt,
+		EndsAt:        req.EndsAt,
+		ProductIDs:    req.ProductIDs,
+		Categories:    req.Categories,
+		BuyQuantity:   req.BuyQuantity,
+		PayQuantity:   req.PayQuantity,
+		MinAmount:     req.MinAmount,
+		Percentage:    req.Percentage,
+		FreeProductID: req.FreeProductID,
+		FreeQuantity:  req.FreeQuantity,
+	}
+	return &promotion, true
+}
+
+func ListPromotions(c *gin.Context) {
+	pagination := utils.GeneratePaginationFromRequest(c)
+
+	promotions, err := services.ListPromotions(&pagination, c.Query("active"))
+	if err != nil {
+		respondPromotionError(c, err, "Failed to list promotions")
+		return
+	}
+
+	pagination.Rows = promotions
+	c.JSON(http.StatusOK, pagination)
+}
+
+func GetPromotion(c *gin.Context) {
+	id, ok := parseIDParam(c, "id", "Invalid promotion ID")
+	if !ok {
+		return
+	}
+
+	promotion, err := services.GetPromotion(id)
+	if err != nil {
+		respondPromotionError(c, err, "Failed to get promotion")
+		return
+	}
+
+	c.JSON(http.StatusOK, promotion)
+}
+
+func CreatePromotion(c *gin.Context) {
+	promotion, ok := bindPromotionRequest(c)
+	if !ok {
+		return
+	}
+
+	if err := services.CreatePromotion(promotion); err != nil {
+		respondPromotionError(c, err, "Failed to create promotion")
+		return
+	}
+
+	c.JSON(http.StatusCreated, promotion)
+}
+
+func UpdatePromotion(c *gin.Context) {
+	id, ok := parseIDParam(c, "id", "Invalid promotion ID")
+	if !ok {
+		return
+	}
+	changes, ok := bindPromotionRequest(c)
+	if !ok {
+		return
+	}
+
+	promotion, err := services.UpdatePromotion(id, *changes)
+	if err != nil {
+		respondPromotionError(c, err, "Failed to update promotion")
+		return
+	}
+
+	c.JSON(http.StatusOK, promotion)
+}
+
+func DeletePromotion(c *gin.Context) {
+	id, ok := parseIDParam(c, "id", "Invalid promotion ID")
+	if !ok {
+		return
+	}
+
+	if err := services.DeletePromotion(id); err != nil {
+		respondPromotionError(c, err, "Failed to delete promotion")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Promotion deleted successfully"})
+}
+
+func respondPromotionError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, services.ErrPromotionNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	default:
+		log.Error().Err(err).Msg(message)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
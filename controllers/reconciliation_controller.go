@@ -0,0 +1,148 @@
+package controllers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"smart-choice/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+// maxSettlementFileSize bounds the settlement file upload (10 MB).
+const maxSettlementFileSize = 10 << 20
+
+func CreateReconciliation(c *gin.Context) {
+	admin, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	provider := c.PostForm("provider")
+	if provider == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "provider is required"})
+		return
+	}
+
+	periodStart, periodEnd, ok := parseReconciliationPeriod(c)
+	if !ok {
+		return
+	}
+
+	header, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A settlement file is required"})
+		return
+	}
+	if header.Size > maxSettlementFileSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Settlement file is too large"})
+		return
+	}
+
+	file, err := header.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read settlement file"})
+		return
+	}
+	defer file.Close()
+
+	content, err := io.ReadAll(file)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read settlement file"})
+		return
+	}
+
+	run, err := services.RequestReconciliation(c.Request.Context(), admin, provider, header.Filename, content, periodStart, periodEnd)
+	if err != nil {
+		respondReconciliationError(c, err, "Failed to start reconciliation")
+		return
+	}
+
+	c.JSON(http.StatusAccepted, run)
+}
+
+// parseReconciliationPeriod reads the optional from/to dates (YYYY-MM-DD);
+// the period covers both days in full.
+func parseReconciliationPeriod(c *gin.Context) (*time.Time, *time.Time, bool) {
+	from, to := c.PostForm("from"), c.PostForm("to")
+	if from == "" && to == "" {
+		return nil, nil, true
+	}
+
+	start, err := time.ParseInLocation("2006-01-02", from, time.Local)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from must be a date (YYYY-MM-DD)"})
+		return nil, nil, false
+	}
+	end, err := time.ParseInLocation("2006-01-02", to, time.Local)
+	if err != nil || end.Before(start) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "to must be a date (YYYY-MM-DD) not before from"})
+		return nil, nil, false
+	}
+	end = end.AddDate(0, 0, 1).Add(-time.Nanosecond)
+
+	return &start, &end, true
+}
+
+func ListReconciliations(c *gin.Context) {
+	runs, err := services.ListReconciliationRuns()
+	if err != nil {
+		respondReconciliationError(c, err, "Failed to list reconciliations")
+		return
+	}
+
+	c.JSON(http.StatusOK, runs)
+}
+
+func GetReconciliation(c *gin.Context) {
+	id, ok := parseIDParam(c, "id", "Invalid reconciliation ID")
+	if !ok {
+		return
+	}
+
+	run, err := services.GetReconciliationRun(id, c.Query("result"))
+	if err != nil {
+		respondReconciliationError(c, err, "Failed to get reconciliation")
+		return
+	}
+
+	c.JSON(http.StatusOK, run)
+}
+
+func ExportReconciliation(c *gin.Context) {
+	id, ok := parseIDParam(c, "id", "Invalid reconciliation ID")
+	if !ok {
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := services.WriteReconciliationCSV(&buf, id); err != nil {
+		respondReconciliationError(c, err, "Failed to export reconciliation")
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=conciliacao-%d.csv", id))
+	c.Data(http.StatusOK, "text/csv; charset=utf-8", buf.Bytes())
+}
+
+func respondReconciliationError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, services.ErrReconciliationNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidSettlementFile),
+		errors.Is(err, services.ErrUnknownPaymentProvider):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrReconciliationNotReady):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrJobQueueUnavailable):
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+	default:
+		log.Error().Err(err).Msg(message)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
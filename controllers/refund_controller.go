@@ -0,0 +1,73 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"smart-choice/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+func RefundOrder(c *gin.Context) {
+	admin, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	id, ok := parseIDParam(c, "id", "Invalid order ID")
+	if !ok {
+		return
+	}
+
+	var req services.RefundRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	refund, err := services.RefundOrder(c.Request.Context(), admin, id, req)
+	if err != nil {
+		respondRefundError(c, err, "Failed to refund order")
+		return
+	}
+
+	c.JSON(http.StatusCreated, refund)
+}
+
+func ListOrderRefunds(c *gin.Context) {
+	id, ok := parseIDParam(c, "id", "Invalid order ID")
+	if !ok {
+		return
+	}
+
+	refunds, err := services.ListOrderRefunds(id)
+	if err != nil {
+		respondRefundError(c, err, "Failed to list refunds")
+		return
+	}
+
+	c.JSON(http.StatusOK, refunds)
+}
+
+func respondRefundError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, services.ErrOrderNotFound),
+		errors.Is(err, services.ErrRefundItemNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrOrderNotRefundable),
+		errors.Is(err, services.ErrRefundExceedsPaid),
+		errors.Is(err, services.ErrRefundQuantityExceeded),
+		errors.Is(err, services.ErrRefundRequiresManual),
+		errors.Is(err, services.ErrRefundDeclined),
+		errors.Is(err, services.ErrInvalidRefundAmount):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrRefundAmountRequired),
+		errors.Is(err, services.ErrRefundReasonRequired),
+		errors.Is(err, services.ErrDuplicateRefundLineItem):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		log.Error().Err(err).Msg(message)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
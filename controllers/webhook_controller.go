@@ -0,0 +1,48 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"smart-choice/repository"
+	"smart-choice/services"
+	"smart-choice/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+func ListWebhookEvents(c *gin.Context) {
+	pagination := utils.GeneratePaginationFromRequest(c)
+
+	events, err := repository.GetWebhookEvents(&pagination, c.Query("provider"), c.Query("status"))
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to list webhook events")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list webhook events"})
+		return
+	}
+
+	pagination.Rows = events
+	c.JSON(http.StatusOK, pagination)
+}
+
+func ReplayWebhookEvent(c *gin.Context) {
+	id, ok := parseIDParam(c, "id", "Invalid event ID")
+	if !ok {
+		return
+	}
+
+	event, err := services.ReplayWebhookEvent(id)
+	if errors.Is(err, services.ErrWebhookEventNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if event == nil {
+		log.Error().Err(err).Uint("event_id", id).Msg("Failed to replay webhook event")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to replay webhook event"})
+		return
+	}
+
+	// The handler's own failure is recorded on the event and shown to the admin.
+	c.JSON(http.StatusOK, event)
+}
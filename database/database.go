@@ -16,7 +16,20 @@ import (
 var DB *gorm.DB
 
 func autoMigrate(db *gorm.DB) {
-	db.AutoMigrate(&models.User{}, &models.Product{}, &models.Order{}, &models.OrderItem{}, &models.Coupon{}, &models.ActivityLog{})
+	db.AutoMigrate(
+		&models.User{}, &models.Product{}, &models.Order{}, &models.OrderItem{}, &models.Coupon{}, &models.ActivityLog{},
+		&models.Address{},
+		&models.PrivacyRequest{},
+		&models.Payment{},
+		&models.Refund{}, &models.RefundItem{},
+		&models.WebhookEvent{},
+		&models.PaymentReview{},
+		&models.ReconciliationRun{}, &models.ReconciliationItem{},
+		&models.CouponRedemption{},
+		&models.Promotion{}, &models.OrderPromotion{},
+		&models.GiftCard{}, &models.GiftCardTransaction{}, &models.StoreCreditAccount{}, &models.StoreCreditEntry{},
+		&models.LoyaltyAccount{}, &models.LoyaltyEntry{}, &models.LoyaltyRule{},
+	)
 }
 
 func ConnectDB() {
@@ -39,12 +39,30 @@ func main() {
 	config.LoadEnv()
 	database.ConnectDB()
 
-	// Initialize Redis services
+	// Initialize cache, job queue and rate limit services (SERVICE_BACKEND)
 	serviceManager := services.GetServiceManager()
 	if err := serviceManager.InitializeServices(); err != nil {
 		log.Error().Err(err).Msg("Failed to initialize services")
 	}
 
+	scheduler := services.NewDefaultScheduler(serviceManager.GetCacheService())
+	scheduler.Start(context.Background())
+
+	// Consume and schedule background jobs when the queue is available
+	var workerPool *services.WorkerPool
+	var jobSchedules *services.CronScheduler
+	workerConfig := services.DefaultWorkerPoolConfig()
+	if jobQueue := serviceManager.GetJobQueue(); jobQueue != nil {
+		workerPool = services.NewWorkerPool(jobQueue, services.NewDefaultJobRegistry(), workerConfig)
+		workerPool.Start(context.Background())
+
+		jobSchedules, err = services.NewDefaultCronScheduler(jobQueue, serviceManager.GetCacheService())
+		if err != nil {
+			log.Fatal().Err(err).Msg("Invalid job schedule")
+		}
+		jobSchedules.Start(context.Background())
+	}
+
 	r := gin.Default()
 
 	r.Use(middlewares.SecurityHeadersMiddleware())
@@ -89,6 +107,26 @@ func main() {
 		log.Error().Err(err).Msg("Server forced to shutdown")
 	}
 
+	// Stop periodic tasks and let in-flight jobs finish before closing
+	// their dependencies
+	scheduler.Stop()
+	if jobSchedules != nil {
+		jobSchedules.Stop()
+	}
+	if workerPool != nil {
+		// In-flight jobs get a full job timeout to finish, however long the
+		// HTTP server took to drain; the ones still running are re-queued.
+		drainTimeout := workerConfig.JobTimeout
+		if drainTimeout <= 0 {
+			drainTimeout = 30 * time.Second
+		}
+		drainCtx, drainCancel := context.WithTimeout(context.Background(), drainTimeout)
+		if err := workerPool.Stop(drainCtx); err != nil {
+			log.Error().Err(err).Msg("Job workers forced to stop")
+		}
+		drainCancel()
+	}
+
 	// Close database connection
 	if sqlDB, err := database.DB.DB(); err == nil {
 		sqlDB.Close()
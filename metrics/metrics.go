@@ -25,6 +25,50 @@ var (
 		},
 		[]string{"method", "path"},
 	)
+
+	// PaymentReviewsTotal counts settlements sent to manual review.
+	PaymentReviewsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "payment_reviews_total",
+			Help: "Total number of payments flagged for review by reason",
+		},
+		[]string{"source", "reason"},
+	)
+
+	// JobsProcessedTotal counts background job executions by result.
+	JobsProcessedTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "jobs_processed_total",
+			Help: "Total number of background jobs processed by type and result",
+		},
+		[]string{"type", "result"},
+	)
+
+	JobDuration = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name: "job_duration_seconds",
+			Help: "Duration of background job executions",
+		},
+		[]string{"type"},
+	)
+
+	// JobsDeadLetteredTotal counts jobs that used all their attempts.
+	JobsDeadLetteredTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "jobs_dead_lettered_total",
+			Help: "Total number of background jobs moved to the dead letter queue",
+		},
+		[]string{"type"},
+	)
+
+	// JobQueueSize reports how many jobs are in each state of the queue.
+	JobQueueSize = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "job_queue_size",
+			Help: "Number of background jobs by queue state",
+		},
+		[]string{"state"},
+	)
 )
 
 func PrometheusMiddleware() gin.HandlerFunc {
@@ -0,0 +1,158 @@
+package middlewares
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"smart-choice/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	idempotencyInFlight  = "in_flight"
+	idempotencyCompleted = "completed"
+	maxIdempotencyKeyLen = 255
+)
+
+// idempotencyRecord is what is kept in the cache under an Idempotency-Key.
+type idempotencyRecord struct {
+	Fingerprint string `json:"fingerprint"`
+	State       string `json:"state"`
+	StatusCode  int    `json:"status_code,omitempty"`
+	ContentType string `json:"content_type,omitempty"`
+	Body        []byte `json:"body,omitempty"`
+}
+
+// capturingWriter keeps a copy of the response so it can be replayed.
+type capturingWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *capturingWriter) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *capturingWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// IdempotencyMiddleware honours the Idempotency-Key header on POST, PUT and
+// DELETE. The first request with a key runs normally and its response is
+// stored; retries with the same key and body get the stored response back.
+// Reusing a key for a different request is rejected with 422, and a retry
+// that arrives while the first request is still running gets 409. Keys are
+// scoped per user, so it must run after AuthMiddleware.
+func IdempotencyMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader("Idempotency-Key")
+		method := c.Request.Method
+		if key == "" || (method != http.MethodPost && method != http.MethodPut && method != http.MethodDelete) {
+			c.Next()
+			return
+		}
+
+		if len(key) > maxIdempotencyKeyLen {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Idempotency-Key is too long"})
+			return
+		}
+
+		cache := services.GetServiceManager().GetCacheService()
+		if cache == nil {
+			log.Warn().Msg("Cache unavailable, Idempotency-Key ignored")
+			c.Next()
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		sum := sha256.Sum256(append([]byte(method+" "+c.Request.URL.Path+"\n"), body...))
+		fingerprint := hex.EncodeToString(sum[:])
+
+		userID, _ := c.Get("user_id")
+		cacheKey := fmt.Sprintf("idempotency:%v:%s", userID, key)
+		ctx := c.Request.Context()
+
+		acquired, err := cache.SetNX(ctx, cacheKey, idempotencyRecord{Fingerprint: fingerprint, State: idempotencyInFlight},
+			services.GetEnvDuration("IDEMPOTENCY_LOCK_TTL", time.Minute))
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to reserve idempotency key")
+			c.Next()
+			return
+		}
+
+		if !acquired {
+			replayIdempotentResponse(c, cache, cacheKey, fingerprint)
+			return
+		}
+
+		writer := &capturingWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+		c.Next()
+
+		// Server errors are not stored so that the client can retry them.
+		if writer.Status() >= http.StatusInternalServerError {
+			if err := cache.Delete(ctx, cacheKey); err != nil {
+				log.Error().Err(err).Msg("Failed to release idempotency key")
+			}
+			return
+		}
+
+		record := idempotencyRecord{
+			Fingerprint: fingerprint,
+			State:       idempotencyCompleted,
+			StatusCode:  writer.Status(),
+			ContentType: writer.Header().Get("Content-Type"),
+			Body:        writer.body.Bytes(),
+		}
+		if err := cache.Set(ctx, cacheKey, record, services.GetEnvDuration("IDEMPOTENCY_TTL", 24*time.Hour)); err != nil {
+			log.Error().Err(err).Msg("Failed to store idempotent response")
+		}
+	}
+}
+
+func replayIdempotentResponse(c *gin.Context, cache services.CacheService, cacheKey, fingerprint string) {
+	stored, ok := cache.Get(c.Request.Context(), cacheKey)
+	if !ok {
+		c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": "A request with this Idempotency-Key is still being processed"})
+		return
+	}
+
+	// The cache hands back generic JSON; round-trip it into the record.
+	var record idempotencyRecord
+	raw, err := json.Marshal(stored)
+	if err == nil {
+		err = json.Unmarshal(raw, &record)
+	}
+	if err != nil {
+		log.Error().Err(err).Msg("Invalid idempotency record")
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to replay request"})
+		return
+	}
+
+	switch {
+	case record.Fingerprint != fingerprint:
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": "Idempotency-Key was already used for a different request"})
+	case record.State != idempotencyCompleted:
+		c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": "A request with this Idempotency-Key is still being processed"})
+	default:
+		c.Header("Idempotent-Replayed", "true")
+		c.Data(record.StatusCode, record.ContentType, record.Body)
+		c.Abort()
+	}
+}
@@ -0,0 +1,45 @@
+package models
+
+import "gorm.io/gorm"
+
+type Address struct {
+	gorm.Model
+	UserID            uint   `json:"user_id" gorm:"index;not null"`
+	Label             string `json:"label"`
+	RecipientName     string `json:"recipient_name"`
+	CEP               string `json:"cep" gorm:"size:8;not null"`
+	Logradouro        string `json:"logradouro" gorm:"not null"`
+	Numero            string `json:"numero" gorm:"not null"`
+	Complemento       string `json:"complemento"`
+	Bairro            string `json:"bairro" gorm:"not null"`
+	Cidade            string `json:"cidade" gorm:"not null"`
+	UF                string `json:"uf" gorm:"size:2;not null"`
+	IsDefaultShipping bool   `json:"is_default_shipping" gorm:"default:false"`
+	IsDefaultBilling  bool   `json:"is_default_billing" gorm:"default:false"`
+}
+
+// AddressSnapshot is the copy of an Address stored on an order at checkout,
+// so later edits to the address book do not rewrite order history.
+type AddressSnapshot struct {
+	RecipientName string `json:"recipient_name"`
+	CEP           string `json:"cep"`
+	Logradouro    string `json:"logradouro"`
+	Numero        string `json:"numero"`
+	Complemento   string `json:"complemento"`
+	Bairro        string `json:"bairro"`
+	Cidade        string `json:"cidade"`
+	UF            string `json:"uf"`
+}
+
+func (a *Address) Snapshot() AddressSnapshot {
+	return AddressSnapshot{
+		RecipientName: a.RecipientName,
+		CEP:           a.CEP,
+		Logradouro:    a.Logradouro,
+		Numero:        a.Numero,
+		Complemento:   a.Complemento,
+		Bairro:        a.Bairro,
+		Cidade:        a.Cidade,
+		UF:            a.UF,
+	}
+}
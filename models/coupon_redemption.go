@@ -0,0 +1,18 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// CouponRedemption records that a coupon was spent on an order. ReleasedAt is
+// set when the order is canceled and the use is given back to the coupon.
+type CouponRedemption struct {
+	gorm.Model
+	CouponID   uint       `json:"coupon_id" gorm:"index;not null"`
+	OrderID    uint       `json:"order_id" gorm:"uniqueIndex;not null"`
+	UserID     uint       `json:"user_id" gorm:"index;not null"`
+	Discount   float64    `json:"discount"`
+	ReleasedAt *time.Time `json:"released_at,omitempty"`
+}
@@ -0,0 +1,57 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// GiftCard is a prepaid balance redeemable at checkout by whoever holds the
+// code. RemainingBalance only goes down through GiftCardTransaction entries.
+type GiftCard struct {
+	gorm.Model
+	Code             string     `json:"code" gorm:"uniqueIndex;size:32;not null"`
+	InitialBalance   float64    `json:"initial_balance"`
+	RemainingBalance float64    `json:"remaining_balance" gorm:"check:gift_card_balance_non_negative,remaining_balance >= 0"`
+	ExpiresAt        *time.Time `json:"expires_at"`
+	Active           bool       `json:"active"`
+	IssuedByID       uint       `json:"issued_by_id"`
+	Note             string     `json:"note,omitempty"`
+}
+
+// Usable reports whether the card can pay for something at t.
+func (g *GiftCard) Usable(t time.Time) bool {
+	return g.Active && (g.ExpiresAt == nil || t.Before(*g.ExpiresAt))
+}
+
+// GiftCardTransaction is one movement of a gift card balance: negative when
+// spent on an order, positive when given back.
+type GiftCardTransaction struct {
+	gorm.Model
+	GiftCardID   uint    `json:"gift_card_id" gorm:"index;not null"`
+	OrderID      *uint   `json:"order_id" gorm:"index"`
+	Amount       float64 `json:"amount"`
+	BalanceAfter float64 `json:"balance_after"`
+	Reason       string  `json:"reason"`
+}
+
+// StoreCreditAccount holds a user's store credit balance. Every change is
+// recorded as a StoreCreditEntry.
+type StoreCreditAccount struct {
+	gorm.Model
+	UserID  uint    `json:"user_id" gorm:"uniqueIndex;not null"`
+	Balance float64 `json:"balance" gorm:"check:store_credit_balance_non_negative,balance >= 0"`
+}
+
+// StoreCreditEntry is a line of the store credit ledger: positive for credit
+// issued (returns, goodwill), negative for credit spent at checkout.
+type StoreCreditEntry struct {
+	gorm.Model
+	UserID       uint    `json:"user_id" gorm:"index;not null"`
+	Amount       float64 `json:"amount"`
+	BalanceAfter float64 `json:"balance_after"`
+	Reason       string  `json:"reason"`
+	OrderID      *uint   `json:"order_id" gorm:"index"`
+	RefundID     *uint   `json:"refund_id"`
+	CreatedByID  *uint   `json:"created_by_id"`
+}
@@ -0,0 +1,74 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	LoyaltyEntryEarn    = "earn"
+	LoyaltyEntryRedeem  = "redeem"
+	LoyaltyEntryExpire  = "expire"
+	LoyaltyEntryRestore = "restore"
+	// LoyaltyEntryClawback takes back points earned on a refunded purchase.
+	LoyaltyEntryClawback = "clawback"
+
+	// LoyaltyRuleCategoryBonus adds BonusPerReal points per real spent on
+	// products of Category.
+	LoyaltyRuleCategoryBonus = "category_bonus"
+	// LoyaltyRuleCampaign multiplies the points of orders delivered while it
+	// runs. Only the highest running multiplier counts.
+	LoyaltyRuleCampaign = "campaign_multiplier"
+)
+
+// LoyaltyAccount holds a user's points balance, which always equals the
+// Remaining points of their unexpired earn entries.
+type LoyaltyAccount struct {
+	gorm.Model
+	UserID  uint `json:"user_id" gorm:"uniqueIndex;not null"`
+	Balance int  `json:"balance" gorm:"check:loyalty_balance_non_negative,balance >= 0"`
+}
+
+// LoyaltyEntry is a line of the points ledger. Points are positive when
+// earned or restored and negative when redeemed or expired. Earned entries
+// track how many of their points are still unspent in Remaining; redemptions
+// consume the entries that expire first.
+type LoyaltyEntry struct {
+	gorm.Model
+	UserID      uint       `json:"user_id" gorm:"index;not null"`
+	Type        string     `json:"type"`
+	Points      int        `json:"points"`
+	Remaining   int        `json:"remaining"`
+	OrderID     *uint      `json:"order_id" gorm:"index"`
+	ExpiresAt   *time.Time `json:"expires_at" gorm:"index"`
+	Description string     `json:"description"`
+}
+
+// LoyaltyRule adjusts how many points an order earns on top of the base
+// points per real.
+type LoyaltyRule struct {
+	gorm.Model
+	Name         string     `json:"name"`
+	Type         string     `json:"type"`
+	Category     string     `json:"category,omitempty"`
+	BonusPerReal float64    `json:"bonus_per_real,omitempty"`
+	Multiplier   float64    `json:"multiplier,omitempty"`
+	StartsAt     *time.Time `json:"starts_at"`
+	EndsAt       *time.Time `json:"ends_at"`
+	Active       bool       `json:"active"`
+}
+
+// ActiveAt reports whether the rule applies at t.
+func (r *LoyaltyRule) ActiveAt(t time.Time) bool {
+	if !r.Active {
+		return false
+	}
+	if r.StartsAt != nil && t.Before(*r.StartsAt) {
+		return false
+	}
+	if r.EndsAt != nil && !t.Before(*r.EndsAt) {
+		return false
+	}
+	return true
+}
@@ -1,20 +1,52 @@
 package models
 
 import (
+	"encoding/json"
 	"fmt"
+	"math"
 	"time"
 
+	"smart-choice/utils"
+
 	"gorm.io/gorm"
 )
 
 type User struct {
 	gorm.Model
-	Name        string `json:"name"`
-	Email       string `json:"email" gorm:"unique"`
-	Password    string `json:"-" gorm:"not null"`
-	IsAdmin     bool   `json:"is_admin" gorm:"default:false"`
-	TwoFA       bool   `json:"two_fa" gorm:"default:false"`
-	TwoFASecret string `json:"-"`
+	Name        string  `json:"name"`
+	Email       string  `json:"email" gorm:"unique"`
+	Password    string  `json:"-" gorm:"not null"`
+	IsAdmin     bool    `json:"is_admin" gorm:"default:false"`
+	TwoFA       bool    `json:"two_fa" gorm:"default:false"`
+	TwoFASecret string  `json:"-"`
+	CPF         *string `json:"-" gorm:"uniqueIndex;size:11"`
+	CNPJ        *string `json:"-" gorm:"uniqueIndex;size:14"`
+}
+
+// MarshalJSON exposes the tax documents only in masked form.
+func (u User) MarshalJSON() ([]byte, error) {
+	type userAlias User
+	return json.Marshal(struct {
+		userAlias
+		CPF  string `json:"cpf,omitempty"`
+		CNPJ string `json:"cnpj,omitempty"`
+	}{
+		userAlias: userAlias(u),
+		CPF:       maskDocument(u.CPF, utils.MaskCPF),
+		CNPJ:      maskDocument(u.CNPJ, utils.MaskCNPJ),
+	})
+}
+
+// HasTaxDocument reports whether the user has a CPF or CNPJ on file.
+func (u *User) HasTaxDocument() bool {
+	return (u.CPF != nil && *u.CPF != "") || (u.CNPJ != nil && *u.CNPJ != "")
+}
+
+func maskDocument(document *string, mask func(string) string) string {
+	if document == nil || *document == "" {
+		return ""
+	}
+	return mask(*document)
 }
 
 type Product struct {
@@ -24,6 +56,7 @@ type Product struct {
 	Price       float64 `json:"price"`
 	Stock       uint    `json:"stock"`
 	StockLimit  uint    `json:"stock_limit" gorm:"default:5"`
+	Category    string  `json:"category" gorm:"index"`
 }
 
 func (p *Product) AfterUpdate(tx *gorm.DB) (err error) {
@@ -42,10 +75,30 @@ type Order struct {
 	UserID     uint        `json:"user_id"`
 	User       User        `json:"user"`
 	OrderItems []OrderItem `json:"order_items"`
+	Subtotal   float64     `json:"subtotal"`
+	Discount   float64     `json:"discount"`
 	Total      float64     `json:"total"`
 	Status     string      `json:"status" gorm:"default:'pending'"`
 	CouponID   *uint       `json:"coupon_id"`
 	Coupon     *Coupon     `json:"coupon"`
+	Payments   []Payment   `json:"payments,omitempty"`
+
+	// PromotionDiscount is what automatic promotions took off; Discount is
+	// the coupon's share.
+	PromotionDiscount float64          `json:"promotion_discount"`
+	Promotions        []OrderPromotion `json:"promotions,omitempty"`
+
+	// LoyaltyDiscount is what the redeemed points took off. Points are
+	// earned once the order is delivered.
+	LoyaltyPointsRedeemed int     `json:"loyalty_points_redeemed"`
+	LoyaltyDiscount       float64 `json:"loyalty_discount"`
+	LoyaltyPointsEarned   int     `json:"loyalty_points_earned"`
+
+	// FreeShipping is set when a free-shipping coupon was applied.
+	FreeShipping    bool            `json:"free_shipping"`
+	ShippingAddress AddressSnapshot `json:"shipping_address" gorm:"embedded;embeddedPrefix:shipping_"`
+	BillingAddress  AddressSnapshot `json:"billing_address" gorm:"embedded;embeddedPrefix:billing_"`
+	InstallmentPlan InstallmentPlan `json:"installment_plan" gorm:"embedded;embeddedPrefix:installment_"`
 }
 
 type OrderItem struct {
@@ -55,16 +108,70 @@ type OrderItem struct {
 	Product   Product `json:"product"`
 	Quantity  uint    `json:"quantity"`
 	Price     float64 `json:"price"`
+	// Discount is the promotion discount on the whole line.
+	Discount float64 `json:"discount"`
+
+	RefundedQuantity uint `json:"refunded_quantity" gorm:"default:0"`
 }
 
+// NetUnitPrice is what one unit of the line cost after promotions.
+func (i *OrderItem) NetUnitPrice() float64 {
+	if i.Quantity == 0 {
+		return i.Price
+	}
+	return i.Price - i.Discount/float64(i.Quantity)
+}
+
+// NetUnitPrice is what one unit of an item of the order cost after
+// promotions, the coupon and redeemed points. The coupon and points are
+// spread over the lines in proportion to their value, so OrderItems must be
+// loaded.
+func (o *Order) NetUnitPrice(item *OrderItem) float64 {
+	unit := item.NetUnitPrice()
+	orderDiscount := o.Discount + o.LoyaltyDiscount
+	if orderDiscount <= 0 {
+		return unit
+	}
+
+	var gross float64
+	for i := range o.OrderItems {
+		gross += o.OrderItems[i].Price*float64(o.OrderItems[i].Quantity) - o.OrderItems[i].Discount
+	}
+	if gross <= 0 {
+		return unit
+	}
+	return unit * (1 - math.Min(orderDiscount/gross, 1))
+}
+
+const (
+	CouponTypePercentage   = "percentage"
+	CouponTypeFixed        = "fixed"
+	CouponTypeFreeShipping = "free_shipping"
+)
+
+// Coupon is a discount code. Discount is a percentage or an amount in BRL
+// depending on DiscountType. Empty product and category inclusion lists mean
+// the coupon applies to the whole cart; exclusions always win.
 type Coupon struct {
 	gorm.Model
-	Code       string    `json:"code" gorm:"unique"`
-	Discount   float64   `json:"discount"`
-	ValidUntil time.Time `json:"valid_until"`
-	MaxUses    uint      `json:"max_uses"`
-	UsedCount  uint      `json:"used_count" gorm:"default:0"`
-	MinAmount  float64   `json:"min_amount"`
+	Code         string     `json:"code" gorm:"unique"`
+	DiscountType string     `json:"discount_type" gorm:"default:'percentage'"`
+	Discount     float64    `json:"discount"`
+	MaxDiscount  float64    `json:"max_discount"`
+	StartsAt     *time.Time `json:"starts_at"`
+	ValidUntil   time.Time  `json:"valid_until"`
+	MaxUses      uint       `json:"max_uses"`
+	UsedCount    uint       `json:"used_count" gorm:"default:0"`
+	MinAmount    float64    `json:"min_amount"`
+	Active       bool       `json:"active" gorm:"default:true"`
+	Campaign     string     `json:"campaign" gorm:"index"`
+
+	MaxUsesPerUser     uint     `json:"max_uses_per_user"`
+	FirstOrderOnly     bool     `json:"first_order_only"`
+	IncludedProducts   []uint   `json:"included_products" gorm:"serializer:json;type:text"`
+	ExcludedProducts   []uint   `json:"excluded_products" gorm:"serializer:json;type:text"`
+	IncludedCategories []string `json:"included_categories" gorm:"serializer:json;type:text"`
+	ExcludedCategories []string `json:"excluded_categories" gorm:"serializer:json;type:text"`
 }
 
 type ActivityLog struct {
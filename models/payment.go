@@ -0,0 +1,84 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	OrderStatusPending  = "pending"
+	OrderStatusPaid     = "paid"
+	OrderStatusCanceled = "canceled"
+	// OrderStatusPaymentReview holds orders whose settlement did not match the charge.
+	OrderStatusPaymentReview = "payment_review"
+
+	OrderStatusRefunded          = "refunded"
+	OrderStatusPartiallyRefunded = "partially_refunded"
+
+	OrderStatusShipped   = "shipped"
+	OrderStatusDelivered = "delivered"
+
+	PaymentStatusPending    = "pending"
+	PaymentStatusAuthorized = "authorized"
+	PaymentStatusSucceeded  = "succeeded"
+	PaymentStatusFailed     = "failed"
+	PaymentStatusCanceled   = "canceled"
+	PaymentStatusRefunded   = "refunded"
+	PaymentStatusExpired    = "expired"
+	PaymentStatusReview     = "review"
+
+	PaymentMethodCard        = "card"
+	PaymentMethodPix         = "pix"
+	PaymentMethodBoleto      = "boleto"
+	PaymentMethodGiftCard    = "gift_card"
+	PaymentMethodStoreCredit = "store_credit"
+
+	// PaymentProviderInternal settles payments from balances the store keeps
+	// itself: gift cards and store credit.
+	PaymentProviderInternal = "internal"
+)
+
+type Payment struct {
+	gorm.Model
+	OrderID           uint       `json:"order_id" gorm:"index;not null"`
+	Provider          string     `json:"provider" gorm:"not null"`
+	ProviderReference string     `json:"provider_reference" gorm:"uniqueIndex"`
+	Method            string     `json:"method" gorm:"not null"`
+	Amount            float64    `json:"amount"`
+	Currency          string     `json:"currency" gorm:"size:3;default:'BRL'"`
+	Status            string     `json:"status" gorm:"default:'pending'"`
+	Installments      uint       `json:"installments,omitempty"`
+	FailureReason     string     `json:"failure_reason,omitempty"`
+	CapturedAt        *time.Time `json:"captured_at"`
+	ExpiresAt         *time.Time `json:"expires_at,omitempty"`
+
+	PixTxID       string `json:"pix_txid,omitempty" gorm:"index"`
+	PixBRCode     string `json:"pix_br_code,omitempty"`
+	PixQRCode     string `json:"pix_qr_code,omitempty" gorm:"-"`
+	PixEndToEndID string `json:"pix_end_to_end_id,omitempty"`
+
+	BoletoBarcode       string     `json:"boleto_barcode,omitempty"`
+	BoletoDigitableLine string     `json:"boleto_digitable_line,omitempty"`
+	BoletoBarcodeImage  string     `json:"boleto_barcode_image,omitempty" gorm:"-"`
+	DueDate             *time.Time `json:"due_date,omitempty"`
+}
+
+// InstallmentPlan is the parcelamento chosen at checkout, frozen on the order.
+type InstallmentPlan struct {
+	Count        uint    `json:"count" gorm:"default:1"`
+	Amount       float64 `json:"amount"`
+	FirstAmount  float64 `json:"first_amount"`
+	Total        float64 `json:"total"`
+	InterestFree bool    `json:"interest_free"`
+	MonthlyRate  float64 `json:"monthly_rate"`
+}
+
+// IsActive reports whether the payment still counts toward settling its order.
+func (p *Payment) IsActive() bool {
+	switch p.Status {
+	case PaymentStatusPending, PaymentStatusAuthorized, PaymentStatusSucceeded:
+		return true
+	}
+	return false
+}
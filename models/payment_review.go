@@ -0,0 +1,36 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	PaymentReviewUnderpayment     = "underpayment"
+	PaymentReviewOverpayment      = "overpayment"
+	PaymentReviewCurrencyMismatch = "currency_mismatch"
+	PaymentReviewLateSettlement   = "late_settlement"
+
+	PaymentReviewActionAccept = "accept"
+	PaymentReviewActionCancel = "cancel"
+)
+
+// PaymentReview records a settlement whose amount or currency did not match
+// what was charged, or that arrived after the charge expired or was
+// canceled. An open order waits in payment_review until an admin decides.
+type PaymentReview struct {
+	gorm.Model
+	OrderID          uint       `json:"order_id" gorm:"index;not null"`
+	PaymentID        *uint      `json:"payment_id"`
+	Source           string     `json:"source"`
+	Reason           string     `json:"reason"`
+	ExpectedAmount   float64    `json:"expected_amount"`
+	ReceivedAmount   float64    `json:"received_amount"`
+	ExpectedCurrency string     `json:"expected_currency"`
+	ReceivedCurrency string     `json:"received_currency"`
+	Resolution       string     `json:"resolution,omitempty"`
+	ResolutionNote   string     `json:"resolution_note,omitempty"`
+	ResolvedByID     *uint      `json:"resolved_by_id"`
+	ResolvedAt       *time.Time `json:"resolved_at" gorm:"index"`
+}
@@ -0,0 +1,28 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	PrivacyRequestExport  = "export"
+	PrivacyRequestErasure = "erasure"
+
+	PrivacyStatusPending    = "pending"
+	PrivacyStatusProcessing = "processing"
+	PrivacyStatusCompleted  = "completed"
+	PrivacyStatusFailed     = "failed"
+)
+
+// PrivacyRequest records an LGPD data-subject request (export or erasure).
+type PrivacyRequest struct {
+	gorm.Model
+	UserID      uint       `json:"user_id" gorm:"index;not null"`
+	Type        string     `json:"type" gorm:"not null"`
+	Status      string     `json:"status" gorm:"default:'pending'"`
+	FilePath    string     `json:"-"`
+	Error       string     `json:"error,omitempty"`
+	CompletedAt *time.Time `json:"completed_at"`
+}
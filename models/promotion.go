@@ -0,0 +1,69 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// PromotionBuyXPayY makes the cheapest units free: buy BuyQuantity, pay
+	// PayQuantity.
+	PromotionBuyXPayY = "buy_x_pay_y"
+	// PromotionCartPercentage takes Percentage off the qualifying lines once
+	// they add up to MinAmount.
+	PromotionCartPercentage = "cart_percentage"
+	// PromotionFreeItem makes FreeQuantity units of FreeProductID free for
+	// every BuyQuantity qualifying units in the cart.
+	PromotionFreeItem = "free_item"
+)
+
+// Promotion is a discount applied automatically, without a code. Promotions
+// are evaluated by descending Priority. An Exclusive promotion is only
+// applied alone; a promotion that is not Stackable skips lines another
+// promotion already discounted. Empty ProductIDs and Categories mean every
+// product qualifies.
+type Promotion struct {
+	gorm.Model
+	Name      string     `json:"name"`
+	Type      string     `json:"type"`
+	Active    bool       `json:"active"`
+	Priority  int        `json:"priority" gorm:"index"`
+	Exclusive bool       `json:"exclusive"`
+	Stackable bool       `json:"stackable"`
+	StartsAt  *time.Time `json:"starts_at"`
+	EndsAt    *time.Time `json:"ends_at"`
+
+	ProductIDs    []uint   `json:"product_ids" gorm:"serializer:json;type:text"`
+	Categories    []string `json:"categories" gorm:"serializer:json;type:text"`
+	BuyQuantity   uint     `json:"buy_quantity"`
+	PayQuantity   uint     `json:"pay_quantity"`
+	MinAmount     float64  `json:"min_amount"`
+	Percentage    float64  `json:"percentage"`
+	FreeProductID *uint    `json:"free_product_id"`
+	FreeQuantity  uint     `json:"free_quantity"`
+}
+
+// ActiveAt reports whether the promotion runs at t.
+func (p *Promotion) ActiveAt(t time.Time) bool {
+	if !p.Active {
+		return false
+	}
+	if p.StartsAt != nil && t.Before(*p.StartsAt) {
+		return false
+	}
+	if p.EndsAt != nil && !t.Before(*p.EndsAt) {
+		return false
+	}
+	return true
+}
+
+// OrderPromotion records a promotion applied to an order and how much it
+// took off.
+type OrderPromotion struct {
+	gorm.Model
+	OrderID     uint    `json:"order_id" gorm:"index;not null"`
+	PromotionID uint    `json:"promotion_id" gorm:"index"`
+	Name        string  `json:"name"`
+	Discount    float64 `json:"discount"`
+}
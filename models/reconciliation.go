@@ -0,0 +1,58 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	ReconciliationStatusPending    = "pending"
+	ReconciliationStatusProcessing = "processing"
+	ReconciliationStatusCompleted  = "completed"
+	ReconciliationStatusFailed     = "failed"
+
+	ReconciliationMatched         = "matched"
+	ReconciliationAmountMismatch  = "amount_mismatch"
+	ReconciliationStatusMismatch  = "status_mismatch"
+	ReconciliationDuplicate       = "duplicate"
+	ReconciliationNotFound        = "not_found"
+	ReconciliationMissingFromFile = "missing_from_file"
+)
+
+// ReconciliationRun is one import of a provider settlement file and its
+// comparison against our payments.
+type ReconciliationRun struct {
+	gorm.Model
+	Provider        string               `json:"provider" gorm:"not null"`
+	FileName        string               `json:"file_name"`
+	FilePath        string               `json:"-"`
+	PeriodStart     *time.Time           `json:"period_start"`
+	PeriodEnd       *time.Time           `json:"period_end"`
+	Status          string               `json:"status" gorm:"default:'pending'"`
+	Error           string               `json:"error,omitempty"`
+	TotalLines      int                  `json:"total_lines"`
+	Matched         int                  `json:"matched"`
+	Mismatched      int                  `json:"mismatched"`
+	Duplicates      int                  `json:"duplicates"`
+	NotFound        int                  `json:"not_found"`
+	MissingFromFile int                  `json:"missing_from_file"`
+	CreatedByID     uint                 `json:"created_by_id"`
+	CompletedAt     *time.Time           `json:"completed_at"`
+	Items           []ReconciliationItem `json:"items,omitempty"`
+}
+
+// ReconciliationItem is the outcome for one settlement line, or for a
+// payment that the settlement file should have contained but did not.
+type ReconciliationItem struct {
+	gorm.Model
+	RunID          uint    `json:"run_id" gorm:"index;not null"`
+	Line           int     `json:"line"`
+	Reference      string  `json:"reference"`
+	Result         string  `json:"result" gorm:"index"`
+	FileAmount     float64 `json:"file_amount"`
+	ExpectedAmount float64 `json:"expected_amount"`
+	PaymentID      *uint   `json:"payment_id"`
+	OrderID        *uint   `json:"order_id"`
+	PaymentStatus  string  `json:"payment_status,omitempty"`
+}
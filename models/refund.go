@@ -0,0 +1,35 @@
+package models
+
+import "gorm.io/gorm"
+
+const (
+	RefundStatusPending   = "pending"
+	RefundStatusSucceeded = "succeeded"
+	RefundStatusFailed    = "failed"
+)
+
+// Refund returns money for a paid order, either in full or for some of its
+// items. Manual refunds were settled outside the payment provider.
+// StoreCreditAmount is the part credited to the customer's store credit
+// rather than returned through the provider.
+type Refund struct {
+	gorm.Model
+	OrderID           uint         `json:"order_id" gorm:"index;not null"`
+	PaymentID         *uint        `json:"payment_id"`
+	Amount            float64      `json:"amount"`
+	Reason            string       `json:"reason"`
+	Status            string       `json:"status" gorm:"default:'pending'"`
+	Manual            bool         `json:"manual"`
+	StoreCreditAmount float64      `json:"store_credit_amount"`
+	ProviderReference string       `json:"provider_reference,omitempty"`
+	CreatedByID       uint         `json:"created_by_id"`
+	Items             []RefundItem `json:"items,omitempty"`
+}
+
+type RefundItem struct {
+	gorm.Model
+	RefundID    uint    `json:"refund_id" gorm:"index;not null"`
+	OrderItemID uint    `json:"order_item_id"`
+	Quantity    uint    `json:"quantity"`
+	Amount      float64 `json:"amount"`
+}
@@ -0,0 +1,27 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	WebhookEventStatusReceived  = "received"
+	WebhookEventStatusProcessed = "processed"
+	WebhookEventStatusFailed    = "failed"
+)
+
+// WebhookEvent stores every verified webhook delivery. The provider's event
+// ID is unique so retried deliveries are recognised and not reprocessed.
+type WebhookEvent struct {
+	gorm.Model
+	Provider       string     `json:"provider" gorm:"not null;uniqueIndex:idx_webhook_events_provider_event"`
+	EventID        string     `json:"event_id" gorm:"not null;uniqueIndex:idx_webhook_events_provider_event"`
+	Payload        string     `json:"payload" gorm:"type:text"`
+	EventTimestamp time.Time  `json:"event_timestamp"`
+	Status         string     `json:"status" gorm:"index;default:'received'"`
+	Attempts       int        `json:"attempts" gorm:"default:0"`
+	LastError      string     `json:"last_error,omitempty"`
+	ProcessedAt    *time.Time `json:"processed_at"`
+}
@@ -0,0 +1,50 @@
+package repository
+
+import (
+	"smart-choice/database"
+	"smart-choice/models"
+
+	"gorm.io/gorm"
+)
+
+func GetAddressesByUserID(userID uint) ([]models.Address, error) {
+	var addresses []models.Address
+	err := database.DB.Where("user_id = ?", userID).Order("id asc").Find(&addresses).Error
+	return addresses, err
+}
+
+func GetUserAddress(userID, addressID uint) (models.Address, error) {
+	var address models.Address
+	err := database.DB.Where("user_id = ?", userID).First(&address, addressID).Error
+	return address, err
+}
+
+func GetDefaultShippingAddress(tx *gorm.DB, userID uint) (models.Address, error) {
+	var address models.Address
+	err := tx.Where("user_id = ? AND is_default_shipping = ?", userID, true).First(&address).Error
+	return address, err
+}
+
+func GetDefaultBillingAddress(tx *gorm.DB, userID uint) (models.Address, error) {
+	var address models.Address
+	err := tx.Where("user_id = ? AND is_default_billing = ?", userID, true).First(&address).Error
+	return address, err
+}
+
+func CountUserAddresses(tx *gorm.DB, userID uint) (int64, error) {
+	var count int64
+	err := tx.Model(&models.Address{}).Where("user_id = ?", userID).Count(&count).Error
+	return count, err
+}
+
+// ClearDefaultAddressFlags unsets the given default flag ("is_default_shipping"
+// or "is_default_billing") on every address of the user except exceptID.
+func ClearDefaultAddressFlags(tx *gorm.DB, userID, exceptID uint, column string) error {
+	return tx.Model(&models.Address{}).
+		Where("user_id = ? AND id <> ?", userID, exceptID).
+		Update(column, false).Error
+}
+
+func DeleteUserAddress(userID, addressID uint) error {
+	return database.DB.Where("user_id = ?", userID).Delete(&models.Address{}, addressID).Error
+}
@@ -3,14 +3,95 @@ package repository
 import (
 	"smart-choice/database"
 	"smart-choice/models"
+	"smart-choice/utils"
+
+	"gorm.io/gorm"
 )
 
+// GetCouponByCode looks the code up case-insensitively: coupons created
+// before codes were normalised may be stored in mixed case. code must already
+// be upper-cased.
 func GetCouponByCode(code string) (models.Coupon, error) {
 	var coupon models.Coupon
-	err := database.DB.Where("code = ?", code).First(&coupon).Error
+	err := database.DB.Where("UPPER(code) = ?", code).First(&coupon).Error
+	return coupon, err
+}
+
+func GetCouponByID(id uint) (models.Coupon, error) {
+	var coupon models.Coupon
+	err := database.DB.First(&coupon, id).Error
 	return coupon, err
 }
 
+func CreateCoupon(coupon *models.Coupon) error {
+	return database.DB.Create(coupon).Error
+}
+
 func UpdateCoupon(coupon *models.Coupon) error {
 	return database.DB.Save(coupon).Error
 }
+
+func GetCoupons(pagination *utils.Pagination, campaign, active, code string) ([]models.Coupon, error) {
+	var coupons []models.Coupon
+	query := database.DB.Model(&models.Coupon{})
+
+	if campaign != "" {
+		query = query.Where("campaign = ?", campaign)
+	}
+	if active == "true" || active == "false" {
+		query = query.Where("active = ?", active == "true")
+	}
+	if code != "" {
+		query = query.Where("UPPER(code) LIKE ?", code+"%")
+	}
+
+	if err := query.Count(&pagination.TotalRows).Error; err != nil {
+		return nil, err
+	}
+
+	err := query.Order("id desc").Limit(pagination.GetLimit()).Offset(pagination.GetOffset()).Find(&coupons).Error
+	return coupons, err
+}
+
+func GetCouponsByCampaign(campaign string) ([]models.Coupon, error) {
+	var coupons []models.Coupon
+	query := database.DB.Order("id asc")
+	if campaign != "" {
+		query = query.Where("campaign = ?", campaign)
+	}
+	err := query.Find(&coupons).Error
+	return coupons, err
+}
+
+// GetExistingCouponCodes returns, upper-cased, which of the upper-cased codes
+// are already taken in any case.
+func GetExistingCouponCodes(codes []string) ([]string, error) {
+	var existing []string
+	err := database.DB.Model(&models.Coupon{}).Where("UPPER(code) IN ?", codes).Pluck("UPPER(code)", &existing).Error
+	return existing, err
+}
+
+func GetOrdersByCouponID(couponID uint) ([]models.Order, error) {
+	var orders []models.Order
+	err := database.DB.Where("coupon_id = ?", couponID).Order("id desc").Find(&orders).Error
+	return orders, err
+}
+
+// CountUserCouponRedemptions counts the uses of a coupon by a user that were
+// not given back by a cancellation.
+func CountUserCouponRedemptions(db *gorm.DB, couponID, userID uint) (int64, error) {
+	var count int64
+	err := db.Model(&models.CouponRedemption{}).
+		Where("coupon_id = ? AND user_id = ? AND released_at IS NULL", couponID, userID).
+		Count(&count).Error
+	return count, err
+}
+
+// CountActiveUserOrders counts the user's orders that were not canceled.
+func CountActiveUserOrders(db *gorm.DB, userID uint) (int64, error) {
+	var count int64
+	err := db.Model(&models.Order{}).
+		Where("user_id = ? AND status <> ?", userID, models.OrderStatusCanceled).
+		Count(&count).Error
+	return count, err
+}
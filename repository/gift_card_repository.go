@@ -0,0 +1,35 @@
+package repository
+
+import (
+	"smart-choice/database"
+	"smart-choice/models"
+	"smart-choice/utils"
+)
+
+func GetGiftCardByCode(code string) (models.GiftCard, error) {
+	var card models.GiftCard
+	err := database.DB.Where("code = ?", code).First(&card).Error
+	return card, err
+}
+
+func GetGiftCardByID(id uint) (models.GiftCard, error) {
+	var card models.GiftCard
+	err := database.DB.First(&card, id).Error
+	return card, err
+}
+
+func GetGiftCards(pagination *utils.Pagination, active string) ([]models.GiftCard, error) {
+	var cards []models.GiftCard
+	query := database.DB.Model(&models.GiftCard{})
+
+	if active == "true" || active == "false" {
+		query = query.Where("active = ?", active == "true")
+	}
+
+	if err := query.Count(&pagination.TotalRows).Error; err != nil {
+		return nil, err
+	}
+
+	err := query.Order("id desc").Limit(pagination.GetLimit()).Offset(pagination.GetOffset()).Find(&cards).Error
+	return cards, err
+}
@@ -0,0 +1,43 @@
+package repository
+
+import (
+	"time"
+
+	"smart-choice/database"
+	"smart-choice/models"
+
+	"gorm.io/gorm"
+)
+
+func GetLoyaltyAccount(db *gorm.DB, userID uint) (models.LoyaltyAccount, error) {
+	var account models.LoyaltyAccount
+	err := db.Where("user_id = ?", userID).First(&account).Error
+	return account, err
+}
+
+func GetLoyaltyEntries(userID uint) ([]models.LoyaltyEntry, error) {
+	var entries []models.LoyaltyEntry
+	err := database.DB.Where("user_id = ?", userID).Order("id desc").Find(&entries).Error
+	return entries, err
+}
+
+func GetExpiredLoyaltyEntries(now time.Time, limit int) ([]models.LoyaltyEntry, error) {
+	var entries []models.LoyaltyEntry
+	err := database.DB.Where("remaining > 0 AND expires_at <= ?", now).
+		Order("expires_at asc").Limit(limit).Find(&entries).Error
+	return entries, err
+}
+
+func GetLoyaltyRules() ([]models.LoyaltyRule, error) {
+	var rules []models.LoyaltyRule
+	err := database.DB.Order("id desc").Find(&rules).Error
+	return rules, err
+}
+
+// GetActiveLoyaltyRules loads the enabled rules. Date windows are checked by
+// the caller.
+func GetActiveLoyaltyRules(db *gorm.DB) ([]models.LoyaltyRule, error) {
+	var rules []models.LoyaltyRule
+	err := db.Where("active = ?", true).Find(&rules).Error
+	return rules, err
+}
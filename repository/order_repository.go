@@ -7,16 +7,39 @@ import (
 	"smart-choice/models"
 )
 
+// GetTotalSales returns net sales for the period: order totals minus the
+// refunds settled in the same period.
 func GetTotalSales(start, end time.Time) (float64, error) {
 	var total float64
-	err := database.DB.Model(&models.Order{}).Where("created_at BETWEEN ? AND ?", start, end).Select("sum(total)").Row().Scan(&total)
-	return total, err
+	err := database.DB.Model(&models.Order{}).Where("created_at BETWEEN ? AND ?", start, end).Select("coalesce(sum(total), 0)").Row().Scan(&total)
+	if err != nil {
+		return 0, err
+	}
+
+	refunded, err := GetRefundedTotal(start, end)
+	if err != nil {
+		return 0, err
+	}
+
+	return total - refunded, nil
 }
 
 func CreateOrder(order *models.Order) error {
 	return database.DB.Create(order).Error
 }
 
+func GetOrdersByUserID(userID uint) ([]models.Order, error) {
+	var orders []models.Order
+	err := database.DB.Preload("OrderItems.Product").Where("user_id = ?", userID).Order("id desc").Find(&orders).Error
+	return orders, err
+}
+
+func GetUserOrder(userID, orderID uint) (models.Order, error) {
+	var order models.Order
+	err := database.DB.Preload("OrderItems.Product").Preload("Promotions").Where("user_id = ?", userID).First(&order, orderID).Error
+	return order, err
+}
+
 func UpdateOrderStatus(orderID uint, status string) error {
 	return database.DB.Model(&models.Order{}).Where("id = ?", orderID).Update("status", status).Error
 }
@@ -0,0 +1,24 @@
+package repository
+
+import (
+	"smart-choice/database"
+	"smart-choice/models"
+)
+
+func GetPaymentsByOrderID(orderID uint) ([]models.Payment, error) {
+	var payments []models.Payment
+	err := database.DB.Where("order_id = ?", orderID).Order("id asc").Find(&payments).Error
+	return payments, err
+}
+
+func GetPaymentByID(id uint) (models.Payment, error) {
+	var payment models.Payment
+	err := database.DB.First(&payment, id).Error
+	return payment, err
+}
+
+func GetPaymentByReference(provider, reference string) (models.Payment, error) {
+	var payment models.Payment
+	err := database.DB.Where("provider = ? AND provider_reference = ?", provider, reference).First(&payment).Error
+	return payment, err
+}
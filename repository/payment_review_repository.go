@@ -0,0 +1,16 @@
+package repository
+
+import (
+	"smart-choice/database"
+	"smart-choice/models"
+)
+
+func GetPaymentReviews(includeResolved bool) ([]models.PaymentReview, error) {
+	var reviews []models.PaymentReview
+	query := database.DB.Model(&models.PaymentReview{})
+	if !includeResolved {
+		query = query.Where("resolved_at IS NULL")
+	}
+	err := query.Order("id asc").Find(&reviews).Error
+	return reviews, err
+}
@@ -0,0 +1,80 @@
+package repository
+
+import (
+	"time"
+
+	"smart-choice/database"
+	"smart-choice/models"
+
+	"gorm.io/gorm"
+)
+
+func CreatePrivacyRequest(request *models.PrivacyRequest) error {
+	return database.DB.Create(request).Error
+}
+
+func UpdatePrivacyRequest(request *models.PrivacyRequest) error {
+	return database.DB.Save(request).Error
+}
+
+func GetPrivacyRequestByID(id uint) (models.PrivacyRequest, error) {
+	var request models.PrivacyRequest
+	err := database.DB.First(&request, id).Error
+	return request, err
+}
+
+func GetUserPrivacyRequest(userID, id uint) (models.PrivacyRequest, error) {
+	var request models.PrivacyRequest
+	err := database.DB.Where("user_id = ?", userID).First(&request, id).Error
+	return request, err
+}
+
+func GetActivityLogsByUserID(userID uint) ([]models.ActivityLog, error) {
+	var logs []models.ActivityLog
+	err := database.DB.Where("user_id = ?", userID).Order("id asc").Find(&logs).Error
+	return logs, err
+}
+
+// GetExpiredDataExports returns completed exports finished before the given
+// time whose archive is still on disk.
+func GetExpiredDataExports(before time.Time) ([]models.PrivacyRequest, error) {
+	var requests []models.PrivacyRequest
+	err := database.DB.
+		Where("type = ? AND status = ? AND file_path <> '' AND completed_at < ?",
+			models.PrivacyRequestExport, models.PrivacyStatusCompleted, before).
+		Order("id asc").
+		Find(&requests).Error
+	return requests, err
+}
+
+// userOrderIDs selects the IDs of the user's orders, for the ledgers that
+// only reference the order.
+func userOrderIDs(userID uint) *gorm.DB {
+	return database.DB.Model(&models.Order{}).Select("id").Where("user_id = ?", userID)
+}
+
+func GetPaymentsByUserID(userID uint) ([]models.Payment, error) {
+	var payments []models.Payment
+	err := database.DB.Where("order_id IN (?)", userOrderIDs(userID)).Order("id asc").Find(&payments).Error
+	return payments, err
+}
+
+func GetRefundsByUserID(userID uint) ([]models.Refund, error) {
+	var refunds []models.Refund
+	err := database.DB.Preload("Items").Where("order_id IN (?)", userOrderIDs(userID)).Order("id asc").Find(&refunds).Error
+	return refunds, err
+}
+
+// GetGiftCardTransactionsByUserID returns the gift card movements on the
+// user's orders. Gift cards themselves are bearer instruments with no owner.
+func GetGiftCardTransactionsByUserID(userID uint) ([]models.GiftCardTransaction, error) {
+	var transactions []models.GiftCardTransaction
+	err := database.DB.Where("order_id IN (?)", userOrderIDs(userID)).Order("id asc").Find(&transactions).Error
+	return transactions, err
+}
+
+func GetCouponRedemptionsByUserID(userID uint) ([]models.CouponRedemption, error) {
+	var redemptions []models.CouponRedemption
+	err := database.DB.Where("user_id = ?", userID).Order("id asc").Find(&redemptions).Error
+	return redemptions, err
+}
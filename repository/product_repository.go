@@ -22,7 +22,7 @@ func GetProducts(pagination *utils.Pagination) ([]models.Product, error) {
 	return products, result.Error
 }
 
-func GetProductsWithFilters(pagination *utils.Pagination, name, minPrice, maxPrice, inStock string) ([]models.Product, error) {
+func GetProductsWithFilters(pagination *utils.Pagination, name, minPrice, maxPrice, inStock, category string) ([]models.Product, error) {
 	var products []models.Product
 	query := database.DB.Model(&models.Product{})
 
@@ -46,7 +46,18 @@ func GetProductsWithFilters(pagination *utils.Pagination, name, minPrice, maxPri
 		query = query.Where("stock > 0")
 	}
 
+	if category != "" {
+		query = query.Where("LOWER(category) = LOWER(?)", category)
+	}
+
 	offset := (pagination.Page - 1) * pagination.Limit
 	err := query.Limit(pagination.Limit).Offset(offset).Order(pagination.Sort).Find(&products).Error
 	return products, err
 }
+
+// GetLowStockProducts returns products whose stock is below their limit.
+func GetLowStockProducts() ([]models.Product, error) {
+	var products []models.Product
+	err := database.DB.Where("stock < stock_limit").Order("stock asc, id asc").Find(&products).Error
+	return products, err
+}
@@ -0,0 +1,39 @@
+package repository
+
+import (
+	"smart-choice/database"
+	"smart-choice/models"
+	"smart-choice/utils"
+
+	"gorm.io/gorm"
+)
+
+func GetPromotions(pagination *utils.Pagination, active string) ([]models.Promotion, error) {
+	var promotions []models.Promotion
+	query := database.DB.Model(&models.Promotion{})
+
+	if active == "true" || active == "false" {
+		query = query.Where("active = ?", active == "true")
+	}
+
+	if err := query.Count(&pagination.TotalRows).Error; err != nil {
+		return nil, err
+	}
+
+	err := query.Order("priority desc, id asc").Limit(pagination.GetLimit()).Offset(pagination.GetOffset()).Find(&promotions).Error
+	return promotions, err
+}
+
+func GetPromotionByID(id uint) (models.Promotion, error) {
+	var promotion models.Promotion
+	err := database.DB.First(&promotion, id).Error
+	return promotion, err
+}
+
+// GetActivePromotions loads the enabled promotions. Date windows are checked
+// by the caller against its own clock.
+func GetActivePromotions(db *gorm.DB) ([]models.Promotion, error) {
+	var promotions []models.Promotion
+	err := db.Where("active = ?", true).Order("priority desc, id asc").Find(&promotions).Error
+	return promotions, err
+}
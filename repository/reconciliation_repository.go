@@ -0,0 +1,53 @@
+package repository
+
+import (
+	"time"
+
+	"smart-choice/database"
+	"smart-choice/models"
+)
+
+func CreateReconciliationRun(run *models.ReconciliationRun) error {
+	return database.DB.Create(run).Error
+}
+
+func UpdateReconciliationRun(run *models.ReconciliationRun) error {
+	return database.DB.Omit("Items").Save(run).Error
+}
+
+func GetReconciliationRuns() ([]models.ReconciliationRun, error) {
+	var runs []models.ReconciliationRun
+	err := database.DB.Order("id desc").Find(&runs).Error
+	return runs, err
+}
+
+func GetReconciliationRun(id uint) (models.ReconciliationRun, error) {
+	var run models.ReconciliationRun
+	err := database.DB.First(&run, id).Error
+	return run, err
+}
+
+func GetReconciliationItems(runID uint, result string) ([]models.ReconciliationItem, error) {
+	var items []models.ReconciliationItem
+	query := database.DB.Where("run_id = ?", runID)
+	if result != "" {
+		query = query.Where("result = ?", result)
+	}
+	err := query.Order("line asc, id asc").Find(&items).Error
+	return items, err
+}
+
+func GetPaymentsByReferences(provider string, references []string) ([]models.Payment, error) {
+	var payments []models.Payment
+	err := database.DB.Where("provider = ? AND provider_reference IN ?", provider, references).Find(&payments).Error
+	return payments, err
+}
+
+// GetSettledPayments returns the provider's succeeded payments captured in
+// the period, which a complete settlement file must contain.
+func GetSettledPayments(provider string, start, end time.Time) ([]models.Payment, error) {
+	var payments []models.Payment
+	err := database.DB.Where("provider = ? AND status = ? AND captured_at BETWEEN ? AND ?",
+		provider, models.PaymentStatusSucceeded, start, end).Find(&payments).Error
+	return payments, err
+}
@@ -0,0 +1,23 @@
+package repository
+
+import (
+	"time"
+
+	"smart-choice/database"
+	"smart-choice/models"
+)
+
+func GetRefundsByOrderID(orderID uint) ([]models.Refund, error) {
+	var refunds []models.Refund
+	err := database.DB.Preload("Items").Where("order_id = ?", orderID).Order("id asc").Find(&refunds).Error
+	return refunds, err
+}
+
+// GetRefundedTotal sums the refunds that succeeded within the period.
+func GetRefundedTotal(start, end time.Time) (float64, error) {
+	var total float64
+	err := database.DB.Model(&models.Refund{}).
+		Where("status = ? AND created_at BETWEEN ? AND ?", models.RefundStatusSucceeded, start, end).
+		Select("coalesce(sum(amount), 0)").Row().Scan(&total)
+	return total, err
+}
@@ -0,0 +1,20 @@
+package repository
+
+import (
+	"smart-choice/database"
+	"smart-choice/models"
+
+	"gorm.io/gorm"
+)
+
+func GetStoreCreditAccount(db *gorm.DB, userID uint) (models.StoreCreditAccount, error) {
+	var account models.StoreCreditAccount
+	err := db.Where("user_id = ?", userID).First(&account).Error
+	return account, err
+}
+
+func GetStoreCreditEntries(userID uint) ([]models.StoreCreditEntry, error) {
+	var entries []models.StoreCreditEntry
+	err := database.DB.Where("user_id = ?", userID).Order("id desc").Find(&entries).Error
+	return entries, err
+}
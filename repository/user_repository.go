@@ -31,3 +31,10 @@ func CountNewUsers(start, end time.Time) (int64, error) {
 	err := database.DB.Model(&models.User{}).Where("created_at BETWEEN ? AND ?", start, end).Count(&count).Error
 	return count, err
 }
+
+// GetUserByTaxDocument looks a user up by "cpf" or "cnpj"
+func GetUserByTaxDocument(column, document string) (models.User, error) {
+	var user models.User
+	err := database.DB.Where(column+" = ?", document).First(&user).Error
+	return user, err
+}
@@ -0,0 +1,26 @@
+package repository
+
+import (
+	"smart-choice/database"
+	"smart-choice/models"
+	"smart-choice/utils"
+)
+
+func GetWebhookEvents(pagination *utils.Pagination, provider, status string) ([]models.WebhookEvent, error) {
+	var events []models.WebhookEvent
+	query := database.DB.Model(&models.WebhookEvent{})
+
+	if provider != "" {
+		query = query.Where("provider = ?", provider)
+	}
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	if err := query.Count(&pagination.TotalRows).Error; err != nil {
+		return nil, err
+	}
+
+	err := query.Order("id desc").Limit(pagination.GetLimit()).Offset(pagination.GetOffset()).Find(&events).Error
+	return events, err
+}
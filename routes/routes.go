@@ -38,6 +38,8 @@ func SetupRoutes(r *gin.Engine) {
 	webhooks := r.Group("/webhooks")
 	{
 		webhooks.POST("/payment", controllers.PaymentWebhook)
+		webhooks.POST("/pix", controllers.PixWebhook)
+		webhooks.POST("/boleto", controllers.BoletoWebhook)
 	}
 
 	seo := r.Group("/seo")
@@ -58,6 +60,7 @@ func SetupRoutes(r *gin.Engine) {
 
 	api := r.Group("/api")
 	api.Use(middlewares.AuthMiddleware())
+	api.Use(middlewares.IdempotencyMiddleware())
 	{
 		api.GET("/ping", func(c *gin.Context) {
 			c.JSON(200, gin.H{"message": "pong"})
@@ -67,16 +70,107 @@ func SetupRoutes(r *gin.Engine) {
 		{
 			products.GET("/", controllers.GetProducts)
 			products.GET("/:id", controllers.GetProduct)
+			products.GET("/:id/installments", controllers.GetProductInstallments)
 			products.POST("/", middlewares.AdminMiddleware(), controllers.CreateProduct)
 			products.PUT("/:id", middlewares.AdminMiddleware(), controllers.UpdateProduct)
 			products.DELETE("/:id", middlewares.AdminMiddleware(), controllers.DeleteProduct)
 		}
 
+		me := api.Group("/me")
+		{
+			me.GET("", controllers.GetProfile)
+			me.PUT("/document", controllers.UpdateTaxDocument)
+			me.DELETE("", controllers.EraseAccount)
+
+			me.POST("/data-export", controllers.RequestDataExport)
+			me.GET("/data-export/:id", controllers.GetDataExport)
+			me.GET("/data-export/:id/download", controllers.DownloadDataExport)
+
+			me.GET("/store-credit", controllers.GetStoreCredit)
+			me.GET("/loyalty", controllers.GetLoyalty)
+
+			addresses := me.Group("/addresses")
+			{
+				addresses.GET("/", controllers.ListAddresses)
+				addresses.GET("/:id", controllers.GetAddress)
+				addresses.POST("/", controllers.CreateAddress)
+				addresses.PUT("/:id", controllers.UpdateAddress)
+				addresses.DELETE("/:id", controllers.DeleteAddress)
+			}
+		}
+
+		orders := api.Group("/orders")
+		{
+			orders.GET("/", controllers.ListOrders)
+			orders.GET("/:id", controllers.GetOrder)
+			orders.POST("/", controllers.CreateOrder)
+			orders.POST("/:id/pay", controllers.PayOrder)
+			orders.GET("/:id/payments", controllers.ListOrderPayments)
+		}
+
 		coupons := api.Group("/coupons")
 		{
 			coupons.POST("/validate", controllers.ValidateCoupon)
 		}
 
+		api.POST("/cart/preview", controllers.PreviewCart)
+
+		api.GET("/gift-cards/:code/balance", controllers.GetGiftCardBalance)
+
+		admin := api.Group("/admin")
+		admin.Use(middlewares.AdminMiddleware())
+		{
+			admin.POST("/orders/:id/refunds", controllers.RefundOrder)
+			admin.GET("/orders/:id/refunds", controllers.ListOrderRefunds)
+			admin.POST("/orders/:id/ship", controllers.ShipOrder)
+			admin.POST("/orders/:id/deliver", controllers.DeliverOrder)
+
+			admin.GET("/webhooks/events", controllers.ListWebhookEvents)
+			admin.POST("/webhooks/events/:id/replay", controllers.ReplayWebhookEvent)
+
+			admin.GET("/payment-reviews", controllers.ListPaymentReviews)
+			admin.POST("/payment-reviews/:id/resolve", controllers.ResolvePaymentReview)
+
+			admin.POST("/reconciliations", controllers.CreateReconciliation)
+			admin.GET("/reconciliations", controllers.ListReconciliations)
+			admin.GET("/reconciliations/:id", controllers.GetReconciliation)
+			admin.GET("/reconciliations/:id/export", controllers.ExportReconciliation)
+
+			admin.GET("/coupons", controllers.ListCoupons)
+			admin.POST("/coupons", controllers.CreateCoupon)
+			admin.POST("/coupons/bulk", controllers.GenerateCoupons)
+			admin.GET("/coupons/export", controllers.ExportCoupons)
+			admin.GET("/coupons/:id", controllers.GetCoupon)
+			admin.PUT("/coupons/:id", controllers.UpdateCoupon)
+			admin.POST("/coupons/:id/deactivate", controllers.DeactivateCoupon)
+			admin.GET("/coupons/:id/usage", controllers.GetCouponUsage)
+
+			admin.GET("/promotions", controllers.ListPromotions)
+			admin.POST("/promotions", controllers.CreatePromotion)
+			admin.GET("/promotions/:id", controllers.GetPromotion)
+			admin.PUT("/promotions/:id", controllers.UpdatePromotion)
+			admin.DELETE("/promotions/:id", controllers.DeletePromotion)
+
+			admin.GET("/gift-cards", controllers.ListGiftCards)
+			admin.POST("/gift-cards", controllers.IssueGiftCard)
+			admin.POST("/gift-cards/:id/deactivate", controllers.DeactivateGiftCard)
+			admin.GET("/users/:id/store-credit", controllers.GetUserStoreCredit)
+			admin.POST("/users/:id/store-credit", controllers.AdjustStoreCredit)
+
+			admin.GET("/loyalty/rules", controllers.ListLoyaltyRules)
+			admin.POST("/loyalty/rules", controllers.CreateLoyaltyRule)
+			admin.DELETE("/loyalty/rules/:id", controllers.DeleteLoyaltyRule)
+
+			admin.GET("/jobs", controllers.ListJobs)
+			admin.GET("/jobs/stats", controllers.GetJobStats)
+			admin.POST("/jobs/dead/retry", controllers.RetryDeadJobs)
+			admin.DELETE("/jobs/dead", controllers.DiscardDeadJobs)
+			admin.POST("/jobs/dead/:id/retry", controllers.RetryDeadJob)
+			admin.DELETE("/jobs/dead/:id", controllers.DiscardDeadJob)
+			admin.POST("/jobs/types/:type/pause", controllers.PauseJobType)
+			admin.POST("/jobs/types/:type/resume", controllers.ResumeJobType)
+		}
+
 		dashboard := api.Group("/dashboard")
 		dashboard.Use(middlewares.AdminMiddleware())
 		{
@@ -0,0 +1,122 @@
+package services
+
+import (
+	"errors"
+	"strings"
+
+	"smart-choice/database"
+	"smart-choice/models"
+	"smart-choice/repository"
+	"smart-choice/utils"
+
+	"gorm.io/gorm"
+)
+
+var ErrAddressNotFound = errors.New("address not found")
+
+func ListAddresses(userID uint) ([]models.Address, error) {
+	return repository.GetAddressesByUserID(userID)
+}
+
+func GetAddress(userID, addressID uint) (*models.Address, error) {
+	address, err := repository.GetUserAddress(userID, addressID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrAddressNotFound
+		}
+		return nil, err
+	}
+	return &address, nil
+}
+
+// CreateAddress stores a new address for the user. The first address of a
+// user becomes both the default shipping and billing address.
+func CreateAddress(userID uint, address *models.Address) error {
+	address.UserID = userID
+	normalizeAddress(address)
+
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		count, err := repository.CountUserAddresses(tx, userID)
+		if err != nil {
+			return err
+		}
+
+		if count == 0 {
+			address.IsDefaultShipping = true
+			address.IsDefaultBilling = true
+		}
+
+		if err := tx.Create(address).Error; err != nil {
+			return err
+		}
+
+		return applyDefaultFlags(tx, address)
+	})
+}
+
+func UpdateAddress(userID, addressID uint, changes models.Address) (*models.Address, error) {
+	address, err := GetAddress(userID, addressID)
+	if err != nil {
+		return nil, err
+	}
+
+	address.Label = changes.Label
+	address.RecipientName = changes.RecipientName
+	address.CEP = changes.CEP
+	address.Logradouro = changes.Logradouro
+	address.Numero = changes.Numero
+	address.Complemento = changes.Complemento
+	address.Bairro = changes.Bairro
+	address.Cidade = changes.Cidade
+	address.UF = changes.UF
+	address.IsDefaultShipping = changes.IsDefaultShipping
+	address.IsDefaultBilling = changes.IsDefaultBilling
+	normalizeAddress(address)
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(address).Error; err != nil {
+			return err
+		}
+		return applyDefaultFlags(tx, address)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return address, nil
+}
+
+func DeleteAddress(userID, addressID uint) error {
+	if _, err := GetAddress(userID, addressID); err != nil {
+		return err
+	}
+	return repository.DeleteUserAddress(userID, addressID)
+}
+
+// applyDefaultFlags keeps at most one default shipping and one default
+// billing address per user.
+func applyDefaultFlags(tx *gorm.DB, address *models.Address) error {
+	if address.IsDefaultShipping {
+		if err := repository.ClearDefaultAddressFlags(tx, address.UserID, address.ID, "is_default_shipping"); err != nil {
+			return err
+		}
+	}
+
+	if address.IsDefaultBilling {
+		if err := repository.ClearDefaultAddressFlags(tx, address.UserID, address.ID, "is_default_billing"); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func normalizeAddress(address *models.Address) {
+	address.CEP = utils.NormalizeCEP(address.CEP)
+	address.UF = strings.ToUpper(strings.TrimSpace(address.UF))
+	address.Logradouro = strings.TrimSpace(address.Logradouro)
+	address.Numero = strings.TrimSpace(address.Numero)
+	address.Complemento = strings.TrimSpace(address.Complemento)
+	address.Bairro = strings.TrimSpace(address.Bairro)
+	address.Cidade = strings.TrimSpace(address.Cidade)
+}
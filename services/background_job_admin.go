@@ -0,0 +1,366 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/rs/zerolog/log"
+)
+
+// reviveScript puts a dead job back in the ready list if it is still in
+// the dead letter queue.
+var reviveScript = redis.NewScript(`
+if redis.call('LREM', KEYS[1], 1, ARGV[1]) > 0 then
+	redis.call('HSET', KEYS[2], ARGV[2], ARGV[3])
+	redis.call('LPUSH', KEYS[3], ARGV[2])
+	return 1
+end
+return 0`)
+
+// deadEntry is a dead letter queue element with its raw value, which is
+// what LREM needs to remove it.
+type deadEntry struct {
+	raw  string
+	dead DeadJob
+}
+
+func newJobInfo(job *Job, state string) JobInfo {
+	return JobInfo{
+		ID:          job.ID,
+		Type:        job.Type,
+		Queue:       job.Queue,
+		State:       state,
+		Attempts:    job.Attempts,
+		MaxAttempts: job.MaxAttempts,
+		LastError:   job.LastError,
+		CreatedAt:   job.CreatedAt,
+		Payload:     JobPayloadPreview(job.Payload),
+	}
+}
+
+func (q *RedisJobQueue) ListJobs(ctx context.Context, state string, offset, limit int) ([]JobInfo, int64, error) {
+	switch state {
+	case JobStateQueued:
+		return q.listQueued(ctx, offset, limit)
+	case JobStateProcessing:
+		return q.listProcessing(ctx, offset, limit)
+	case JobStateScheduled:
+		return q.listDelayed(ctx, jobScheduledKey, state, offset, limit)
+	case JobStateRetrying:
+		return q.listDelayed(ctx, jobRetriesKey, state, offset, limit)
+	case JobStateDead:
+		return q.listDead(ctx, offset, limit)
+	}
+	return nil, 0, ErrInvalidJobState
+}
+
+// listQueued lists ready jobs queue by queue, highest weight first, each in
+// the order they will run. Jobs are pushed at the head of a list and taken
+// from the tail.
+func (q *RedisJobQueue) listQueued(ctx context.Context, offset, limit int) ([]JobInfo, int64, error) {
+	var total int64
+	var ids []string
+
+	skip := int64(offset)
+	for _, queue := range q.config.Routing.Queues {
+		key := readyKey(queue.Name)
+		length, err := q.client.LLen(ctx, key).Result()
+		if err != nil {
+			return nil, 0, err
+		}
+		total += length
+
+		want := int64(limit - len(ids))
+		if want <= 0 || skip >= length {
+			skip -= min(skip, length)
+			continue
+		}
+
+		stop := length - skip - 1
+		start := max(stop-want+1, 0)
+		skip = 0
+
+		page, err := q.client.LRange(ctx, key, start, stop).Result()
+		if err != nil {
+			return nil, 0, err
+		}
+		for i := len(page) - 1; i >= 0; i-- {
+			ids = append(ids, page[i])
+		}
+	}
+
+	infos, err := q.jobInfos(ctx, ids, JobStateQueued)
+	return infos, total, err
+}
+
+func (q *RedisJobQueue) listProcessing(ctx context.Context, offset, limit int) ([]JobInfo, int64, error) {
+	total, err := q.client.LLen(ctx, jobProcessingKey).Result()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	ids, err := q.client.LRange(ctx, jobProcessingKey, int64(offset), int64(offset+limit-1)).Result()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	infos, err := q.jobInfos(ctx, ids, JobStateProcessing)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	for i := range infos {
+		score, err := q.client.ZScore(ctx, jobLeasesKey, infos[i].ID).Result()
+		if err == redis.Nil {
+			continue
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+		leaseUntil := time.Unix(int64(score), 0)
+		infos[i].LeaseUntil = &leaseUntil
+	}
+	return infos, total, nil
+}
+
+func (q *RedisJobQueue) listDelayed(ctx context.Context, key, state string, offset, limit int) ([]JobInfo, int64, error) {
+	total, err := q.client.ZCard(ctx, key).Result()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	members, err := q.client.ZRangeWithScores(ctx, key, int64(offset), int64(offset+limit-1)).Result()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	ids := make([]string, 0, len(members))
+	runAt := make(map[string]time.Time, len(members))
+	for _, member := range members {
+		id, _ := member.Member.(string)
+		ids = append(ids, id)
+		runAt[id] = time.Unix(int64(member.Score), 0)
+	}
+
+	infos, err := q.jobInfos(ctx, ids, state)
+	if err != nil {
+		return nil, 0, err
+	}
+	for i := range infos {
+		at := runAt[infos[i].ID]
+		infos[i].RunAt = &at
+	}
+	return infos, total, nil
+}
+
+func (q *RedisJobQueue) listDead(ctx context.Context, offset, limit int) ([]JobInfo, int64, error) {
+	total, err := q.client.LLen(ctx, deadLetterKey).Result()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	raws, err := q.client.LRange(ctx, deadLetterKey, int64(offset), int64(offset+limit-1)).Result()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	infos := make([]JobInfo, 0, len(raws))
+	for _, raw := range raws {
+		var dead DeadJob
+		if err := json.Unmarshal([]byte(raw), &dead); err != nil {
+			log.Warn().Err(err).Msg("Skipping unreadable dead letter entry")
+			continue
+		}
+
+		info := newJobInfo(&dead.Job, JobStateDead)
+		info.LastError = dead.Reason
+		failedAt := dead.FailedAt
+		info.FailedAt = &failedAt
+		infos = append(infos, info)
+	}
+	return infos, total, nil
+}
+
+// jobInfos loads the bodies of the given jobs, skipping those already gone.
+func (q *RedisJobQueue) jobInfos(ctx context.Context, ids []string, state string) ([]JobInfo, error) {
+	if len(ids) == 0 {
+		return []JobInfo{}, nil
+	}
+
+	values, err := q.client.HMGet(ctx, jobDataKey, ids...).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	infos := make([]JobInfo, 0, len(values))
+	for _, value := range values {
+		data, ok := value.(string)
+		if !ok {
+			continue
+		}
+
+		var job Job
+		if err := json.Unmarshal([]byte(data), &job); err != nil {
+			return nil, err
+		}
+		infos = append(infos, newJobInfo(&job, state))
+	}
+	return infos, nil
+}
+
+func (q *RedisJobQueue) deadEntries(ctx context.Context) ([]deadEntry, error) {
+	raws, err := q.client.LRange(ctx, deadLetterKey, 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]deadEntry, 0, len(raws))
+	for _, raw := range raws {
+		var dead DeadJob
+		if err := json.Unmarshal([]byte(raw), &dead); err != nil {
+			continue
+		}
+		entries = append(entries, deadEntry{raw: raw, dead: dead})
+	}
+	return entries, nil
+}
+
+func (q *RedisJobQueue) findDeadJob(ctx context.Context, jobID string) (*deadEntry, error) {
+	entries, err := q.deadEntries(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for i := range entries {
+		if entries[i].dead.Job.ID == jobID {
+			return &entries[i], nil
+		}
+	}
+	return nil, ErrJobNotFound
+}
+
+// revive puts a dead job back in its ready queue with fresh attempts. It
+// reports false when the entry was already taken out of the queue.
+func (q *RedisJobQueue) revive(ctx context.Context, entry *deadEntry) (bool, error) {
+	job := entry.dead.Job
+	job.Attempts = 0
+	job.LastError = entry.dead.Reason
+	job.Queue = q.config.Routing.queueOf(&job)
+
+	jobJSON, err := json.Marshal(job)
+	if err != nil {
+		return false, err
+	}
+
+	moved, err := reviveScript.Run(ctx, q.client, []string{deadLetterKey, jobDataKey, readyKey(job.Queue)},
+		entry.raw, job.ID, jobJSON).Int()
+	return moved == 1, err
+}
+
+func (q *RedisJobQueue) RetryDeadJob(ctx context.Context, jobID string) error {
+	entry, err := q.findDeadJob(ctx, jobID)
+	if err != nil {
+		return err
+	}
+
+	revived, err := q.revive(ctx, entry)
+	if err != nil {
+		return err
+	}
+	if !revived {
+		return ErrJobNotFound
+	}
+	return nil
+}
+
+func (q *RedisJobQueue) DiscardDeadJob(ctx context.Context, jobID string) error {
+	entry, err := q.findDeadJob(ctx, jobID)
+	if err != nil {
+		return err
+	}
+
+	removed, err := q.client.LRem(ctx, deadLetterKey, 1, entry.raw).Result()
+	if err != nil {
+		return err
+	}
+	if removed == 0 {
+		return ErrJobNotFound
+	}
+	return nil
+}
+
+func (q *RedisJobQueue) RetryDeadJobs(ctx context.Context, jobType JobType) (int, error) {
+	entries, err := q.deadEntries(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for i := range entries {
+		if jobType != "" && entries[i].dead.Job.Type != jobType {
+			continue
+		}
+		revived, err := q.revive(ctx, &entries[i])
+		if err != nil {
+			return count, err
+		}
+		if revived {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (q *RedisJobQueue) DiscardDeadJobs(ctx context.Context, jobType JobType) (int, error) {
+	if jobType == "" {
+		count, err := q.client.LLen(ctx, deadLetterKey).Result()
+		if err != nil {
+			return 0, err
+		}
+		return int(count), q.client.Del(ctx, deadLetterKey).Err()
+	}
+
+	entries, err := q.deadEntries(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for i := range entries {
+		if entries[i].dead.Job.Type != jobType {
+			continue
+		}
+		removed, err := q.client.LRem(ctx, deadLetterKey, 1, entries[i].raw).Result()
+		if err != nil {
+			return count, err
+		}
+		count += int(removed)
+	}
+	return count, nil
+}
+
+// PauseJobType stops workers on every instance from running jobs of the
+// type; they are set aside and looked at again every PauseRecheck.
+func (q *RedisJobQueue) PauseJobType(ctx context.Context, jobType JobType) error {
+	return q.client.SAdd(ctx, jobPausedKey, string(jobType)).Err()
+}
+
+func (q *RedisJobQueue) ResumeJobType(ctx context.Context, jobType JobType) error {
+	return q.client.SRem(ctx, jobPausedKey, string(jobType)).Err()
+}
+
+func (q *RedisJobQueue) PausedJobTypes(ctx context.Context) ([]JobType, error) {
+	members, err := q.client.SMembers(ctx, jobPausedKey).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(members)
+	types := make([]JobType, len(members))
+	for i, member := range members {
+		types[i] = JobType(member)
+	}
+	return types, nil
+}
@@ -3,9 +3,14 @@ package services
 import (
 	"context"
 	"encoding/json"
+	"strconv"
 	"time"
 
+	"smart-choice/metrics"
+
 	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
 )
 
 type JobType string
@@ -14,18 +19,29 @@ const (
 	JobTypeEmailSend      JobType = "email_send"
 	JobTypeReportGenerate JobType = "report_generate"
 	JobTypeDataCleanup    JobType = "data_cleanup"
+	JobTypeDataExport     JobType = "data_export"
+	JobTypeReconciliation JobType = "reconciliation"
 )
 
 type Job struct {
 	ID          string    `json:"id"`
 	Type        JobType   `json:"type"`
+	Queue       string    `json:"queue,omitempty"`
 	Payload     []byte    `json:"payload"`
 	Attempts    int       `json:"attempts"`
 	MaxAttempts int       `json:"max_attempts"`
+	LastError   string    `json:"last_error,omitempty"`
 	CreatedAt   time.Time `json:"created_at"`
 	ScheduledAt time.Time `json:"scheduled_at"`
 }
 
+// DeadJob is a job that failed its last attempt, kept for analysis.
+type DeadJob struct {
+	Job      Job       `json:"job"`
+	Reason   string    `json:"reason"`
+	FailedAt time.Time `json:"failed_at"`
+}
+
 type JobQueue interface {
 	Enqueue(ctx context.Context, job Job) error
 	Dequeue(ctx context.Context) (*Job, error)
@@ -34,15 +50,185 @@ type JobQueue interface {
 	GetStats(ctx context.Context) (map[string]int, error)
 }
 
+// JobReaper is implemented by queues that need periodic maintenance, such
+// as re-queuing jobs whose lease expired.
+type JobReaper interface {
+	Reap(ctx context.Context) error
+}
+
+// JobPromoter is implemented by queues that hold delayed jobs and need to
+// be told to move the due ones to the ready queue.
+type JobPromoter interface {
+	PromoteDue(ctx context.Context) error
+}
+
+// JobReleaser is implemented by queues that can hand a processing job back
+// as if it had never been taken, for jobs interrupted by a shutdown.
+type JobReleaser interface {
+	// Release re-queues the job and gives back the attempt its dequeue
+	// counted.
+	Release(ctx context.Context, jobID string) error
+}
+
+// MultiJobQueue is implemented by queues split into named queues, so that
+// workers can choose which one to take from.
+type MultiJobQueue interface {
+	Queues() []JobQueueSpec
+	// DequeueFrom takes the next job of the named queue without waiting;
+	// it returns nil when that queue is empty.
+	DequeueFrom(ctx context.Context, queue string) (*Job, error)
+}
+
+type JobQueueConfig struct {
+	MaxAttempts int
+	// VisibilityTimeout is how long a dequeued job stays leased to its
+	// worker before the reaper hands it to another one. It must be longer
+	// than JOB_TIMEOUT.
+	VisibilityTimeout time.Duration
+	RetryBackoff      time.Duration
+	MaxRetryBackoff   time.Duration
+	// PauseRecheck is how long a job of a paused type waits before it is
+	// looked at again.
+	PauseRecheck time.Duration
+	// PollInterval is how long Dequeue waits when every queue is empty.
+	PollInterval time.Duration
+	Routing      JobRouting
+}
+
+func DefaultJobQueueConfig() JobQueueConfig {
+	return JobQueueConfig{
+		MaxAttempts:       getEnvInt("JOB_MAX_ATTEMPTS", 3),
+		VisibilityTimeout: GetEnvDuration("JOB_VISIBILITY_TIMEOUT", 10*time.Minute),
+		RetryBackoff:      GetEnvDuration("JOB_RETRY_BACKOFF", 10*time.Second),
+		MaxRetryBackoff:   GetEnvDuration("JOB_RETRY_MAX_BACKOFF", 10*time.Minute),
+		PauseRecheck:      GetEnvDuration("JOB_PAUSE_RECHECK", 30*time.Second),
+		PollInterval:      GetEnvDuration("JOB_POLL_INTERVAL", 500*time.Millisecond),
+	}
+}
+
+// RetryBackoff returns the delay before retrying a job that failed its
+// given attempt: base doubled for every previous attempt, capped at max.
+func RetryBackoff(attempt int, base, max time.Duration) time.Duration {
+	if attempt < 1 {
+		attempt = 1
+	}
+
+	delay := base
+	for i := 1; i < attempt && delay < max; i++ {
+		delay *= 2
+	}
+	if max > 0 && delay > max {
+		delay = max
+	}
+	return delay
+}
+
+// Redis keys. The ready lists (one per named queue) and the processing list
+// hold job IDs; job bodies live in the jobs hash so they survive moving
+// between lists. Delayed jobs and retries wait in sorted sets scored by the
+// time they are due.
+const (
+	jobQueueKey      = "job_queue"
+	jobProcessingKey = "processing_jobs"
+	jobLeasesKey     = "job_leases"
+	jobRetriesKey    = "job_retries"
+	jobScheduledKey  = "scheduled_jobs"
+	jobDataKey       = "jobs"
+	deadLetterKey    = "dead_letter_queue"
+	jobPausedKey     = "paused_job_types"
+
+	jobReapBatch = 100
+)
+
+var (
+	// requeueScript moves a job back to the ready list if it is still
+	// in the processing list.
+	requeueScript = redis.NewScript(`
+if redis.call('LREM', KEYS[1], 0, ARGV[1]) > 0 then
+	redis.call('LPUSH', KEYS[2], ARGV[1])
+	return 1
+end
+return 0`)
+
+	// retryScript schedules a processing job for a later attempt.
+	retryScript = redis.NewScript(`
+if redis.call('LREM', KEYS[1], 0, ARGV[1]) > 0 then
+	redis.call('ZREM', KEYS[2], ARGV[1])
+	redis.call('HSET', KEYS[4], ARGV[1], ARGV[3])
+	redis.call('ZADD', KEYS[3], ARGV[2], ARGV[1])
+	return 1
+end
+return 0`)
+
+	// deadLetterScript moves a processing job to the dead letter queue.
+	deadLetterScript = redis.NewScript(`
+if redis.call('LREM', KEYS[1], 0, ARGV[1]) > 0 then
+	redis.call('ZREM', KEYS[2], ARGV[1])
+	redis.call('HDEL', KEYS[3], ARGV[1])
+	redis.call('LPUSH', KEYS[4], ARGV[2])
+	return 1
+end
+return 0`)
+
+	// releaseScript moves a processing job back to the ready list and
+	// stores its body with the attempt given back.
+	releaseScript = redis.NewScript(`
+if redis.call('LREM', KEYS[1], 0, ARGV[1]) > 0 then
+	redis.call('ZREM', KEYS[2], ARGV[1])
+	redis.call('HSET', KEYS[3], ARGV[1], ARGV[2])
+	redis.call('LPUSH', KEYS[4], ARGV[1])
+	return 1
+end
+return 0`)
+
+	// deferScript moves a processing job to the scheduled set without
+	// counting an attempt.
+	deferScript = redis.NewScript(`
+if redis.call('LREM', KEYS[1], 0, ARGV[1]) > 0 then
+	redis.call('ZADD', KEYS[2], ARGV[2], ARGV[1])
+	return 1
+end
+return 0`)
+
+	// promoteScript moves a due job from a sorted set to the ready list.
+	promoteScript = redis.NewScript(`
+if redis.call('ZREM', KEYS[1], ARGV[1]) > 0 then
+	redis.call('LPUSH', KEYS[2], ARGV[1])
+	return 1
+end
+return 0`)
+)
+
 type RedisJobQueue struct {
 	client *redis.Client
+	config JobQueueConfig
+}
+
+// readyKey returns the ready list of a named queue. The default queue
+// keeps the original key so jobs queued before named queues still run.
+func readyKey(queue string) string {
+	if queue == DefaultJobQueueName {
+		return jobQueueKey
+	}
+	return jobQueueKey + ":" + queue
 }
 
 func NewRedisJobQueue(addr, password string) (*RedisJobQueue, error) {
+	routing, err := DefaultJobRouting()
+	if err != nil {
+		return nil, err
+	}
+
+	config := DefaultJobQueueConfig()
+	config.Routing = routing
+	return NewRedisJobQueueWithConfig(addr, password, config)
+}
+
+func NewRedisJobQueueWithConfig(addr, password string, config JobQueueConfig) (*RedisJobQueue, error) {
 	rdb := redis.NewClient(&redis.Options{
 		Addr:     addr,
 		Password: password,
-		DB:       1, // Use DB 1 for job queue
+		DB:       getEnvInt("JOB_QUEUE_DB", 1),
 		PoolSize: 10,
 	})
 
@@ -52,94 +238,399 @@ func NewRedisJobQueue(addr, password string) (*RedisJobQueue, error) {
 		return nil, err
 	}
 
-	return &RedisJobQueue{client: rdb}, nil
+	if config.MaxAttempts <= 0 {
+		config.MaxAttempts = 1
+	}
+	if config.PauseRecheck <= 0 {
+		config.PauseRecheck = 30 * time.Second
+	}
+	if config.PollInterval <= 0 {
+		config.PollInterval = 500 * time.Millisecond
+	}
+	if len(config.Routing.Queues) == 0 {
+		config.Routing = builtinJobRouting()
+	}
+
+	return &RedisJobQueue{client: rdb, config: config}, nil
 }
 
+func (q *RedisJobQueue) Queues() []JobQueueSpec {
+	return append([]JobQueueSpec(nil), q.config.Routing.Queues...)
+}
+
+// Enqueue adds a job to the ready list of the queue its type is routed to,
+// or to the scheduled set when its ScheduledAt is in the future.
 func (q *RedisJobQueue) Enqueue(ctx context.Context, job Job) error {
 	job.ID = generateJobID()
 	job.CreatedAt = time.Now()
 	job.Attempts = 0
-	job.MaxAttempts = 3
+	job.Queue = q.config.Routing.queueOf(&job)
+	if job.MaxAttempts <= 0 {
+		job.MaxAttempts = q.config.MaxAttempts
+	}
 
 	jobJSON, err := json.Marshal(job)
 	if err != nil {
 		return err
 	}
 
-	// Add to queue list
-	return q.client.LPush(ctx, "job_queue", jobJSON).Err()
+	_, err = q.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, jobDataKey, job.ID, jobJSON)
+		if job.ScheduledAt.After(job.CreatedAt) {
+			pipe.ZAdd(ctx, jobScheduledKey, &redis.Z{Score: float64(job.ScheduledAt.Unix()), Member: job.ID})
+		} else {
+			pipe.LPush(ctx, readyKey(job.Queue), job.ID)
+		}
+		return nil
+	})
+	return err
 }
 
+// Dequeue takes the next job from the queues in priority order, waiting
+// PollInterval and returning nil when all of them are empty.
 func (q *RedisJobQueue) Dequeue(ctx context.Context) (*Job, error) {
-	result, err := q.client.BRPop(ctx, 10*time.Second, "job_queue").Result()
+	for _, queue := range q.config.Routing.Queues {
+		job, err := q.DequeueFrom(ctx, queue.Name)
+		if err != nil || job != nil {
+			return job, err
+		}
+	}
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-time.After(q.config.PollInterval):
+		return nil, nil
+	}
+}
+
+// DequeueFrom atomically moves the next job of a queue to the processing
+// list and leases it for VisibilityTimeout. A worker that dies mid-job
+// leaves the job there for the reaper to re-queue. Jobs of a paused type
+// are set aside for PauseRecheck and nil is returned.
+func (q *RedisJobQueue) DequeueFrom(ctx context.Context, queue string) (*Job, error) {
+	jobID, err := q.client.RPopLPush(ctx, readyKey(queue), jobProcessingKey).Result()
+	if err == redis.Nil {
+		return nil, nil
+	}
 	if err != nil {
 		return nil, err
 	}
 
-	if len(result) == 0 {
-		return nil, nil // No jobs available
+	job, err := q.loadJob(ctx, jobID)
+	if err == redis.Nil {
+		log.Warn().Str("job_id", jobID).Msg("Dropping job without a stored body")
+		return nil, q.client.LRem(ctx, jobProcessingKey, 0, jobID).Err()
+	}
+	if err != nil {
+		return nil, err
 	}
 
-	// BRPop returns [key, value], so we need the second element
-	jobData := result[1]
+	paused, err := q.client.SIsMember(ctx, jobPausedKey, string(job.Type)).Result()
+	if err != nil {
+		return nil, err
+	}
+	if paused {
+		recheckAt := time.Now().Add(q.config.PauseRecheck).Unix()
+		return nil, deferScript.Run(ctx, q.client, []string{jobProcessingKey, jobScheduledKey},
+			job.ID, strconv.FormatInt(recheckAt, 10)).Err()
+	}
 
-	var job Job
-	if err := json.Unmarshal([]byte(jobData), &job); err != nil {
+	job.Attempts++
+	jobJSON, err := json.Marshal(job)
+	if err != nil {
 		return nil, err
 	}
 
-	return &job, nil
+	leaseUntil := time.Now().Add(q.config.VisibilityTimeout)
+	_, err = q.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, jobDataKey, job.ID, jobJSON)
+		pipe.ZAdd(ctx, jobLeasesKey, &redis.Z{Score: float64(leaseUntil.Unix()), Member: job.ID})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return job, nil
 }
 
 func (q *RedisJobQueue) Complete(ctx context.Context, jobID string) error {
-	// Remove from processing queue
-	return q.client.LRem(ctx, "processing_jobs", 0, jobID).Err()
+	_, err := q.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.LRem(ctx, jobProcessingKey, 0, jobID)
+		pipe.ZRem(ctx, jobLeasesKey, jobID)
+		pipe.HDel(ctx, jobDataKey, jobID)
+		return nil
+	})
+	return err
 }
 
+// Fail schedules another attempt with exponential backoff, or moves the
+// whole job to the dead letter queue once it has used all its attempts.
 func (q *RedisJobQueue) Fail(ctx context.Context, jobID string, reason string) error {
-	// Move to dead letter queue for analysis
-	deadJob := map[string]interface{}{
-		"job_id":    jobID,
-		"reason":    reason,
-		"failed_at": time.Now(),
+	job, err := q.loadJob(ctx, jobID)
+	if err == redis.Nil {
+		return nil // Already completed or dead-lettered
+	}
+	if err != nil {
+		return err
+	}
+
+	job.LastError = reason
+	if job.Attempts >= job.MaxAttempts {
+		return q.deadLetter(ctx, job, reason)
+	}
+
+	jobJSON, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+
+	retryAt := time.Now().Add(RetryBackoff(job.Attempts, q.config.RetryBackoff, q.config.MaxRetryBackoff))
+	return retryScript.Run(ctx, q.client,
+		[]string{jobProcessingKey, jobLeasesKey, jobRetriesKey, jobDataKey},
+		job.ID, strconv.FormatInt(retryAt.Unix(), 10), jobJSON).Err()
+}
+
+// Release re-queues a processing job without counting the attempt it was
+// dequeued with.
+func (q *RedisJobQueue) Release(ctx context.Context, jobID string) error {
+	job, err := q.loadJob(ctx, jobID)
+	if err == redis.Nil {
+		return nil // Already completed or dead-lettered
+	}
+	if err != nil {
+		return err
+	}
+
+	if job.Attempts > 0 {
+		job.Attempts--
+	}
+	jobJSON, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+
+	queue := readyKey(q.config.Routing.queueOf(job))
+	return releaseScript.Run(ctx, q.client,
+		[]string{jobProcessingKey, jobLeasesKey, jobDataKey, queue},
+		job.ID, jobJSON).Err()
+}
+
+func (q *RedisJobQueue) deadLetter(ctx context.Context, job *Job, reason string) error {
+	deadJobJSON, err := json.Marshal(DeadJob{Job: *job, Reason: reason, FailedAt: time.Now()})
+	if err != nil {
+		return err
+	}
+
+	moved, err := deadLetterScript.Run(ctx, q.client,
+		[]string{jobProcessingKey, jobLeasesKey, jobDataKey, deadLetterKey},
+		job.ID, deadJobJSON).Int()
+	if err != nil {
+		return err
+	}
+	if moved == 1 {
+		metrics.JobsDeadLetteredTotal.WithLabelValues(string(job.Type)).Inc()
+		log.Warn().Str("job_id", job.ID).Str("job_type", string(job.Type)).Str("reason", reason).
+			Msg("Job moved to dead letter queue")
+	}
+	return nil
+}
+
+// PromoteDue moves delayed jobs and retries whose time has come to the
+// ready list.
+func (q *RedisJobQueue) PromoteDue(ctx context.Context) error {
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+
+	for _, key := range []string{jobScheduledKey, jobRetriesKey} {
+		due, err := q.dueMembers(ctx, key, now)
+		if err != nil {
+			return err
+		}
+		for _, jobID := range due {
+			job, err := q.loadJob(ctx, jobID)
+			if err == redis.Nil {
+				if err := q.client.ZRem(ctx, key, jobID).Err(); err != nil {
+					return err
+				}
+				continue
+			}
+			if err != nil {
+				return err
+			}
+
+			queue := readyKey(q.config.Routing.queueOf(job))
+			if err := promoteScript.Run(ctx, q.client, []string{key, queue}, jobID).Err(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Reap re-queues jobs whose lease expired, dead-lettering those out of
+// attempts.
+func (q *RedisJobQueue) Reap(ctx context.Context) error {
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+
+	expired, err := q.dueMembers(ctx, jobLeasesKey, now)
+	if err != nil {
+		return err
+	}
+	for _, jobID := range expired {
+		if err := q.reapExpired(ctx, jobID); err != nil {
+			return err
+		}
 	}
 
-	deadJobJSON, _ := json.Marshal(deadJob)
-	return q.client.LPush(ctx, "dead_letter_queue", deadJobJSON).Err()
+	return q.leaseOrphans(ctx)
 }
 
+func (q *RedisJobQueue) reapExpired(ctx context.Context, jobID string) error {
+	// Removing the lease claims the job for this reaper.
+	claimed, err := q.client.ZRem(ctx, jobLeasesKey, jobID).Result()
+	if err != nil || claimed == 0 {
+		return err
+	}
+
+	job, err := q.loadJob(ctx, jobID)
+	if err == redis.Nil {
+		return q.client.LRem(ctx, jobProcessingKey, 0, jobID).Err()
+	}
+	if err != nil {
+		return err
+	}
+
+	if job.Attempts >= job.MaxAttempts {
+		return q.deadLetter(ctx, job, "lease expired on the last attempt")
+	}
+
+	queue := readyKey(q.config.Routing.queueOf(job))
+	moved, err := requeueScript.Run(ctx, q.client, []string{jobProcessingKey, queue}, jobID).Int()
+	if err != nil {
+		return err
+	}
+	if moved == 1 {
+		log.Warn().Str("job_id", jobID).Str("job_type", string(job.Type)).Msg("Job lease expired, re-queued")
+	}
+	return nil
+}
+
+// leaseOrphans gives a lease to processing jobs that have none, which
+// happens when a worker dies between taking a job and leasing it.
+func (q *RedisJobQueue) leaseOrphans(ctx context.Context) error {
+	processing, err := q.client.LRange(ctx, jobProcessingKey, 0, -1).Result()
+	if err != nil {
+		return err
+	}
+
+	leaseUntil := float64(time.Now().Add(q.config.VisibilityTimeout).Unix())
+	for _, jobID := range processing {
+		if err := q.client.ZAddNX(ctx, jobLeasesKey, &redis.Z{Score: leaseUntil, Member: jobID}).Err(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (q *RedisJobQueue) dueMembers(ctx context.Context, key, now string) ([]string, error) {
+	return q.client.ZRangeByScore(ctx, key, &redis.ZRangeBy{
+		Min:   "-inf",
+		Max:   now,
+		Count: jobReapBatch,
+	}).Result()
+}
+
+func (q *RedisJobQueue) loadJob(ctx context.Context, jobID string) (*Job, error) {
+	data, err := q.client.HGet(ctx, jobDataKey, jobID).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	var job Job
+	if err := json.Unmarshal([]byte(data), &job); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// GetStats counts the jobs in each state; queue_length sums the ready lists
+// and queue_length:<name> gives each of them.
 func (q *RedisJobQueue) GetStats(ctx context.Context) (map[string]int, error) {
-	queueLen, err := q.client.LLen(ctx, "job_queue").Result()
+	stats := make(map[string]int)
+
+	var queueLen int64
+	for _, queue := range q.config.Routing.Queues {
+		length, err := q.client.LLen(ctx, readyKey(queue.Name)).Result()
+		if err != nil {
+			return nil, err
+		}
+		stats["queue_length:"+queue.Name] = int(length)
+		queueLen += length
+	}
+
+	processingLen, err := q.client.LLen(ctx, jobProcessingKey).Result()
 	if err != nil {
 		return nil, err
 	}
 
-	processingLen, err := q.client.LLen(ctx, "processing_jobs").Result()
+	retryLen, err := q.client.ZCard(ctx, jobRetriesKey).Result()
 	if err != nil {
 		return nil, err
 	}
 
-	deadLen, err := q.client.LLen(ctx, "dead_letter_queue").Result()
+	scheduledLen, err := q.client.ZCard(ctx, jobScheduledKey).Result()
 	if err != nil {
 		return nil, err
 	}
 
-	return map[string]int{
-		"queue_length":      int(queueLen),
-		"processing_length": int(processingLen),
-		"dead_letter_count": int(deadLen),
-	}, nil
+	deadLen, err := q.client.LLen(ctx, deadLetterKey).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	stats["queue_length"] = int(queueLen)
+	stats["processing_length"] = int(processingLen)
+	stats["retry_length"] = int(retryLen)
+	stats["scheduled_length"] = int(scheduledLen)
+	stats["dead_letter_count"] = int(deadLen)
+	return stats, nil
 }
 
-func generateJobID() string {
-	return time.Now().Format("20060102150405") + "-" + randomString(8)
+// ReapJobQueue runs the maintenance of the configured job queue, if it
+// needs any, and refreshes the queue size metrics.
+func ReapJobQueue(ctx context.Context) error {
+	jobQueue := GetServiceManager().GetJobQueue()
+	if jobQueue == nil {
+		return nil
+	}
+
+	if reaper, ok := jobQueue.(JobReaper); ok {
+		if err := reaper.Reap(ctx); err != nil {
+			return err
+		}
+	}
+
+	stats, err := jobQueue.GetStats(ctx)
+	if err != nil {
+		return err
+	}
+	for state, size := range stats {
+		metrics.JobQueueSize.WithLabelValues(state).Set(float64(size))
+	}
+	return nil
 }
 
-func randomString(length int) string {
-	const charset = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
-	b := make([]byte, length)
-	for i := range b {
-		b[i] = charset[time.Now().UnixNano()%int64(len(charset))]
+// PromoteJobQueue moves the due delayed jobs of the configured job queue,
+// if it holds any.
+func PromoteJobQueue(ctx context.Context) error {
+	promoter, ok := GetServiceManager().GetJobQueue().(JobPromoter)
+	if !ok {
+		return nil
 	}
-	return string(b)
+	return promoter.PromoteDue(ctx)
+}
+
+func generateJobID() string {
+	return time.Now().Format("20060102150405") + "-" + uuid.New().String()[:8]
 }
@@ -0,0 +1,259 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"time"
+
+	"smart-choice/models"
+	"smart-choice/repository"
+	"smart-choice/utils"
+
+	"github.com/boombuler/barcode"
+	"github.com/boombuler/barcode/twooffive"
+)
+
+const BoletoProviderName = "boleto"
+
+var (
+	ErrBoletoAmountTooLarge = errors.New("boleto amount exceeds 99,999,999.99")
+	ErrBoletoDueDate        = errors.New("boleto due date is outside the supported range")
+)
+
+// boletoFactorBase is day zero of the "fator de vencimento"; the factor
+// rolled over from 9999 back to 1000 on 2025-02-22.
+var boletoFactorBase = time.Date(1997, time.October, 7, 0, 0, 0, 0, time.UTC)
+
+// Boleto holds the data encoded in a FEBRABAN boleto bancário barcode.
+// The 25-digit free field follows the Banco do Brasil layout for 7-digit
+// agreements: six zeros, agreement, 10-digit sequence and wallet.
+type Boleto struct {
+	BankCode  string
+	Agreement string
+	Sequence  string
+	Wallet    string
+	Amount    float64
+	DueDate   time.Time
+}
+
+// NossoNumero is the bank's identifier of the boleto (agreement + sequence).
+func (b Boleto) NossoNumero() string {
+	return padDigits(b.Agreement, 7) + padDigits(b.Sequence, 10)
+}
+
+func (b Boleto) freeField() string {
+	return "000000" + b.NossoNumero() + padDigits(b.Wallet, 2)
+}
+
+// Barcode returns the 44-digit barcode content.
+func (b Boleto) Barcode() (string, error) {
+	if err := b.validate(); err != nil {
+		return "", err
+	}
+
+	cents := int64(math.Round(b.Amount * 100))
+	if cents > 9999999999 {
+		return "", ErrBoletoAmountTooLarge
+	}
+
+	factor, err := BoletoDueDateFactor(b.DueDate)
+	if err != nil {
+		return "", err
+	}
+
+	// Position 5 holds the general check digit, computed over the other 43.
+	withoutDV := fmt.Sprintf("%s9%04d%010d%s", padDigits(b.BankCode, 3), factor, cents, b.freeField())
+	dv := boletoBarcodeCheckDigit(withoutDV)
+
+	return withoutDV[:4] + fmt.Sprint(dv) + withoutDV[4:], nil
+}
+
+func (b Boleto) validate() error {
+	fields := []struct {
+		name, value string
+		size        int
+	}{
+		{"bank code", b.BankCode, 3},
+		{"agreement", b.Agreement, 7},
+		{"sequence", b.Sequence, 10},
+		{"wallet", b.Wallet, 2},
+	}
+
+	for _, field := range fields {
+		if field.value == "" || len(field.value) > field.size || utils.OnlyDigits(field.value) != field.value {
+			return fmt.Errorf("boleto %s must have up to %d digits", field.name, field.size)
+		}
+	}
+	return nil
+}
+
+func padDigits(digits string, size int) string {
+	for len(digits) < size {
+		digits = "0" + digits
+	}
+	return digits
+}
+
+// BoletoDigitableLine converts a 44-digit barcode to the formatted 47-digit
+// "linha digitável".
+func BoletoDigitableLine(barcode string) (string, error) {
+	if len(barcode) != 44 || utils.OnlyDigits(barcode) != barcode {
+		return "", fmt.Errorf("barcode must have 44 digits")
+	}
+
+	field1 := barcode[0:4] + barcode[19:24]
+	field2 := barcode[24:34]
+	field3 := barcode[34:44]
+
+	field1 += fmt.Sprint(mod10CheckDigit(field1))
+	field2 += fmt.Sprint(mod10CheckDigit(field2))
+	field3 += fmt.Sprint(mod10CheckDigit(field3))
+
+	return fmt.Sprintf("%s.%s %s.%s %s.%s %s %s",
+		field1[:5], field1[5:],
+		field2[:5], field2[5:],
+		field3[:5], field3[5:],
+		barcode[4:5],
+		barcode[5:19],
+	), nil
+}
+
+// BoletoDueDateFactor returns the 4-digit "fator de vencimento" for a date.
+func BoletoDueDateFactor(dueDate time.Time) (int, error) {
+	day := time.Date(dueDate.Year(), dueDate.Month(), dueDate.Day(), 0, 0, 0, 0, time.UTC)
+	days := int(day.Sub(boletoFactorBase).Hours() / 24)
+	if days < 1000 {
+		return 0, ErrBoletoDueDate
+	}
+	if days > 9999 {
+		days = (days-10000)%9000 + 1000
+	}
+	return days, nil
+}
+
+// boletoBarcodeCheckDigit is the modulo 11 check digit of the barcode, with
+// weights 2 to 9 from right to left; results 0, 10 and 11 become 1.
+func boletoBarcodeCheckDigit(digits string) int {
+	sum, weight := 0, 2
+	for i := len(digits) - 1; i >= 0; i-- {
+		sum += int(digits[i]-'0') * weight
+		weight++
+		if weight > 9 {
+			weight = 2
+		}
+	}
+
+	dv := 11 - sum%11
+	if dv == 0 || dv == 10 || dv == 11 {
+		return 1
+	}
+	return dv
+}
+
+// mod10CheckDigit is the modulo 10 check digit of a digitable line field,
+// with weights 2 and 1 alternating from right to left.
+func mod10CheckDigit(digits string) int {
+	sum, weight := 0, 2
+	for i := len(digits) - 1; i >= 0; i-- {
+		product := int(digits[i]-'0') * weight
+		sum += product/10 + product%10
+		weight = 3 - weight
+	}
+	return (10 - sum%10) % 10
+}
+
+// GenerateBoletoBarcodeImage renders the barcode as an Interleaved 2 of 5
+// base64 encoded PNG.
+func GenerateBoletoBarcodeImage(content string) (string, error) {
+	code, err := twooffive.Encode(content, true)
+	if err != nil {
+		return "", err
+	}
+
+	code, err = barcode.Scale(code, 1030, 100)
+	if err != nil {
+		return "", err
+	}
+
+	return encodePNGBase64(code)
+}
+
+// BoletoProvider issues registered boletos from the merchant's agreement.
+// Payment is confirmed by the bank's settlement notice, so the stored
+// payment is the source of truth for its status.
+type BoletoProvider struct{}
+
+func NewBoletoProvider() *BoletoProvider {
+	return &BoletoProvider{}
+}
+
+func (p *BoletoProvider) Name() string {
+	return BoletoProviderName
+}
+
+func (p *BoletoProvider) CreateIntent(ctx context.Context, req PaymentIntentRequest) (*PaymentIntent, error) {
+	sequence, err := rand.Int(rand.Reader, big.NewInt(10000000000))
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	dueDate := time.Date(now.Year(), now.Month(), now.Day(), 23, 59, 59, 0, now.Location()).
+		AddDate(0, 0, getEnvInt("BOLETO_DUE_DAYS", 3))
+
+	boleto := Boleto{
+		BankCode:  getEnv("BOLETO_BANK_CODE", "001"),
+		Agreement: getEnv("BOLETO_AGREEMENT", "0000000"),
+		Sequence:  fmt.Sprintf("%010d", sequence.Int64()),
+		Wallet:    getEnv("BOLETO_WALLET", "17"),
+		Amount:    req.Amount,
+		DueDate:   dueDate,
+	}
+
+	code, err := boleto.Barcode()
+	if err != nil {
+		return nil, err
+	}
+
+	line, err := BoletoDigitableLine(code)
+	if err != nil {
+		return nil, err
+	}
+
+	return &PaymentIntent{
+		Reference:           boleto.NossoNumero(),
+		Status:              models.PaymentStatusPending,
+		Amount:              req.Amount,
+		BoletoBarcode:       code,
+		BoletoDigitableLine: line,
+		DueDate:             &dueDate,
+	}, nil
+}
+
+func (p *BoletoProvider) Capture(ctx context.Context, reference string, amount float64) (*PaymentIntent, error) {
+	return nil, fmt.Errorf("boletos are settled by the payer and cannot be captured")
+}
+
+func (p *BoletoProvider) Refund(ctx context.Context, reference string, amount float64) (*PaymentRefundResult, error) {
+	return nil, ErrRefundNotSupported
+}
+
+func (p *BoletoProvider) FetchStatus(ctx context.Context, reference string) (*PaymentIntent, error) {
+	payment, err := repository.GetPaymentByReference(BoletoProviderName, reference)
+	if err != nil {
+		return nil, ErrPaymentNotFound
+	}
+
+	return &PaymentIntent{
+		Reference:           payment.ProviderReference,
+		Status:              payment.Status,
+		Amount:              payment.Amount,
+		BoletoBarcode:       payment.BoletoBarcode,
+		BoletoDigitableLine: payment.BoletoDigitableLine,
+		DueDate:             payment.DueDate,
+	}, nil
+}
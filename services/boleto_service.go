@@ -0,0 +1,95 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"smart-choice/database"
+	"smart-choice/models"
+
+	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// BoletoSettlement is the bank's notice that a boleto was paid.
+type BoletoSettlement struct {
+	NossoNumero string    `json:"nosso_numero"`
+	Amount      float64   `json:"amount"`
+	Currency    string    `json:"currency"`
+	PaidAt      time.Time `json:"paid_at"`
+}
+
+// HandleBoletoEvent settles the boleto named in a verified settlement notice.
+func HandleBoletoEvent(tx *gorm.DB, payload string) (*models.PaymentReview, error) {
+	var settlement BoletoSettlement
+	if err := json.Unmarshal([]byte(payload), &settlement); err != nil {
+		return nil, err
+	}
+
+	return ConfirmBoletoPayment(tx, settlement)
+}
+
+// ConfirmBoletoPayment settles the boleto within tx. A review the settlement
+// opened is returned.
+func ConfirmBoletoPayment(tx *gorm.DB, settlement BoletoSettlement) (*models.PaymentReview, error) {
+	var payment models.Payment
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("provider = ? AND provider_reference = ?", BoletoProviderName, settlement.NossoNumero).
+		First(&payment).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrPaymentNotFound
+		}
+		return nil, err
+	}
+
+	return settlePendingPayment(tx, &payment, settlement.Amount, settlement.Currency, settlement.PaidAt)
+}
+
+// CancelExpiredBoletoOrders expires boletos left unpaid past their due date
+// plus BOLETO_CANCEL_GRACE (bank settlement lag) and cancels their orders.
+func CancelExpiredBoletoOrders(ctx context.Context) error {
+	cutoff := time.Now().Add(-GetEnvDuration("BOLETO_CANCEL_GRACE", 24*time.Hour))
+
+	var payments []models.Payment
+	if err := database.DB.WithContext(ctx).
+		Where("method = ? AND status = ? AND due_date < ?", models.PaymentMethodBoleto, models.PaymentStatusPending, cutoff).
+		Find(&payments).Error; err != nil {
+		return err
+	}
+
+	for _, payment := range payments {
+		err := database.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			// Re-read under lock: the settlement notice may have just arrived.
+			var locked models.Payment
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&locked, payment.ID).Error; err != nil {
+				return err
+			}
+			if locked.Status != models.PaymentStatusPending {
+				return nil
+			}
+
+			if err := tx.Model(&locked).Update("status", models.PaymentStatusExpired).Error; err != nil {
+				return err
+			}
+
+			var order models.Order
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&order, locked.OrderID).Error; err != nil {
+				return err
+			}
+			if order.Status != models.OrderStatusPending {
+				return nil
+			}
+
+			return cancelOrderTx(tx, &order, "boleto expired unpaid")
+		})
+		if err != nil {
+			log.Error().Err(err).Uint("payment_id", payment.ID).Msg("Failed to cancel order with expired boleto")
+			continue
+		}
+	}
+
+	return nil
+}
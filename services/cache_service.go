@@ -11,6 +11,9 @@ import (
 
 type CacheService interface {
 	Set(ctx context.Context, key string, value interface{}, ttl time.Duration) error
+	// SetNX stores the value only if the key does not exist yet and reports
+	// whether it did.
+	SetNX(ctx context.Context, key string, value interface{}, ttl time.Duration) (bool, error)
 	Get(ctx context.Context, key string) (interface{}, bool)
 	Delete(ctx context.Context, key string) error
 	Clear(ctx context.Context, pattern string) error
@@ -47,6 +50,15 @@ func (r *RedisCache) Set(ctx context.Context, key string, value interface{}, ttl
 	return r.client.Set(ctx, key, jsonValue, ttl).Err()
 }
 
+func (r *RedisCache) SetNX(ctx context.Context, key string, value interface{}, ttl time.Duration) (bool, error) {
+	jsonValue, err := json.Marshal(value)
+	if err != nil {
+		return false, err
+	}
+
+	return r.client.SetNX(ctx, key, jsonValue, ttl).Result()
+}
+
 func (r *RedisCache) Get(ctx context.Context, key string) (interface{}, bool) {
 	val, err := r.client.Get(ctx, key).Result()
 	if err == redis.Nil {
@@ -84,10 +96,10 @@ func (r *RedisCache) Clear(ctx context.Context, pattern string) error {
 }
 
 func (r *RedisCache) Exists(ctx context.Context, key string) bool {
-	_, err := r.client.Exists(ctx, key).Result()
+	count, err := r.client.Exists(ctx, key).Result()
 	if err != nil {
 		log.Error().Err(err).Str("key", key).Msg("Cache exists check error")
 		return false
 	}
-	return true
+	return count > 0
 }
@@ -0,0 +1,93 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+
+	"smart-choice/database"
+	"smart-choice/models"
+
+	"gorm.io/gorm"
+)
+
+type CartPreviewRequest struct {
+	Items []OrderItemRequest `json:"items" binding:"required,dive"`
+}
+
+type CartLine struct {
+	ProductID uint    `json:"product_id"`
+	Name      string  `json:"name"`
+	Quantity  uint    `json:"quantity"`
+	UnitPrice float64 `json:"unit_price"`
+	Subtotal  float64 `json:"subtotal"`
+	Discount  float64 `json:"discount"`
+}
+
+// CartPreview prices a prospective order, automatic promotions included,
+// without reserving stock.
+type CartPreview struct {
+	Items        []CartLine          `json:"items"`
+	Subtotal     float64             `json:"subtotal"`
+	Discount     float64             `json:"discount"`
+	Promotions   PromotionResult     `json:"promotions"`
+	Total        float64             `json:"total"`
+	Installments []InstallmentOption `json:"installments"`
+}
+
+func PreviewCart(req CartPreviewRequest) (*CartPreview, error) {
+	if len(req.Items) == 0 {
+		return nil, ErrEmptyOrder
+	}
+
+	preview := CartPreview{}
+	var promotionLines []PromotionLine
+	seen := make(map[uint]bool)
+	for _, item := range req.Items {
+		if item.Quantity == 0 {
+			return nil, ErrInvalidItemQuantity
+		}
+		if seen[item.ProductID] {
+			return nil, ErrDuplicateOrderItem
+		}
+		seen[item.ProductID] = true
+
+		var product models.Product
+		if err := database.DB.First(&product, item.ProductID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, fmt.Errorf("%w: %d", ErrProductNotFound, item.ProductID)
+			}
+			return nil, err
+		}
+
+		line := CartLine{
+			ProductID: product.ID,
+			Name:      product.Name,
+			Quantity:  item.Quantity,
+			UnitPrice: product.Price,
+			Subtotal:  product.Price * float64(item.Quantity),
+		}
+		preview.Items = append(preview.Items, line)
+		preview.Subtotal += line.Subtotal
+		promotionLines = append(promotionLines, PromotionLine{
+			ProductID: product.ID,
+			Category:  product.Category,
+			Quantity:  item.Quantity,
+			UnitPrice: product.Price,
+		})
+	}
+
+	promotions, err := applyPromotions(database.DB, promotionLines)
+	if err != nil {
+		return nil, err
+	}
+	for i := range preview.Items {
+		preview.Items[i].Discount = promotions.DiscountFor(preview.Items[i].ProductID)
+	}
+	preview.Subtotal = roundCents(preview.Subtotal)
+	preview.Promotions = promotions
+	preview.Discount = promotions.Discount
+	preview.Total = roundCents(preview.Subtotal - promotions.Discount)
+
+	preview.Installments = CalculateInstallments(preview.Total, DefaultInstallmentRules())
+	return &preview, nil
+}
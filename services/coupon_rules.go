@@ -0,0 +1,170 @@
+package services
+
+import (
+	"math"
+	"strings"
+	"time"
+
+	"smart-choice/models"
+	"smart-choice/repository"
+
+	"gorm.io/gorm"
+)
+
+// CouponError is a coupon rejection. Code is stable so clients can tell the
+// reasons apart without parsing the message.
+type CouponError struct {
+	Code    string
+	Message string
+}
+
+func (e *CouponError) Error() string {
+	return e.Message
+}
+
+var (
+	ErrCouponInvalid        = &CouponError{Code: "COUPON_INVALID", Message: "invalid coupon code"}
+	ErrCouponNotStarted     = &CouponError{Code: "COUPON_NOT_STARTED", Message: "coupon is not valid yet"}
+	ErrCouponExpired        = &CouponError{Code: "COUPON_EXPIRED", Message: "coupon has expired"}
+	ErrCouponExhausted      = &CouponError{Code: "COUPON_USAGE_LIMIT", Message: "coupon has reached its usage limit"}
+	ErrCouponUserLimit      = &CouponError{Code: "COUPON_USER_LIMIT", Message: "coupon has reached its usage limit for this user"}
+	ErrCouponFirstOrderOnly = &CouponError{Code: "COUPON_FIRST_ORDER_ONLY", Message: "coupon is only valid on the first order"}
+	ErrCouponMinimumNotMet  = &CouponError{Code: "COUPON_MIN_AMOUNT", Message: "order amount does not meet the minimum requirement for this coupon"}
+	ErrCouponNotApplicable  = &CouponError{Code: "COUPON_NOT_APPLICABLE", Message: "coupon does not apply to any item in the cart"}
+)
+
+// CouponLine is a cart line as far as coupon rules are concerned.
+type CouponLine struct {
+	ProductID uint
+	Category  string
+	Amount    float64
+}
+
+// CouponQuote is what a coupon is worth on a given cart.
+type CouponQuote struct {
+	Coupon         *models.Coupon `json:"coupon"`
+	EligibleAmount float64        `json:"eligible_amount"`
+	Discount       float64        `json:"discount"`
+	FreeShipping   bool           `json:"free_shipping"`
+}
+
+// quoteCoupon checks every rule of the coupon against the user's cart and
+// prices the discount. Usage is read through db so the order transaction sees
+// its own locks.
+func quoteCoupon(db *gorm.DB, coupon *models.Coupon, userID uint, lines []CouponLine) (*CouponQuote, error) {
+	now := time.Now()
+
+	if !coupon.Active {
+		return nil, ErrCouponInvalid
+	}
+	if coupon.StartsAt != nil && now.Before(*coupon.StartsAt) {
+		return nil, ErrCouponNotStarted
+	}
+	if coupon.ValidUntil.Before(now) {
+		return nil, ErrCouponExpired
+	}
+	if coupon.UsedCount >= coupon.MaxUses {
+		return nil, ErrCouponExhausted
+	}
+
+	var subtotal float64
+	for _, line := range lines {
+		subtotal += line.Amount
+	}
+	if subtotal < coupon.MinAmount {
+		return nil, ErrCouponMinimumNotMet
+	}
+
+	if coupon.MaxUsesPerUser > 0 {
+		used, err := repository.CountUserCouponRedemptions(db, coupon.ID, userID)
+		if err != nil {
+			return nil, err
+		}
+		if used >= int64(coupon.MaxUsesPerUser) {
+			return nil, ErrCouponUserLimit
+		}
+	}
+
+	if coupon.FirstOrderOnly {
+		orders, err := repository.CountActiveUserOrders(db, userID)
+		if err != nil {
+			return nil, err
+		}
+		if orders > 0 {
+			return nil, ErrCouponFirstOrderOnly
+		}
+	}
+
+	eligible := CouponEligibleAmount(coupon, lines)
+	if eligible <= 0 {
+		return nil, ErrCouponNotApplicable
+	}
+
+	return &CouponQuote{
+		Coupon:         coupon,
+		EligibleAmount: eligible,
+		Discount:       CouponDiscount(coupon, eligible),
+		FreeShipping:   coupon.DiscountType == models.CouponTypeFreeShipping,
+	}, nil
+}
+
+// CouponEligibleAmount sums the cart lines the coupon applies to.
+func CouponEligibleAmount(coupon *models.Coupon, lines []CouponLine) float64 {
+	var eligible float64
+	for _, line := range lines {
+		if couponAppliesTo(coupon, line) {
+			eligible += line.Amount
+		}
+	}
+	return roundCents(eligible)
+}
+
+func couponAppliesTo(coupon *models.Coupon, line CouponLine) bool {
+	if containsID(coupon.ExcludedProducts, line.ProductID) || containsFold(coupon.ExcludedCategories, line.Category) {
+		return false
+	}
+	if len(coupon.IncludedProducts) == 0 && len(coupon.IncludedCategories) == 0 {
+		return true
+	}
+	return containsID(coupon.IncludedProducts, line.ProductID) || containsFold(coupon.IncludedCategories, line.Category)
+}
+
+// CouponDiscount is the amount a coupon takes off the eligible part of a
+// cart, capped by MaxDiscount and never more than the eligible amount.
+func CouponDiscount(coupon *models.Coupon, eligible float64) float64 {
+	var discount float64
+	switch coupon.DiscountType {
+	case models.CouponTypeFixed:
+		discount = coupon.Discount
+	case models.CouponTypeFreeShipping:
+		return 0
+	default:
+		discount = eligible * coupon.Discount / 100
+	}
+
+	if coupon.MaxDiscount > 0 {
+		discount = math.Min(discount, coupon.MaxDiscount)
+	}
+	return roundCents(math.Min(discount, eligible))
+}
+
+func containsID(ids []uint, id uint) bool {
+	for _, candidate := range ids {
+		if candidate == id {
+			return true
+		}
+	}
+	return false
+}
+
+func containsFold(values []string, value string) bool {
+	if value == "" {
+		return false
+	}
+	for _, candidate := range values {
+		if strings.EqualFold(strings.TrimSpace(candidate), value) {
+			return true
+		}
+	}
+	return false
+}
@@ -1,30 +1,404 @@
 package services
 
 import (
+	"crypto/rand"
+	"encoding/csv"
 	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"strconv"
+	"strings"
 	"time"
 
+	"smart-choice/database"
 	"smart-choice/models"
 	"smart-choice/repository"
+	"smart-choice/utils"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrCouponNotFound       = errors.New("coupon not found")
+	ErrCouponCodeTaken      = errors.New("coupon code already exists")
+	ErrInvalidCouponBatch   = errors.New("bulk generation requires a campaign and between 1 and 10000 codes")
+	ErrCouponCodeGeneration = errors.New("could not generate enough unique coupon codes")
 )
 
-func ValidateCoupon(code string, amount float64) (*models.Coupon, error) {
-	coupon, err := repository.GetCouponByCode(code)
+// couponCodeAlphabet leaves out characters that are easily confused (0/O, 1/I).
+const couponCodeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+const maxCouponBatch = 10000
+
+// ValidateCoupon quotes a coupon for the user. When items are given the
+// products are loaded so product and category rules apply; otherwise the
+// amount is checked as a single unscoped line.
+func ValidateCoupon(userID uint, code string, amount float64, items []OrderItemRequest) (*CouponQuote, error) {
+	coupon, err := repository.GetCouponByCode(NormalizeCouponCode(code))
 	if err != nil {
-		return nil, errors.New("invalid coupon code")
+		return nil, ErrCouponInvalid
 	}
 
-	if coupon.ValidUntil.Before(time.Now()) {
-		return nil, errors.New("coupon has expired")
+	lines := []CouponLine{{Amount: amount}}
+	if len(items) > 0 {
+		if lines, err = couponLinesForItems(items); err != nil {
+			return nil, err
+		}
 	}
 
-	if coupon.UsedCount >= coupon.MaxUses {
-		return nil, errors.New("coupon has reached its usage limit")
+	return quoteCoupon(database.DB, &coupon, userID, lines)
+}
+
+func couponLinesForItems(items []OrderItemRequest) ([]CouponLine, error) {
+	lines := make([]CouponLine, 0, len(items))
+	for _, item := range items {
+		product, err := repository.GetProductByID(item.ProductID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, fmt.Errorf("%w: %d", ErrProductNotFound, item.ProductID)
+			}
+			return nil, err
+		}
+		lines = append(lines, CouponLine{
+			ProductID: product.ID,
+			Category:  product.Category,
+			Amount:    product.Price * float64(item.Quantity),
+		})
+	}
+	return lines, nil
+}
+
+// redeemCouponTx spends one use of the coupon inside the order transaction.
+// The coupon row is locked so per-user limits hold under concurrent
+// checkouts, and the increment is conditional on the usage limit.
+func redeemCouponTx(tx *gorm.DB, userID uint, code string, lines []CouponLine) (*CouponQuote, error) {
+	var coupon models.Coupon
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("UPPER(code) = ?", NormalizeCouponCode(code)).First(&coupon).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrCouponInvalid
+		}
+		return nil, err
 	}
 
-	if amount < coupon.MinAmount {
-		return nil, errors.New("order amount does not meet the minimum requirement for this coupon")
+	quote, err := quoteCoupon(tx, &coupon, userID, lines)
+	if err != nil {
+		return nil, err
 	}
 
+	result := tx.Model(&models.Coupon{}).
+		Where("id = ? AND active = ? AND used_count < max_uses", coupon.ID, true).
+		UpdateColumn("used_count", gorm.Expr("used_count + 1"))
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrCouponExhausted
+	}
+	coupon.UsedCount++
+
+	return quote, nil
+}
+
+// releaseCouponTx gives the coupon use of a canceled order back.
+func releaseCouponTx(tx *gorm.DB, orderID uint) error {
+	var redemption models.CouponRedemption
+	err := tx.Where("order_id = ? AND released_at IS NULL", orderID).First(&redemption).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	if err := tx.Model(&redemption).Update("released_at", &now).Error; err != nil {
+		return err
+	}
+
+	return tx.Model(&models.Coupon{}).
+		Where("id = ? AND used_count > 0", redemption.CouponID).
+		UpdateColumn("used_count", gorm.Expr("used_count - 1")).Error
+}
+
+// CouponBatchRequest describes a campaign of single-purpose random codes.
+type CouponBatchRequest struct {
+	Campaign     string     `json:"campaign" binding:"required"`
+	Count        int        `json:"count" binding:"required"`
+	Prefix       string     `json:"prefix"`
+	DiscountType string     `json:"discount_type"`
+	Discount     float64    `json:"discount"`
+	MaxDiscount  float64    `json:"max_discount"`
+	StartsAt     *time.Time `json:"starts_at"`
+	ValidUntil   time.Time  `json:"valid_until" binding:"required"`
+	MaxUses      uint       `json:"max_uses"`
+	MinAmount    float64    `json:"min_amount"`
+
+	MaxUsesPerUser     uint     `json:"max_uses_per_user"`
+	FirstOrderOnly     bool     `json:"first_order_only"`
+	IncludedProducts   []uint   `json:"included_products"`
+	ExcludedProducts   []uint   `json:"excluded_products"`
+	IncludedCategories []string `json:"included_categories"`
+	ExcludedCategories []string `json:"excluded_categories"`
+}
+
+// CouponUsage summarises how much of a coupon has been used and by which orders.
+type CouponUsage struct {
+	Coupon    models.Coupon  `json:"coupon"`
+	UsedCount uint           `json:"used_count"`
+	MaxUses   uint           `json:"max_uses"`
+	Remaining uint           `json:"remaining"`
+	Orders    []models.Order `json:"orders"`
+}
+
+// NormalizeCouponCode is how codes are stored and looked up. Rows created
+// before codes were normalised may still be mixed case, so lookups compare
+// against UPPER(code).
+func NormalizeCouponCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+func ListCoupons(pagination *utils.Pagination, campaign, active, code string) ([]models.Coupon, error) {
+	return repository.GetCoupons(pagination, campaign, active, NormalizeCouponCode(code))
+}
+
+func GetCoupon(id uint) (*models.Coupon, error) {
+	coupon, err := repository.GetCouponByID(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrCouponNotFound
+		}
+		return nil, err
+	}
 	return &coupon, nil
 }
+
+func CreateCoupon(coupon *models.Coupon) error {
+	coupon.Code = NormalizeCouponCode(coupon.Code)
+	coupon.Active = true
+
+	if _, err := repository.GetCouponByCode(coupon.Code); err == nil {
+		return ErrCouponCodeTaken
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+
+	return repository.CreateCoupon(coupon)
+}
+
+// UpdateCoupon changes the coupon's terms. Its usage count is kept.
+func UpdateCoupon(id uint, changes models.Coupon) (*models.Coupon, error) {
+	coupon, err := GetCoupon(id)
+	if err != nil {
+		return nil, err
+	}
+
+	code := NormalizeCouponCode(changes.Code)
+	if code != coupon.Code {
+		existing, err := repository.GetCouponByCode(code)
+		if err == nil && existing.ID != coupon.ID {
+			return nil, ErrCouponCodeTaken
+		} else if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
+		}
+	}
+
+	coupon.Code = code
+	coupon.DiscountType = changes.DiscountType
+	coupon.Discount = changes.Discount
+	coupon.MaxDiscount = changes.MaxDiscount
+	coupon.StartsAt = changes.StartsAt
+	coupon.ValidUntil = changes.ValidUntil
+	coupon.MaxUses = changes.MaxUses
+	coupon.MinAmount = changes.MinAmount
+	coupon.Campaign = changes.Campaign
+	coupon.MaxUsesPerUser = changes.MaxUsesPerUser
+	coupon.FirstOrderOnly = changes.FirstOrderOnly
+	coupon.IncludedProducts = changes.IncludedProducts
+	coupon.ExcludedProducts = changes.ExcludedProducts
+	coupon.IncludedCategories = changes.IncludedCategories
+	coupon.ExcludedCategories = changes.ExcludedCategories
+
+	if err := repository.UpdateCoupon(coupon); err != nil {
+		return nil, err
+	}
+	return coupon, nil
+}
+
+// SetCouponActive activates or deactivates a coupon. Deactivated coupons stay
+// in the database so past orders keep their reference.
+func SetCouponActive(id uint, active bool) (*models.Coupon, error) {
+	coupon, err := GetCoupon(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := database.DB.Model(coupon).Update("active", active).Error; err != nil {
+		return nil, err
+	}
+	return coupon, nil
+}
+
+func GetCouponUsage(id uint) (*CouponUsage, error) {
+	coupon, err := GetCoupon(id)
+	if err != nil {
+		return nil, err
+	}
+
+	orders, err := repository.GetOrdersByCouponID(coupon.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	usage := CouponUsage{
+		Coupon:    *coupon,
+		UsedCount: coupon.UsedCount,
+		MaxUses:   coupon.MaxUses,
+		Orders:    orders,
+	}
+	if coupon.MaxUses > coupon.UsedCount {
+		usage.Remaining = coupon.MaxUses - coupon.UsedCount
+	}
+	return &usage, nil
+}
+
+// GenerateCouponBatch creates Count coupons with unique random codes for a
+// campaign. Each code is single use unless MaxUses says otherwise.
+func GenerateCouponBatch(req CouponBatchRequest) ([]models.Coupon, error) {
+	if strings.TrimSpace(req.Campaign) == "" || req.Count < 1 || req.Count > maxCouponBatch {
+		return nil, ErrInvalidCouponBatch
+	}
+	if req.MaxUses == 0 {
+		req.MaxUses = 1
+	}
+	if req.DiscountType == "" {
+		req.DiscountType = models.CouponTypePercentage
+	}
+	prefix := NormalizeCouponCode(req.Prefix)
+
+	errs := utils.ValidateCoupon(prefix+"XXXXXXXX", req.DiscountType, req.Discount, req.ValidUntil, req.MaxUses, req.MinAmount)
+	errs = append(errs, utils.ValidateCouponLimits(req.MaxDiscount, req.StartsAt, req.ValidUntil)...)
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidCouponBatch, strings.Join(errs, "; "))
+	}
+
+	codes, err := uniqueCouponCodes(prefix, req.Count)
+	if err != nil {
+		return nil, err
+	}
+
+	coupons := make([]models.Coupon, 0, len(codes))
+	for _, code := range codes {
+		coupons = append(coupons, models.Coupon{
+			Code:         code,
+			DiscountType: req.DiscountType,
+			Discount:     req.Discount,
+			MaxDiscount:  req.MaxDiscount,
+			StartsAt:     req.StartsAt,
+			ValidUntil:   req.ValidUntil,
+			MaxUses:      req.MaxUses,
+			MinAmount:    req.MinAmount,
+			Active:       true,
+			Campaign:     strings.TrimSpace(req.Campaign),
+
+			MaxUsesPerUser:     req.MaxUsesPerUser,
+			FirstOrderOnly:     req.FirstOrderOnly,
+			IncludedProducts:   req.IncludedProducts,
+			ExcludedProducts:   req.ExcludedProducts,
+			IncludedCategories: req.IncludedCategories,
+			ExcludedCategories: req.ExcludedCategories,
+		})
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		return tx.CreateInBatches(coupons, 500).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return coupons, nil
+}
+
+// uniqueCouponCodes draws random codes until it has count codes that are
+// neither repeated nor already in the database.
+func uniqueCouponCodes(prefix string, count int) ([]string, error) {
+	chosen := make(map[string]bool, count)
+	codes := make([]string, 0, count)
+
+	for attempt := 0; attempt < 5 && len(codes) < count; attempt++ {
+		var candidates []string
+		for len(candidates) < count-len(codes) {
+			code, err := randomCouponCode(prefix, 8)
+			if err != nil {
+				return nil, err
+			}
+			if !chosen[code] {
+				chosen[code] = true
+				candidates = append(candidates, code)
+			}
+		}
+
+		existing, err := repository.GetExistingCouponCodes(candidates)
+		if err != nil {
+			return nil, err
+		}
+		taken := make(map[string]bool, len(existing))
+		for _, code := range existing {
+			taken[code] = true
+		}
+
+		for _, code := range candidates {
+			if !taken[code] {
+				codes = append(codes, code)
+			}
+		}
+	}
+
+	if len(codes) < count {
+		return nil, ErrCouponCodeGeneration
+	}
+	return codes, nil
+}
+
+func randomCouponCode(prefix string, length int) (string, error) {
+	var b strings.Builder
+	b.WriteString(prefix)
+	max := big.NewInt(int64(len(couponCodeAlphabet)))
+	for i := 0; i < length; i++ {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		b.WriteByte(couponCodeAlphabet[n.Int64()])
+	}
+	return b.String(), nil
+}
+
+// WriteCouponsCSV exports the coupons of a campaign (or all coupons).
+func WriteCouponsCSV(w io.Writer, campaign string) error {
+	coupons, err := repository.GetCouponsByCampaign(campaign)
+	if err != nil {
+		return err
+	}
+
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"code", "campaign", "discount_type", "discount", "valid_until", "max_uses", "used_count", "min_amount", "active"})
+	for _, coupon := range coupons {
+		writer.Write([]string{
+			coupon.Code,
+			coupon.Campaign,
+			coupon.DiscountType,
+			strconv.FormatFloat(coupon.Discount, 'f', 2, 64),
+			coupon.ValidUntil.Format(time.RFC3339),
+			strconv.FormatUint(uint64(coupon.MaxUses), 10),
+			strconv.FormatUint(uint64(coupon.UsedCount), 10),
+			strconv.FormatFloat(coupon.MinAmount, 'f', 2, 64),
+			strconv.FormatBool(coupon.Active),
+		})
+	}
+	writer.Flush()
+	return writer.Error()
+}
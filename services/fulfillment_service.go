@@ -0,0 +1,79 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"smart-choice/database"
+	"smart-choice/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrInvalidOrderTransition = errors.New("order cannot move to that status")
+
+// fulfillmentTransitions lists the statuses an order may leave for each
+// fulfillment status. Only orders refunded before they shipped are
+// partially_refunded; refunds keep later fulfillment statuses, and fully
+// refunded orders never move on.
+var fulfillmentTransitions = map[string][]string{
+	models.OrderStatusShipped:   {models.OrderStatusPaid, models.OrderStatusPartiallyRefunded},
+	models.OrderStatusDelivered: {models.OrderStatusPaid, models.OrderStatusPartiallyRefunded, models.OrderStatusShipped},
+}
+
+// ShipOrder marks a paid order as handed to the carrier.
+func ShipOrder(admin *models.User, orderID uint) (*models.Order, error) {
+	return advanceFulfillment(admin, orderID, models.OrderStatusShipped)
+}
+
+// DeliverOrder marks an order as delivered and credits its loyalty points.
+func DeliverOrder(admin *models.User, orderID uint) (*models.Order, error) {
+	return advanceFulfillment(admin, orderID, models.OrderStatusDelivered)
+}
+
+func advanceFulfillment(admin *models.User, orderID uint, status string) (*models.Order, error) {
+	var order models.Order
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&order, orderID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrOrderNotFound
+			}
+			return err
+		}
+
+		allowed := false
+		for _, from := range fulfillmentTransitions[status] {
+			if order.Status == from {
+				allowed = true
+			}
+		}
+		if !allowed {
+			return fmt.Errorf("%w: %s to %s", ErrInvalidOrderTransition, order.Status, status)
+		}
+
+		order.Status = status
+		if err := tx.Model(&order).Update("status", status).Error; err != nil {
+			return err
+		}
+
+		if status == models.OrderStatusDelivered {
+			if err := earnLoyaltyPointsTx(tx, &order); err != nil {
+				return err
+			}
+		}
+
+		activityLog := models.ActivityLog{
+			UserID:    order.UserID,
+			Action:    fmt.Sprintf("Order %d %s by admin %d", order.ID, status, admin.ID),
+			Timestamp: time.Now(),
+		}
+		return tx.Create(&activityLog).Error
+	})
+
+	if err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
@@ -0,0 +1,196 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"smart-choice/database"
+	"smart-choice/models"
+	"smart-choice/repository"
+	"smart-choice/utils"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrGiftCardNotFound        = errors.New("gift card not found")
+	ErrGiftCardUnusable        = errors.New("gift card is inactive or expired")
+	ErrGiftCardEmpty           = errors.New("gift card has no balance left")
+	ErrInvalidGiftCardBalance  = errors.New("gift card balance must be greater than zero")
+	ErrInsufficientBalance     = errors.New("balance is not enough for this operation")
+	ErrGiftCardCodeTaken       = errors.New("gift card code already exists")
+	ErrInvalidGiftCardExpiry   = errors.New("gift card expiry must be in the future")
+	ErrGiftCardCodeUnavailable = errors.New("could not generate a unique gift card code")
+)
+
+// GiftCardRequest is an admin issuing a gift card, for instance one sold at
+// the counter or given to a customer. The code is generated when empty.
+type GiftCardRequest struct {
+	Code      string     `json:"code"`
+	Balance   float64    `json:"balance" binding:"required"`
+	ExpiresAt *time.Time `json:"expires_at"`
+	Note      string     `json:"note"`
+}
+
+// GiftCardBalance is what a balance inquiry reveals about a card.
+type GiftCardBalance struct {
+	Code             string     `json:"code"`
+	RemainingBalance float64    `json:"remaining_balance"`
+	ExpiresAt        *time.Time `json:"expires_at"`
+	Usable           bool       `json:"usable"`
+}
+
+func IssueGiftCard(admin *models.User, req GiftCardRequest) (*models.GiftCard, error) {
+	balance := roundCents(req.Balance)
+	if balance <= 0 {
+		return nil, ErrInvalidGiftCardBalance
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		return nil, ErrInvalidGiftCardExpiry
+	}
+
+	code := NormalizeCouponCode(req.Code)
+	if code == "" {
+		var err error
+		if code, err = uniqueGiftCardCode(); err != nil {
+			return nil, err
+		}
+	} else if _, err := repository.GetGiftCardByCode(code); err == nil {
+		return nil, ErrGiftCardCodeTaken
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	card := models.GiftCard{
+		Code:             code,
+		InitialBalance:   balance,
+		RemainingBalance: balance,
+		ExpiresAt:        req.ExpiresAt,
+		Active:           true,
+		IssuedByID:       admin.ID,
+		Note:             req.Note,
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&card).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.GiftCardTransaction{
+			GiftCardID:   card.ID,
+			Amount:       balance,
+			BalanceAfter: balance,
+			Reason:       "issued",
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &card, nil
+}
+
+func uniqueGiftCardCode() (string, error) {
+	for attempt := 0; attempt < 5; attempt++ {
+		code, err := randomCouponCode("GC", 16)
+		if err != nil {
+			return "", err
+		}
+		if _, err := repository.GetGiftCardByCode(code); errors.Is(err, gorm.ErrRecordNotFound) {
+			return code, nil
+		} else if err != nil {
+			return "", err
+		}
+	}
+	return "", ErrGiftCardCodeUnavailable
+}
+
+func ListGiftCards(pagination *utils.Pagination, active string) ([]models.GiftCard, error) {
+	return repository.GetGiftCards(pagination, active)
+}
+
+func DeactivateGiftCard(id uint) (*models.GiftCard, error) {
+	card, err := repository.GetGiftCardByID(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrGiftCardNotFound
+		}
+		return nil, err
+	}
+
+	if err := database.DB.Model(&card).Update("active", false).Error; err != nil {
+		return nil, err
+	}
+	return &card, nil
+}
+
+func GetGiftCardBalance(code string) (*GiftCardBalance, error) {
+	card, err := repository.GetGiftCardByCode(NormalizeCouponCode(code))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrGiftCardNotFound
+		}
+		return nil, err
+	}
+
+	return &GiftCardBalance{
+		Code:             card.Code,
+		RemainingBalance: card.RemainingBalance,
+		ExpiresAt:        card.ExpiresAt,
+		Usable:           card.Usable(time.Now()),
+	}, nil
+}
+
+// spendGiftCardTx takes up to due from the card for the order. The card row
+// is locked and the decrement is guarded so the balance never goes negative.
+func spendGiftCardTx(tx *gorm.DB, code string, orderID uint, due float64) (float64, *models.GiftCardTransaction, error) {
+	var card models.GiftCard
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("code = ?", NormalizeCouponCode(code)).First(&card).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return 0, nil, ErrGiftCardNotFound
+		}
+		return 0, nil, err
+	}
+
+	if !card.Usable(time.Now()) {
+		return 0, nil, ErrGiftCardUnusable
+	}
+	if card.RemainingBalance <= 0 {
+		return 0, nil, ErrGiftCardEmpty
+	}
+
+	amount := roundCents(math.Min(card.RemainingBalance, due))
+	txn, err := moveGiftCardBalanceTx(tx, &card, -amount, &orderID, fmt.Sprintf("order %d", orderID))
+	if err != nil {
+		return 0, nil, err
+	}
+	return amount, txn, nil
+}
+
+// moveGiftCardBalanceTx changes a card balance by amount and records it.
+func moveGiftCardBalanceTx(tx *gorm.DB, card *models.GiftCard, amount float64, orderID *uint, reason string) (*models.GiftCardTransaction, error) {
+	result := tx.Model(&models.GiftCard{}).
+		Where("id = ? AND remaining_balance + ? >= 0", card.ID, amount).
+		UpdateColumn("remaining_balance", gorm.Expr("remaining_balance + ?", amount))
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrInsufficientBalance
+	}
+	card.RemainingBalance = roundCents(card.RemainingBalance + amount)
+
+	txn := models.GiftCardTransaction{
+		GiftCardID:   card.ID,
+		OrderID:      orderID,
+		Amount:       amount,
+		BalanceAfter: card.RemainingBalance,
+		Reason:       reason,
+	}
+	if err := tx.Create(&txn).Error; err != nil {
+		return nil, err
+	}
+	return &txn, nil
+}
@@ -0,0 +1,150 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+
+	"smart-choice/models"
+)
+
+var ErrInstallmentsUnavailable = errors.New("the requested number of installments is not available for this amount")
+
+// InstallmentRules are the merchant's parcelamento settings.
+type InstallmentRules struct {
+	MaxInstallments          int     `json:"max_installments"`
+	MinInstallmentValue      float64 `json:"min_installment_value"`
+	InterestFreeInstallments int     `json:"interest_free_installments"`
+	MonthlyInterestRate      float64 `json:"monthly_interest_rate"`
+}
+
+// InstallmentOption is one row of the installment table.
+type InstallmentOption struct {
+	Count  int     `json:"count"`
+	Amount float64 `json:"amount"`
+	// FirstAmount is the first installment, which absorbs the cents left
+	// over when the total does not split evenly, so the installments add
+	// up to Total.
+	FirstAmount  float64 `json:"first_amount"`
+	Total        float64 `json:"total"`
+	InterestFree bool    `json:"interest_free"`
+	MonthlyRate  float64 `json:"monthly_rate"`
+}
+
+// Label renders the option the way it is shown to customers, e.g.
+// "12x de R$ 8,33 sem juros".
+func (o InstallmentOption) Label() string {
+	label := fmt.Sprintf("%dx de %s", o.Count, FormatBRL(o.Amount))
+	if o.InterestFree {
+		label += " sem juros"
+	}
+	return label
+}
+
+// DefaultInstallmentRules reads the rules from the environment.
+func DefaultInstallmentRules() InstallmentRules {
+	return InstallmentRules{
+		MaxInstallments:          getEnvInt("INSTALLMENT_MAX", 12),
+		MinInstallmentValue:      getEnvFloat("INSTALLMENT_MIN_VALUE", 5),
+		InterestFreeInstallments: getEnvInt("INSTALLMENT_INTEREST_FREE", 3),
+		MonthlyInterestRate:      getEnvFloat("INSTALLMENT_MONTHLY_RATE", 0.0199),
+	}
+}
+
+// CalculateInstallments returns the installment table for a total. Plans
+// beyond the interest-free count use the Price table (fixed installments on
+// compound monthly interest). Options whose installment would fall below the
+// minimum value are left out; paying in full is always offered.
+func CalculateInstallments(total float64, rules InstallmentRules) []InstallmentOption {
+	options := []InstallmentOption{{
+		Count:        1,
+		Amount:       roundCents(total),
+		FirstAmount:  roundCents(total),
+		Total:        roundCents(total),
+		InterestFree: true,
+	}}
+
+	for n := 2; n <= rules.MaxInstallments; n++ {
+		option := InstallmentOption{Count: n}
+
+		if n <= rules.InterestFreeInstallments || rules.MonthlyInterestRate <= 0 {
+			option.Amount = roundCents(total / float64(n))
+			option.Total = roundCents(total)
+			option.InterestFree = true
+		} else {
+			rate := rules.MonthlyInterestRate
+			option.Amount = roundCents(total * rate / (1 - math.Pow(1+rate, -float64(n))))
+			option.Total = roundCents(option.Amount * float64(n))
+			option.MonthlyRate = rate
+		}
+		option.FirstAmount = roundCents(option.Total - option.Amount*float64(n-1))
+
+		if option.Amount < rules.MinInstallmentValue {
+			break
+		}
+		options = append(options, option)
+	}
+
+	return options
+}
+
+// FindInstallmentOption returns the plan with the given number of installments.
+func FindInstallmentOption(total float64, count int, rules InstallmentRules) (InstallmentOption, error) {
+	for _, option := range CalculateInstallments(total, rules) {
+		if option.Count == count {
+			return option, nil
+		}
+	}
+	return InstallmentOption{}, ErrInstallmentsUnavailable
+}
+
+// BestInstallmentOffer picks the option to advertise: the longest
+// interest-free plan, or the longest plan overall when only one is free.
+func BestInstallmentOffer(total float64, rules InstallmentRules) (InstallmentOption, bool) {
+	options := CalculateInstallments(total, rules)
+	if len(options) < 2 {
+		return InstallmentOption{}, false
+	}
+
+	best := options[len(options)-1]
+	for _, option := range options[1:] {
+		if option.InterestFree {
+			best = option
+		}
+	}
+	return best, true
+}
+
+// installmentPlan converts a chosen option into the snapshot stored on the order.
+func installmentPlan(option InstallmentOption) models.InstallmentPlan {
+	return models.InstallmentPlan{
+		Count:        uint(option.Count),
+		Amount:       option.Amount,
+		FirstAmount:  option.FirstAmount,
+		Total:        option.Total,
+		InterestFree: option.InterestFree,
+		MonthlyRate:  option.MonthlyRate,
+	}
+}
+
+// FormatBRL formats an amount as Brazilian currency, e.g. "R$ 1.234,56".
+func FormatBRL(amount float64) string {
+	cents := int64(math.Round(amount * 100))
+	sign := ""
+	if cents < 0 {
+		sign = "-"
+		cents = -cents
+	}
+
+	units := strconv.FormatInt(cents/100, 10)
+	for i := len(units) - 3; i > 0; i -= 3 {
+		units = units[:i] + "." + units[i:]
+	}
+
+	return fmt.Sprintf("%sR$ %s,%02d", sign, units, cents%100)
+}
+
+func roundCents(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
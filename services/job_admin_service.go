@@ -0,0 +1,200 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+	"unicode/utf8"
+
+	"smart-choice/utils"
+)
+
+const (
+	JobStateQueued     = "queued"
+	JobStateScheduled  = "scheduled"
+	JobStateRetrying   = "retrying"
+	JobStateProcessing = "processing"
+	JobStateDead       = "dead"
+
+	jobPayloadPreviewSize = 200
+)
+
+var (
+	ErrJobNotFound     = errors.New("job not found")
+	ErrInvalidJobState = errors.New("state must be queued, scheduled, retrying, processing or dead")
+	ErrUnknownJobType  = errors.New("unknown job type")
+)
+
+// JobInfo describes a job for operators.
+type JobInfo struct {
+	ID          string    `json:"id"`
+	Type        JobType   `json:"type"`
+	Queue       string    `json:"queue"`
+	State       string    `json:"state"`
+	Attempts    int       `json:"attempts"`
+	MaxAttempts int       `json:"max_attempts"`
+	LastError   string    `json:"last_error,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	// RunAt is when a scheduled or retrying job is due.
+	RunAt      *time.Time `json:"run_at,omitempty"`
+	LeaseUntil *time.Time `json:"lease_until,omitempty"`
+	FailedAt   *time.Time `json:"failed_at,omitempty"`
+	Payload    string     `json:"payload_preview"`
+}
+
+// JobStats summarises the queue for the admin API.
+type JobStats struct {
+	Queue       map[string]int `json:"queue"`
+	PausedTypes []JobType      `json:"paused_types"`
+	JobTypes    []JobType      `json:"job_types"`
+}
+
+// JobInspector is implemented by queues that operators can inspect and
+// act on.
+type JobInspector interface {
+	ListJobs(ctx context.Context, state string, offset, limit int) ([]JobInfo, int64, error)
+	RetryDeadJob(ctx context.Context, jobID string) error
+	DiscardDeadJob(ctx context.Context, jobID string) error
+	// RetryDeadJobs and DiscardDeadJobs act on every dead job of the given
+	// type, or on all of them when jobType is empty.
+	RetryDeadJobs(ctx context.Context, jobType JobType) (int, error)
+	DiscardDeadJobs(ctx context.Context, jobType JobType) (int, error)
+	PauseJobType(ctx context.Context, jobType JobType) error
+	ResumeJobType(ctx context.Context, jobType JobType) error
+	PausedJobTypes(ctx context.Context) ([]JobType, error)
+}
+
+// JobPayloadPreview returns the start of a payload as text, cut at
+// jobPayloadPreviewSize bytes.
+func JobPayloadPreview(payload []byte) string {
+	if len(payload) <= jobPayloadPreviewSize {
+		return string(payload)
+	}
+
+	cut := jobPayloadPreviewSize
+	for cut > 0 && !utf8.RuneStart(payload[cut]) {
+		cut--
+	}
+	return string(payload[:cut]) + "…"
+}
+
+func jobInspector() (JobInspector, error) {
+	inspector, ok := GetServiceManager().GetJobQueue().(JobInspector)
+	if !ok {
+		return nil, ErrJobQueueUnavailable
+	}
+	return inspector, nil
+}
+
+func validJobType(jobType JobType) error {
+	if _, ok := NewDefaultJobRegistry().Handler(jobType); !ok {
+		return ErrUnknownJobType
+	}
+	return nil
+}
+
+func ListJobs(ctx context.Context, state string, pagination *utils.Pagination) ([]JobInfo, error) {
+	switch state {
+	case JobStateQueued, JobStateScheduled, JobStateRetrying, JobStateProcessing, JobStateDead:
+	default:
+		return nil, ErrInvalidJobState
+	}
+
+	inspector, err := jobInspector()
+	if err != nil {
+		return nil, err
+	}
+
+	jobs, total, err := inspector.ListJobs(ctx, state, pagination.GetOffset(), pagination.GetLimit())
+	if err != nil {
+		return nil, err
+	}
+	pagination.TotalRows = total
+	return jobs, nil
+}
+
+func GetJobStats(ctx context.Context) (*JobStats, error) {
+	inspector, err := jobInspector()
+	if err != nil {
+		return nil, err
+	}
+
+	queue, err := GetServiceManager().GetJobQueue().GetStats(ctx)
+	if err != nil {
+		return nil, err
+	}
+	paused, err := inspector.PausedJobTypes(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &JobStats{Queue: queue, PausedTypes: paused, JobTypes: NewDefaultJobRegistry().Types()}, nil
+}
+
+func RetryDeadJob(ctx context.Context, jobID string) error {
+	inspector, err := jobInspector()
+	if err != nil {
+		return err
+	}
+	return inspector.RetryDeadJob(ctx, jobID)
+}
+
+func DiscardDeadJob(ctx context.Context, jobID string) error {
+	inspector, err := jobInspector()
+	if err != nil {
+		return err
+	}
+	return inspector.DiscardDeadJob(ctx, jobID)
+}
+
+func RetryDeadJobs(ctx context.Context, jobType JobType) (int, error) {
+	if jobType != "" {
+		if err := validJobType(jobType); err != nil {
+			return 0, err
+		}
+	}
+
+	inspector, err := jobInspector()
+	if err != nil {
+		return 0, err
+	}
+	return inspector.RetryDeadJobs(ctx, jobType)
+}
+
+func DiscardDeadJobs(ctx context.Context, jobType JobType) (int, error) {
+	if jobType != "" {
+		if err := validJobType(jobType); err != nil {
+			return 0, err
+		}
+	}
+
+	inspector, err := jobInspector()
+	if err != nil {
+		return 0, err
+	}
+	return inspector.DiscardDeadJobs(ctx, jobType)
+}
+
+func PauseJobType(ctx context.Context, jobType JobType) error {
+	if err := validJobType(jobType); err != nil {
+		return err
+	}
+
+	inspector, err := jobInspector()
+	if err != nil {
+		return err
+	}
+	return inspector.PauseJobType(ctx, jobType)
+}
+
+func ResumeJobType(ctx context.Context, jobType JobType) error {
+	if err := validJobType(jobType); err != nil {
+		return err
+	}
+
+	inspector, err := jobInspector()
+	if err != nil {
+		return err
+	}
+	return inspector.ResumeJobType(ctx, jobType)
+}
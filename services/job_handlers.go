@@ -0,0 +1,171 @@
+package services
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"smart-choice/repository"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	ReportLowStock = "low_stock"
+
+	reportFileTimeFormat = "20060102-150405"
+)
+
+var (
+	ErrInvalidEmailJob = errors.New("email job needs a recipient and a subject")
+	ErrUnknownReport   = errors.New("unknown report")
+)
+
+var (
+	emailSenderMu sync.RWMutex
+	emailSender   EmailSender = LogEmailSender{}
+)
+
+// EmailMessage is the payload of a JobTypeEmailSend job.
+type EmailMessage struct {
+	To      string `json:"to"`
+	Subject string `json:"subject"`
+	Body    string `json:"body"`
+}
+
+// EmailSender delivers outgoing email.
+type EmailSender interface {
+	Send(ctx context.Context, message EmailMessage) error
+}
+
+// LogEmailSender only logs messages; it is used until a real transport is
+// configured.
+type LogEmailSender struct{}
+
+func (LogEmailSender) Send(ctx context.Context, message EmailMessage) error {
+	log.Info().Str("to", message.To).Str("subject", message.Subject).Msg("Email sent")
+	return nil
+}
+
+// SetEmailSender replaces the transport used by email jobs.
+func SetEmailSender(sender EmailSender) {
+	emailSenderMu.Lock()
+	defer emailSenderMu.Unlock()
+	emailSender = sender
+}
+
+func currentEmailSender() EmailSender {
+	emailSenderMu.RLock()
+	defer emailSenderMu.RUnlock()
+	return emailSender
+}
+
+// HandleEmailSendJob sends the message in a JobTypeEmailSend job.
+func HandleEmailSendJob(ctx context.Context, job *Job) error {
+	var message EmailMessage
+	if err := json.Unmarshal(job.Payload, &message); err != nil {
+		return err
+	}
+	if strings.TrimSpace(message.To) == "" || strings.TrimSpace(message.Subject) == "" {
+		return ErrInvalidEmailJob
+	}
+
+	return currentEmailSender().Send(ctx, message)
+}
+
+// ReportRequest is the payload of a JobTypeReportGenerate job.
+type ReportRequest struct {
+	Report string `json:"report"`
+}
+
+// HandleReportGenerateJob writes the requested report as CSV under
+// REPORTS_DIR.
+func HandleReportGenerateJob(ctx context.Context, job *Job) error {
+	var req ReportRequest
+	if err := json.Unmarshal(job.Payload, &req); err != nil {
+		return err
+	}
+
+	switch req.Report {
+	case ReportLowStock:
+		path, err := writeLowStockReport()
+		if err != nil {
+			return err
+		}
+		log.Info().Str("path", path).Msg("Low stock report generated")
+		return nil
+	default:
+		return fmt.Errorf("%w: %s", ErrUnknownReport, req.Report)
+	}
+}
+
+func writeLowStockReport() (string, error) {
+	products, err := repository.GetLowStockProducts()
+	if err != nil {
+		return "", err
+	}
+
+	dir := getEnv("REPORTS_DIR", filepath.Join("exports", "reports"))
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", err
+	}
+
+	path := filepath.Join(dir, fmt.Sprintf("low-stock-%s.csv", time.Now().Format(reportFileTimeFormat)))
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	w := csv.NewWriter(file)
+	w.Write([]string{"id", "name", "category", "stock", "stock_limit"})
+	for _, product := range products {
+		w.Write([]string{
+			strconv.FormatUint(uint64(product.ID), 10),
+			product.Name,
+			product.Category,
+			strconv.FormatUint(uint64(product.Stock), 10),
+			strconv.FormatUint(uint64(product.StockLimit), 10),
+		})
+	}
+	w.Flush()
+	return path, w.Error()
+}
+
+// HandleDataCleanupJob removes data export archives older than
+// DATA_EXPORT_RETENTION. The requests are kept without a file.
+func HandleDataCleanupJob(ctx context.Context, job *Job) error {
+	before := time.Now().Add(-GetEnvDuration("DATA_EXPORT_RETENTION", 7*24*time.Hour))
+	requests, err := repository.GetExpiredDataExports(before)
+	if err != nil {
+		return err
+	}
+
+	for i := range requests {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		request := &requests[i]
+		if err := os.Remove(request.FilePath); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		request.FilePath = ""
+		if err := repository.UpdatePrivacyRequest(request); err != nil {
+			return err
+		}
+	}
+
+	if len(requests) > 0 {
+		log.Info().Int("count", len(requests)).Msg("Expired data exports removed")
+	}
+	return nil
+}
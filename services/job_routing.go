@@ -0,0 +1,150 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	DefaultJobQueueName = "default"
+
+	// Built-in queues and routes: email is latency sensitive, reports and
+	// cleanup are heavy and run one at a time.
+	defaultJobQueuesSpec = "critical:5,default:3:3,bulk:1:1"
+	defaultJobRoutesSpec = "email_send:critical,report_generate:bulk,data_cleanup:bulk,data_export:default,reconciliation:default"
+)
+
+var ErrInvalidJobRouting = errors.New("invalid job queue configuration")
+
+// JobQueueSpec describes a named queue. Weight sets its share of the
+// workers when several queues have jobs; Concurrency caps the jobs it runs
+// at once (0 means no cap beyond the pool size).
+type JobQueueSpec struct {
+	Name        string `json:"name"`
+	Weight      int    `json:"weight"`
+	Concurrency int    `json:"concurrency"`
+}
+
+// JobRouting maps job types to named queues.
+type JobRouting struct {
+	Queues []JobQueueSpec
+	Routes map[JobType]string
+}
+
+// DefaultJobRouting reads the queues from JOB_QUEUES and the routes from
+// JOB_ROUTES.
+func DefaultJobRouting() (JobRouting, error) {
+	return NewJobRouting(getEnv("JOB_QUEUES", defaultJobQueuesSpec), getEnv("JOB_ROUTES", defaultJobRoutesSpec))
+}
+
+func builtinJobRouting() JobRouting {
+	routing, err := NewJobRouting(defaultJobQueuesSpec, defaultJobRoutesSpec)
+	if err != nil {
+		panic(err)
+	}
+	return routing
+}
+
+// NewJobRouting parses a queue list ("name:weight[:concurrency],...") and a
+// route list ("job_type:queue,..."). Queues are kept by descending weight.
+func NewJobRouting(queuesSpec, routesSpec string) (JobRouting, error) {
+	queues, err := ParseJobQueues(queuesSpec)
+	if err != nil {
+		return JobRouting{}, err
+	}
+
+	routing := JobRouting{Queues: queues, Routes: make(map[JobType]string)}
+	for _, part := range splitSpec(routesSpec) {
+		fields := strings.Split(part, ":")
+		if len(fields) != 2 || fields[0] == "" {
+			return JobRouting{}, fmt.Errorf("%w: bad route %q", ErrInvalidJobRouting, part)
+		}
+		if !routing.HasQueue(fields[1]) {
+			return JobRouting{}, fmt.Errorf("%w: route %q targets unknown queue", ErrInvalidJobRouting, part)
+		}
+		routing.Routes[JobType(fields[0])] = fields[1]
+	}
+	return routing, nil
+}
+
+func ParseJobQueues(spec string) ([]JobQueueSpec, error) {
+	var queues []JobQueueSpec
+	seen := make(map[string]bool)
+
+	for _, part := range splitSpec(spec) {
+		fields := strings.Split(part, ":")
+		if len(fields) < 2 || len(fields) > 3 || fields[0] == "" {
+			return nil, fmt.Errorf("%w: bad queue %q", ErrInvalidJobRouting, part)
+		}
+
+		queue := JobQueueSpec{Name: fields[0]}
+		weight, err := strconv.Atoi(fields[1])
+		if err != nil || weight <= 0 {
+			return nil, fmt.Errorf("%w: queue %q needs a positive weight", ErrInvalidJobRouting, part)
+		}
+		queue.Weight = weight
+		if len(fields) == 3 {
+			concurrency, err := strconv.Atoi(fields[2])
+			if err != nil || concurrency < 0 {
+				return nil, fmt.Errorf("%w: queue %q has a bad concurrency", ErrInvalidJobRouting, part)
+			}
+			queue.Concurrency = concurrency
+		}
+
+		if seen[queue.Name] {
+			return nil, fmt.Errorf("%w: queue %q declared twice", ErrInvalidJobRouting, queue.Name)
+		}
+		seen[queue.Name] = true
+		queues = append(queues, queue)
+	}
+
+	if len(queues) == 0 {
+		return nil, fmt.Errorf("%w: no queues", ErrInvalidJobRouting)
+	}
+
+	sort.SliceStable(queues, func(i, j int) bool { return queues[i].Weight > queues[j].Weight })
+	return queues, nil
+}
+
+func splitSpec(spec string) []string {
+	var parts []string
+	for _, part := range strings.Split(spec, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return parts
+}
+
+func (r JobRouting) HasQueue(name string) bool {
+	for _, queue := range r.Queues {
+		if queue.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// QueueFor returns the queue of a job type: its route, else the default
+// queue, else the first queue.
+func (r JobRouting) QueueFor(jobType JobType) string {
+	if name, ok := r.Routes[jobType]; ok {
+		return name
+	}
+	if r.HasQueue(DefaultJobQueueName) {
+		return DefaultJobQueueName
+	}
+	return r.Queues[0].Name
+}
+
+// queueOf returns the queue a job belongs to, re-routing jobs whose queue
+// no longer exists.
+func (r JobRouting) queueOf(job *Job) string {
+	if job.Queue != "" && r.HasQueue(job.Queue) {
+		return job.Queue
+	}
+	return r.QueueFor(job.Type)
+}
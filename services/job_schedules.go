@@ -0,0 +1,156 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// JobSchedule enqueues a copy of Job at every time matching Schedule.
+type JobSchedule struct {
+	Name     string
+	Spec     string
+	Schedule *CronSchedule
+	Job      Job
+}
+
+// CronScheduler fires recurring jobs. Every running instance keeps the same
+// timers; before enqueuing, an instance must win a lock on that occurrence,
+// so each one is enqueued once however many instances are up.
+type CronScheduler struct {
+	queue     JobQueue
+	locks     CacheService
+	lockTTL   time.Duration
+	owner     string
+	schedules []JobSchedule
+	cancel    context.CancelFunc
+	wg        sync.WaitGroup
+}
+
+// NewCronScheduler returns an empty scheduler. Without locks every
+// instance fires every schedule.
+func NewCronScheduler(queue JobQueue, locks CacheService) *CronScheduler {
+	owner, _ := os.Hostname()
+	return &CronScheduler{
+		queue:   queue,
+		locks:   locks,
+		lockTTL: GetEnvDuration("JOB_SCHEDULE_LOCK_TTL", time.Hour),
+		owner:   owner,
+	}
+}
+
+// NewDefaultCronScheduler returns a scheduler with the application's
+// recurring jobs. Each schedule can be changed through its environment
+// variable or disabled with "off".
+func NewDefaultCronScheduler(queue JobQueue, locks CacheService) (*CronScheduler, error) {
+	lowStock, err := json.Marshal(ReportRequest{Report: ReportLowStock})
+	if err != nil {
+		return nil, err
+	}
+
+	defaults := []struct {
+		name, env, spec string
+		job             Job
+	}{
+		{"data_cleanup", "JOB_SCHEDULE_DATA_CLEANUP", "0 3 * * *", Job{Type: JobTypeDataCleanup}},
+		{"low_stock_report", "JOB_SCHEDULE_LOW_STOCK_REPORT", "@hourly", Job{Type: JobTypeReportGenerate, Payload: lowStock}},
+	}
+
+	s := NewCronScheduler(queue, locks)
+	for _, d := range defaults {
+		spec := getEnv(d.env, d.spec)
+		if spec == "off" {
+			continue
+		}
+		if err := s.Add(d.name, spec, d.job); err != nil {
+			return nil, fmt.Errorf("%s: %w", d.env, err)
+		}
+	}
+	return s, nil
+}
+
+func (s *CronScheduler) Add(name, spec string, job Job) error {
+	schedule, err := ParseCronSchedule(spec)
+	if err != nil {
+		return err
+	}
+	s.schedules = append(s.schedules, JobSchedule{Name: name, Spec: spec, Schedule: schedule, Job: job})
+	return nil
+}
+
+// Schedules lists the registered schedules.
+func (s *CronScheduler) Schedules() []JobSchedule {
+	return append([]JobSchedule(nil), s.schedules...)
+}
+
+func (s *CronScheduler) Start(ctx context.Context) {
+	ctx, s.cancel = context.WithCancel(ctx)
+
+	for _, schedule := range s.schedules {
+		s.wg.Add(1)
+		go func(schedule JobSchedule) {
+			defer s.wg.Done()
+			s.loop(ctx, schedule)
+		}(schedule)
+	}
+
+	log.Info().Int("schedules", len(s.schedules)).Msg("Job schedules started")
+}
+
+func (s *CronScheduler) Stop() {
+	if s.cancel == nil {
+		return
+	}
+	s.cancel()
+	s.wg.Wait()
+	log.Info().Msg("Job schedules stopped")
+}
+
+func (s *CronScheduler) loop(ctx context.Context, schedule JobSchedule) {
+	for {
+		next := schedule.Schedule.Next(time.Now())
+		if next.IsZero() {
+			log.Warn().Str("schedule", schedule.Name).Msg("Schedule never fires again")
+			return
+		}
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		if _, err := s.Fire(ctx, schedule, next); err != nil {
+			log.Error().Err(err).Str("schedule", schedule.Name).Msg("Failed to enqueue scheduled job")
+		}
+	}
+}
+
+// Fire enqueues the job of the occurrence at the given time unless another
+// instance already claimed it. It reports whether this call enqueued it.
+func (s *CronScheduler) Fire(ctx context.Context, schedule JobSchedule, at time.Time) (bool, error) {
+	if s.locks != nil {
+		key := fmt.Sprintf("job_schedule:%s:%d", schedule.Name, at.Unix())
+		acquired, err := s.locks.SetNX(ctx, key, s.owner, s.lockTTL)
+		if err != nil {
+			return false, err
+		}
+		if !acquired {
+			return false, nil
+		}
+	}
+
+	if err := s.queue.Enqueue(ctx, schedule.Job); err != nil {
+		return false, err
+	}
+
+	log.Info().Str("schedule", schedule.Name).Time("at", at).Msg("Scheduled job enqueued")
+	return true, nil
+}
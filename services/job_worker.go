@@ -0,0 +1,355 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"sort"
+	"sync"
+	"time"
+
+	"smart-choice/metrics"
+
+	"github.com/rs/zerolog/log"
+)
+
+// JobHandler processes one job. A returned error fails the job.
+type JobHandler func(ctx context.Context, job *Job) error
+
+var ErrNoJobHandler = errors.New("no handler registered for job type")
+
+// JobRegistry maps job types to their handlers.
+type JobRegistry struct {
+	mu       sync.RWMutex
+	handlers map[JobType]JobHandler
+}
+
+func NewJobRegistry() *JobRegistry {
+	return &JobRegistry{handlers: make(map[JobType]JobHandler)}
+}
+
+// NewDefaultJobRegistry returns a registry with the application's job
+// handlers registered.
+func NewDefaultJobRegistry() *JobRegistry {
+	r := NewJobRegistry()
+	r.Register(JobTypeEmailSend, HandleEmailSendJob)
+	r.Register(JobTypeReportGenerate, HandleReportGenerateJob)
+	r.Register(JobTypeDataCleanup, HandleDataCleanupJob)
+	r.Register(JobTypeDataExport, HandleDataExportJob)
+	r.Register(JobTypeReconciliation, HandleReconciliationJob)
+	return r
+}
+
+func (r *JobRegistry) Register(jobType JobType, handler JobHandler) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.handlers[jobType] = handler
+}
+
+func (r *JobRegistry) Handler(jobType JobType) (JobHandler, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	handler, ok := r.handlers[jobType]
+	return handler, ok
+}
+
+// Types lists the registered job types in name order.
+func (r *JobRegistry) Types() []JobType {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	types := make([]JobType, 0, len(r.handlers))
+	for jobType := range r.handlers {
+		types = append(types, jobType)
+	}
+	sort.Slice(types, func(i, j int) bool { return types[i] < types[j] })
+	return types
+}
+
+type WorkerPoolConfig struct {
+	Concurrency int
+	JobTimeout  time.Duration
+	// ErrorBackoff is how long a worker waits after the queue itself fails
+	// before dequeuing again.
+	ErrorBackoff time.Duration
+	// PollInterval is how long a worker waits when every named queue it
+	// may take from is empty.
+	PollInterval time.Duration
+}
+
+func DefaultWorkerPoolConfig() WorkerPoolConfig {
+	return WorkerPoolConfig{
+		Concurrency:  getEnvInt("JOB_WORKER_CONCURRENCY", 4),
+		JobTimeout:   GetEnvDuration("JOB_TIMEOUT", 5*time.Minute),
+		ErrorBackoff: GetEnvDuration("JOB_ERROR_BACKOFF", time.Second),
+		PollInterval: GetEnvDuration("JOB_POLL_INTERVAL", 500*time.Millisecond),
+	}
+}
+
+// WorkerPool consumes jobs from a JobQueue with a fixed number of workers.
+// When the queue has named queues, workers share them by weight and respect
+// each queue's concurrency limit.
+type WorkerPool struct {
+	queue    JobQueue
+	registry *JobRegistry
+	config   WorkerPoolConfig
+	// multi and selector are set when the queue is a MultiJobQueue.
+	multi    MultiJobQueue
+	selector *queueSelector
+
+	cancel context.CancelFunc
+	// abort cancels the contexts of in-flight jobs when Stop gives up
+	// waiting for them.
+	abort context.CancelFunc
+	jobs  context.Context
+	wg    sync.WaitGroup
+}
+
+func NewWorkerPool(queue JobQueue, registry *JobRegistry, config WorkerPoolConfig) *WorkerPool {
+	if config.Concurrency <= 0 {
+		config.Concurrency = 1
+	}
+	if config.ErrorBackoff <= 0 {
+		config.ErrorBackoff = time.Second
+	}
+	if config.PollInterval <= 0 {
+		config.PollInterval = 500 * time.Millisecond
+	}
+
+	jobs, abort := context.WithCancel(context.Background())
+	p := &WorkerPool{queue: queue, registry: registry, config: config, jobs: jobs, abort: abort}
+	if multi, ok := queue.(MultiJobQueue); ok {
+		p.multi = multi
+		p.selector = newQueueSelector(multi.Queues())
+	}
+	return p
+}
+
+func (p *WorkerPool) Start(ctx context.Context) {
+	ctx, p.cancel = context.WithCancel(ctx)
+
+	for i := 0; i < p.config.Concurrency; i++ {
+		p.wg.Add(1)
+		go func(worker int) {
+			defer p.wg.Done()
+			p.loop(ctx, worker)
+		}(i)
+	}
+
+	log.Info().Int("workers", p.config.Concurrency).Msg("Job worker pool started")
+}
+
+// Stop stops dequeuing and waits for in-flight jobs to finish. If ctx ends
+// first the running jobs are cancelled and ctx's error is returned.
+func (p *WorkerPool) Stop(ctx context.Context) error {
+	if p.cancel == nil {
+		return nil
+	}
+	p.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		log.Info().Msg("Job worker pool stopped")
+		return nil
+	case <-ctx.Done():
+		p.abort()
+		log.Warn().Msg("Job worker pool stopped before in-flight jobs finished")
+		return ctx.Err()
+	}
+}
+
+func (p *WorkerPool) loop(ctx context.Context, worker int) {
+	for {
+		if ctx.Err() != nil {
+			return
+		}
+
+		if p.selector != nil {
+			p.next(ctx, worker)
+			continue
+		}
+
+		job, err := p.queue.Dequeue(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			log.Error().Err(err).Int("worker", worker).Msg("Failed to dequeue job")
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(p.config.ErrorBackoff):
+			}
+			continue
+		}
+		if job == nil {
+			continue
+		}
+
+		p.Process(job)
+	}
+}
+
+// next runs one job from the named queues, trying them in weighted order.
+// It waits a poll interval when none has a job or a free slot.
+func (p *WorkerPool) next(ctx context.Context, worker int) {
+	tried := make([]bool, len(p.selector.queues))
+	for {
+		i := p.selector.acquire(tried)
+		if i < 0 {
+			break
+		}
+
+		queue := p.selector.queues[i].Name
+		job, err := p.multi.DequeueFrom(ctx, queue)
+		if err != nil {
+			p.selector.release(i)
+			if ctx.Err() != nil {
+				return
+			}
+			log.Error().Err(err).Int("worker", worker).Str("queue", queue).Msg("Failed to dequeue job")
+			p.wait(ctx, p.config.ErrorBackoff)
+			return
+		}
+		if job == nil {
+			p.selector.release(i)
+			tried[i] = true
+			continue
+		}
+
+		p.Process(job)
+		p.selector.release(i)
+		return
+	}
+
+	p.wait(ctx, p.config.PollInterval)
+}
+
+func (p *WorkerPool) wait(ctx context.Context, d time.Duration) {
+	select {
+	case <-ctx.Done():
+	case <-time.After(d):
+	}
+}
+
+// queueSelector picks named queues by smooth weighted round robin, so that
+// busy queues are served in proportion to their weights without bursts,
+// and skips queues already running their maximum number of jobs.
+type queueSelector struct {
+	mu       sync.Mutex
+	queues   []JobQueueSpec
+	current  []int
+	inFlight []int
+}
+
+func newQueueSelector(queues []JobQueueSpec) *queueSelector {
+	return &queueSelector{
+		queues:   queues,
+		current:  make([]int, len(queues)),
+		inFlight: make([]int, len(queues)),
+	}
+}
+
+// acquire reserves a slot on the next queue not in skip and returns its
+// index, or -1 when every such queue is full.
+func (s *queueSelector) acquire(skip []bool) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	best, total := -1, 0
+	for i, queue := range s.queues {
+		if skip[i] || (queue.Concurrency > 0 && s.inFlight[i] >= queue.Concurrency) {
+			continue
+		}
+		s.current[i] += queue.Weight
+		total += queue.Weight
+		if best < 0 || s.current[i] > s.current[best] {
+			best = i
+		}
+	}
+	if best < 0 {
+		return -1
+	}
+
+	s.current[best] -= total
+	s.inFlight[best]++
+	return best
+}
+
+func (s *queueSelector) release(i int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.inFlight[i]--
+}
+
+// Process runs the job's handler and reports the outcome to the queue.
+// Handlers get their own context bounded by the job timeout, so stopping
+// the pool does not interrupt them. A job that fails because Stop gave up
+// waiting is handed back to the queue without using up an attempt.
+func (p *WorkerPool) Process(job *Job) error {
+	logger := log.With().Str("job_id", job.ID).Str("job_type", string(job.Type)).Int("attempt", job.Attempts).Logger()
+	start := time.Now()
+
+	err := p.run(job)
+	metrics.JobDuration.WithLabelValues(string(job.Type)).Observe(time.Since(start).Seconds())
+
+	// The queue is updated even while the pool is stopping.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err != nil && p.jobs.Err() != nil {
+		if releaser, ok := p.queue.(JobReleaser); ok {
+			logger.Warn().Err(err).Dur("duration", time.Since(start)).Msg("Job interrupted by shutdown, re-queued")
+			if releaseErr := releaser.Release(ctx, job.ID); releaseErr != nil {
+				logger.Error().Err(releaseErr).Msg("Failed to re-queue interrupted job")
+			}
+			return err
+		}
+	}
+
+	if err != nil {
+		metrics.JobsProcessedTotal.WithLabelValues(string(job.Type), "failed").Inc()
+		logger.Error().Err(err).Dur("duration", time.Since(start)).Msg("Job failed")
+		if failErr := p.queue.Fail(ctx, job.ID, err.Error()); failErr != nil {
+			logger.Error().Err(failErr).Msg("Failed to mark job as failed")
+		}
+		return err
+	}
+
+	metrics.JobsProcessedTotal.WithLabelValues(string(job.Type), "completed").Inc()
+	logger.Info().Dur("duration", time.Since(start)).Msg("Job completed")
+	if err := p.queue.Complete(ctx, job.ID); err != nil {
+		logger.Error().Err(err).Msg("Failed to mark job as completed")
+	}
+	return nil
+}
+
+func (p *WorkerPool) run(job *Job) (err error) {
+	handler, ok := p.registry.Handler(job.Type)
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrNoJobHandler, job.Type)
+	}
+
+	ctx := p.jobs
+	if p.config.JobTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.config.JobTimeout)
+		defer cancel()
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			log.Error().Str("job_id", job.ID).Bytes("stack", debug.Stack()).Msg("Job handler panicked")
+			err = fmt.Errorf("job handler panicked: %v", r)
+		}
+	}()
+
+	return handler(ctx, job)
+}
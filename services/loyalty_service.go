@@ -0,0 +1,471 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"smart-choice/database"
+	"smart-choice/models"
+	"smart-choice/repository"
+
+	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrInvalidLoyaltyPoints     = errors.New("loyalty points to redeem must be positive")
+	ErrLoyaltyPointsUnavailable = errors.New("not enough loyalty points")
+	ErrLoyaltyRuleNotFound      = errors.New("loyalty rule not found")
+)
+
+// LoyaltySettings are the program's earn and burn rates.
+type LoyaltySettings struct {
+	PointsPerReal  float64
+	PointValue     float64
+	MaxRedeemRatio float64
+	Validity       time.Duration
+}
+
+func DefaultLoyaltySettings() LoyaltySettings {
+	return LoyaltySettings{
+		PointsPerReal:  getEnvFloat("LOYALTY_POINTS_PER_REAL", 1),
+		PointValue:     getEnvFloat("LOYALTY_POINT_VALUE", 0.05),
+		MaxRedeemRatio: getEnvFloat("LOYALTY_MAX_REDEEM_RATIO", 0.5),
+		Validity:       GetEnvDuration("LOYALTY_POINTS_VALIDITY", 365*24*time.Hour),
+	}
+}
+
+// LoyaltyLine is the amount paid for products of one category.
+type LoyaltyLine struct {
+	Category string
+	Amount   float64
+}
+
+// LoyaltyStatement is a user's points balance and ledger.
+type LoyaltyStatement struct {
+	Balance    int                   `json:"balance"`
+	PointValue float64               `json:"point_value"`
+	Entries    []models.LoyaltyEntry `json:"entries"`
+}
+
+// CalculateLoyaltyPoints works out the points an order earns: the base rate
+// plus any category bonus per real, times the highest campaign multiplier
+// running at the time. Fractions of a point are dropped.
+func CalculateLoyaltyPoints(lines []LoyaltyLine, rules []models.LoyaltyRule, settings LoyaltySettings, at time.Time) int {
+	multiplier := 1.0
+	for i := range rules {
+		if rules[i].Type == models.LoyaltyRuleCampaign && rules[i].ActiveAt(at) && rules[i].Multiplier > multiplier {
+			multiplier = rules[i].Multiplier
+		}
+	}
+
+	var points float64
+	for _, line := range lines {
+		perReal := settings.PointsPerReal
+		for i := range rules {
+			rule := &rules[i]
+			if rule.Type == models.LoyaltyRuleCategoryBonus && rule.ActiveAt(at) &&
+				line.Category != "" && strings.EqualFold(rule.Category, line.Category) {
+				perReal += rule.BonusPerReal
+			}
+		}
+		points += line.Amount * perReal
+	}
+
+	return int(math.Floor(points*multiplier + 1e-9))
+}
+
+// LoyaltyRedemption caps a redemption so points never pay more than
+// MaxRedeemRatio of the total. It returns the points actually used and the
+// discount they give.
+func LoyaltyRedemption(points int, total float64, settings LoyaltySettings) (int, float64) {
+	if points <= 0 || settings.PointValue <= 0 {
+		return 0, 0
+	}
+
+	maxDiscount := roundCents(total * settings.MaxRedeemRatio)
+	if roundCents(float64(points)*settings.PointValue) > maxDiscount {
+		points = int(math.Floor(maxDiscount/settings.PointValue + 1e-9))
+	}
+	return points, roundCents(float64(points) * settings.PointValue)
+}
+
+// LoyaltyClawback is how many of the points an order earned a refund takes
+// back: the points not taken back yet, in the proportion the refund bears to
+// what the order had left to refund before it.
+func LoyaltyClawback(earned, clawedBack int, amount, refundableBefore float64) int {
+	left := earned - clawedBack
+	if left <= 0 || amount <= 0 || refundableBefore <= 0 {
+		return 0
+	}
+	return int(math.Round(float64(left) * math.Min(amount/refundableBefore, 1)))
+}
+
+func GetLoyaltyStatement(userID uint) (*LoyaltyStatement, error) {
+	account, err := repository.GetLoyaltyAccount(database.DB, userID)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	entries, err := repository.GetLoyaltyEntries(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &LoyaltyStatement{
+		Balance:    account.Balance,
+		PointValue: DefaultLoyaltySettings().PointValue,
+		Entries:    entries,
+	}, nil
+}
+
+// quoteLoyaltyRedemptionTx checks the user has the points and prices them
+// against the order total. Points past their expiry are expired first, so
+// they cannot be spent before the scheduled task gets to them. The account
+// stays locked until the transaction ends, so redeemLoyaltyPointsTx can
+// spend them afterwards.
+func quoteLoyaltyRedemptionTx(tx *gorm.DB, userID uint, points int, total float64) (int, float64, error) {
+	if points < 0 {
+		return 0, 0, ErrInvalidLoyaltyPoints
+	}
+
+	account, err := lockLoyaltyAccount(tx, userID)
+	if err != nil {
+		return 0, 0, err
+	}
+	expired, err := expireDueLoyaltyEntriesTx(tx, userID)
+	if err != nil {
+		return 0, 0, err
+	}
+	account.Balance -= expired
+	if account.Balance < points {
+		return 0, 0, ErrLoyaltyPointsUnavailable
+	}
+
+	used, discount := LoyaltyRedemption(points, total, DefaultLoyaltySettings())
+	return used, discount, nil
+}
+
+// redeemLoyaltyPointsTx spends points on an order, consuming the earned
+// entries that expire first.
+func redeemLoyaltyPointsTx(tx *gorm.DB, userID, orderID uint, points int) error {
+	if err := moveLoyaltyBalanceTx(tx, userID, -points); err != nil {
+		return err
+	}
+	if err := consumeLoyaltyEntriesTx(tx, userID, points); err != nil {
+		return err
+	}
+
+	return tx.Create(&models.LoyaltyEntry{
+		UserID:      userID,
+		Type:        models.LoyaltyEntryRedeem,
+		Points:      -points,
+		OrderID:     &orderID,
+		Description: fmt.Sprintf("Redeemed on order %d", orderID),
+	}).Error
+}
+
+// consumeLoyaltyEntriesTx takes points debited from the balance out of the
+// earned entries that expire first, so they are not expired again later.
+func consumeLoyaltyEntriesTx(tx *gorm.DB, userID uint, points int) error {
+	var earned []models.LoyaltyEntry
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("user_id = ? AND remaining > 0 AND (expires_at IS NULL OR expires_at > ?)", userID, time.Now()).
+		Order("expires_at asc, id asc").Find(&earned).Error; err != nil {
+		return err
+	}
+
+	left := points
+	for i := range earned {
+		if left == 0 {
+			break
+		}
+		take := min(earned[i].Remaining, left)
+		if err := tx.Model(&earned[i]).UpdateColumn("remaining", gorm.Expr("remaining - ?", take)).Error; err != nil {
+			return err
+		}
+		left -= take
+	}
+	return nil
+}
+
+// restoreLoyaltyPointsTx gives back the points a canceled order redeemed.
+func restoreLoyaltyPointsTx(tx *gorm.DB, order *models.Order) error {
+	var net int
+	if err := tx.Model(&models.LoyaltyEntry{}).
+		Where("order_id = ? AND type IN ?", order.ID, []string{models.LoyaltyEntryRedeem, models.LoyaltyEntryRestore}).
+		Select("coalesce(sum(points), 0)").Row().Scan(&net); err != nil {
+		return err
+	}
+	if net >= 0 {
+		return nil
+	}
+
+	return creditLoyaltyPointsTx(tx, order.UserID, &order.ID, models.LoyaltyEntryRestore, -net,
+		fmt.Sprintf("Restored from canceled order %d", order.ID))
+}
+
+// earnLoyaltyPointsTx credits the points of a delivered order. The amount
+// actually paid, net of refunds, is spread over the lines by value so that
+// category bonuses follow what was bought.
+func earnLoyaltyPointsTx(tx *gorm.DB, order *models.Order) error {
+	if order.LoyaltyPointsEarned > 0 {
+		return nil
+	}
+
+	var items []models.OrderItem
+	if err := tx.Preload("Product").Where("order_id = ?", order.ID).Find(&items).Error; err != nil {
+		return err
+	}
+
+	var refunded float64
+	if err := tx.Model(&models.Refund{}).
+		Where("order_id = ? AND status IN ?", order.ID, []string{models.RefundStatusPending, models.RefundStatusSucceeded}).
+		Select("coalesce(sum(amount), 0)").Row().Scan(&refunded); err != nil {
+		return err
+	}
+
+	var gross float64
+	for i := range items {
+		gross += items[i].Price*float64(items[i].Quantity) - items[i].Discount
+	}
+	paid := order.Total - refunded
+	if gross <= 0 || paid <= 0 {
+		return nil
+	}
+
+	lines := make([]LoyaltyLine, 0, len(items))
+	for i := range items {
+		lineAmount := items[i].Price*float64(items[i].Quantity) - items[i].Discount
+		lines = append(lines, LoyaltyLine{
+			Category: items[i].Product.Category,
+			Amount:   lineAmount / gross * paid,
+		})
+	}
+
+	rules, err := repository.GetActiveLoyaltyRules(tx)
+	if err != nil {
+		return err
+	}
+
+	points := CalculateLoyaltyPoints(lines, rules, DefaultLoyaltySettings(), time.Now())
+	if points <= 0 {
+		return nil
+	}
+
+	if err := creditLoyaltyPointsTx(tx, order.UserID, &order.ID, models.LoyaltyEntryEarn, points,
+		fmt.Sprintf("Earned on order %d", order.ID)); err != nil {
+		return err
+	}
+
+	order.LoyaltyPointsEarned = points
+	return tx.Model(order).UpdateColumn("loyalty_points_earned", points).Error
+}
+
+// clawbackLoyaltyPointsTx takes back the share of a delivered order's points
+// that a refund of amount paid for, out of the refundable balance the order
+// had before it. Points the customer already spent are not recovered: the
+// debit stops at the balance, which never goes negative.
+func clawbackLoyaltyPointsTx(tx *gorm.DB, order *models.Order, amount, refundableBefore float64) error {
+	if order.LoyaltyPointsEarned <= 0 {
+		return nil
+	}
+
+	var clawedBack int
+	if err := tx.Model(&models.LoyaltyEntry{}).
+		Where("order_id = ? AND type = ?", order.ID, models.LoyaltyEntryClawback).
+		Select("coalesce(sum(points), 0)").Row().Scan(&clawedBack); err != nil {
+		return err
+	}
+	points := LoyaltyClawback(order.LoyaltyPointsEarned, -clawedBack, amount, refundableBefore)
+	if points <= 0 {
+		return nil
+	}
+
+	account, err := lockLoyaltyAccount(tx, order.UserID)
+	if err != nil {
+		return err
+	}
+	expired, err := expireDueLoyaltyEntriesTx(tx, order.UserID)
+	if err != nil {
+		return err
+	}
+	points = min(points, account.Balance-expired)
+	if points <= 0 {
+		return nil
+	}
+
+	if err := moveLoyaltyBalanceTx(tx, order.UserID, -points); err != nil {
+		return err
+	}
+	if err := consumeLoyaltyEntriesTx(tx, order.UserID, points); err != nil {
+		return err
+	}
+	return tx.Create(&models.LoyaltyEntry{
+		UserID:      order.UserID,
+		Type:        models.LoyaltyEntryClawback,
+		Points:      -points,
+		OrderID:     &order.ID,
+		Description: fmt.Sprintf("Taken back for refund of order %d", order.ID),
+	}).Error
+}
+
+func creditLoyaltyPointsTx(tx *gorm.DB, userID uint, orderID *uint, entryType string, points int, description string) error {
+	if err := moveLoyaltyBalanceTx(tx, userID, points); err != nil {
+		return err
+	}
+
+	expiresAt := time.Now().Add(DefaultLoyaltySettings().Validity)
+	return tx.Create(&models.LoyaltyEntry{
+		UserID:      userID,
+		Type:        entryType,
+		Points:      points,
+		Remaining:   points,
+		OrderID:     orderID,
+		ExpiresAt:   &expiresAt,
+		Description: description,
+	}).Error
+}
+
+// moveLoyaltyBalanceTx changes the balance by points. Debits are guarded so
+// the balance never goes negative.
+func moveLoyaltyBalanceTx(tx *gorm.DB, userID uint, points int) error {
+	account, err := lockLoyaltyAccount(tx, userID)
+	if err != nil {
+		return err
+	}
+
+	result := tx.Model(&models.LoyaltyAccount{}).
+		Where("id = ? AND balance + ? >= 0", account.ID, points).
+		UpdateColumn("balance", gorm.Expr("balance + ?", points))
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrLoyaltyPointsUnavailable
+	}
+	return nil
+}
+
+func lockLoyaltyAccount(tx *gorm.DB, userID uint) (*models.LoyaltyAccount, error) {
+	if err := tx.Clauses(clause.OnConflict{Columns: []clause.Column{{Name: "user_id"}}, DoNothing: true}).
+		Create(&models.LoyaltyAccount{UserID: userID}).Error; err != nil {
+		return nil, err
+	}
+
+	var account models.LoyaltyAccount
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("user_id = ?", userID).First(&account).Error; err != nil {
+		return nil, err
+	}
+	return &account, nil
+}
+
+// ExpireLoyaltyPoints removes the unspent points of earn entries past their
+// expiry. It runs as a scheduled task.
+func ExpireLoyaltyPoints(ctx context.Context) error {
+	entries, err := repository.GetExpiredLoyaltyEntries(time.Now(), 500)
+	if err != nil {
+		return err
+	}
+
+	expired := 0
+	for _, entry := range entries {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		err := database.DB.Transaction(func(tx *gorm.DB) error {
+			// Lock the account before the entry, in the same order as
+			// redemptions do.
+			if _, err := lockLoyaltyAccount(tx, entry.UserID); err != nil {
+				return err
+			}
+
+			var current models.LoyaltyEntry
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&current, entry.ID).Error; err != nil {
+				return err
+			}
+			_, err := expireLoyaltyEntryTx(tx, &current)
+			return err
+		})
+		if err != nil {
+			log.Error().Err(err).Uint("entry_id", entry.ID).Msg("Failed to expire loyalty points")
+			continue
+		}
+		expired++
+	}
+
+	if expired > 0 {
+		log.Info().Int("entries", expired).Msg("Expired loyalty points")
+	}
+	return nil
+}
+
+// expireDueLoyaltyEntriesTx expires the user's earned points past their
+// expiry and returns how many points went. The caller holds the account
+// lock.
+func expireDueLoyaltyEntriesTx(tx *gorm.DB, userID uint) (int, error) {
+	var due []models.LoyaltyEntry
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("user_id = ? AND remaining > 0 AND expires_at <= ?", userID, time.Now()).
+		Order("id asc").Find(&due).Error; err != nil {
+		return 0, err
+	}
+
+	expired := 0
+	for i := range due {
+		points, err := expireLoyaltyEntryTx(tx, &due[i])
+		if err != nil {
+			return 0, err
+		}
+		expired += points
+	}
+	return expired, nil
+}
+
+// expireLoyaltyEntryTx removes the unspent points of a locked earn entry and
+// returns how many there were. The caller holds the account lock.
+func expireLoyaltyEntryTx(tx *gorm.DB, entry *models.LoyaltyEntry) (int, error) {
+	if entry.Remaining <= 0 {
+		return 0, nil
+	}
+
+	points := entry.Remaining
+	if err := moveLoyaltyBalanceTx(tx, entry.UserID, -points); err != nil {
+		return 0, err
+	}
+	if err := tx.Model(entry).UpdateColumn("remaining", 0).Error; err != nil {
+		return 0, err
+	}
+	return points, tx.Create(&models.LoyaltyEntry{
+		UserID:      entry.UserID,
+		Type:        models.LoyaltyEntryExpire,
+		Points:      -points,
+		OrderID:     entry.OrderID,
+		Description: fmt.Sprintf("Expired points from entry %d", entry.ID),
+	}).Error
+}
+
+func ListLoyaltyRules() ([]models.LoyaltyRule, error) {
+	return repository.GetLoyaltyRules()
+}
+
+func CreateLoyaltyRule(rule *models.LoyaltyRule) error {
+	return database.DB.Create(rule).Error
+}
+
+func DeleteLoyaltyRule(id uint) error {
+	result := database.DB.Delete(&models.LoyaltyRule{}, id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrLoyaltyRuleNotFound
+	}
+	return nil
+}
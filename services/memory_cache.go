@@ -0,0 +1,192 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const memorySweepInterval = time.Minute
+
+type memoryCacheEntry struct {
+	value     []byte
+	expiresAt time.Time // zero means no expiry
+}
+
+func (e memoryCacheEntry) expired(now time.Time) bool {
+	return !e.expiresAt.IsZero() && !now.Before(e.expiresAt)
+}
+
+// MemoryCache is an in-process CacheService for single-node deployments,
+// local development and tests. Values are stored as JSON, so Get returns
+// the same shapes as RedisCache.
+type MemoryCache struct {
+	mu        sync.Mutex
+	entries   map[string]memoryCacheEntry
+	lastSweep time.Time
+}
+
+func NewMemoryCache() *MemoryCache {
+	return &MemoryCache{entries: make(map[string]memoryCacheEntry), lastSweep: time.Now()}
+}
+
+func (m *MemoryCache) Set(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
+	entry, err := newMemoryCacheEntry(value, ttl)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sweep()
+	m.entries[key] = entry
+	return nil
+}
+
+func (m *MemoryCache) SetNX(ctx context.Context, key string, value interface{}, ttl time.Duration) (bool, error) {
+	entry, err := newMemoryCacheEntry(value, ttl)
+	if err != nil {
+		return false, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sweep()
+	if _, ok := m.lookup(key); ok {
+		return false, nil
+	}
+	m.entries[key] = entry
+	return true, nil
+}
+
+func (m *MemoryCache) Get(ctx context.Context, key string) (interface{}, bool) {
+	m.mu.Lock()
+	entry, ok := m.lookup(key)
+	m.mu.Unlock()
+	if !ok {
+		return nil, false
+	}
+
+	var result interface{}
+	if err := json.Unmarshal(entry.value, &result); err != nil {
+		log.Error().Err(err).Str("key", key).Msg("Cache unmarshal error")
+		return nil, false
+	}
+	return result, true
+}
+
+func (m *MemoryCache) Delete(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.entries, key)
+	return nil
+}
+
+// Clear deletes the keys matching a Redis-style glob pattern.
+func (m *MemoryCache) Clear(ctx context.Context, pattern string) error {
+	matcher, err := globPattern(pattern)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for key := range m.entries {
+		if matcher.MatchString(key) {
+			delete(m.entries, key)
+		}
+	}
+	return nil
+}
+
+func (m *MemoryCache) Exists(ctx context.Context, key string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, ok := m.lookup(key)
+	return ok
+}
+
+// lookup returns a live entry, dropping it if it has expired. The caller
+// holds m.mu.
+func (m *MemoryCache) lookup(key string) (memoryCacheEntry, bool) {
+	entry, ok := m.entries[key]
+	if ok && entry.expired(time.Now()) {
+		delete(m.entries, key)
+		return memoryCacheEntry{}, false
+	}
+	return entry, ok
+}
+
+// sweep drops expired entries that were never read again, at most once per
+// memorySweepInterval. The caller holds m.mu.
+func (m *MemoryCache) sweep() {
+	now := time.Now()
+	if now.Sub(m.lastSweep) < memorySweepInterval {
+		return
+	}
+	m.lastSweep = now
+
+	for key, entry := range m.entries {
+		if entry.expired(now) {
+			delete(m.entries, key)
+		}
+	}
+}
+
+func newMemoryCacheEntry(value interface{}, ttl time.Duration) (memoryCacheEntry, error) {
+	jsonValue, err := json.Marshal(value)
+	if err != nil {
+		return memoryCacheEntry{}, err
+	}
+
+	entry := memoryCacheEntry{value: jsonValue}
+	if ttl > 0 {
+		entry.expiresAt = time.Now().Add(ttl)
+	}
+	return entry, nil
+}
+
+// globPattern compiles a Redis KEYS pattern: * matches any run of
+// characters, ? a single one, [...] a set and \ escapes the next character.
+func globPattern(pattern string) (*regexp.Regexp, error) {
+	var b strings.Builder
+	b.WriteString("(?s)^")
+
+	for i := 0; i < len(pattern); i++ {
+		switch pattern[i] {
+		case '*':
+			b.WriteString(".*")
+		case '?':
+			b.WriteString(".")
+		case '[':
+			end := strings.IndexByte(pattern[i+1:], ']')
+			if end < 0 {
+				b.WriteString(regexp.QuoteMeta("["))
+				continue
+			}
+			class := pattern[i+1 : i+1+end]
+			if strings.HasPrefix(class, "^") {
+				class = "^" + regexp.QuoteMeta(class[1:])
+			} else {
+				class = regexp.QuoteMeta(class)
+			}
+			b.WriteString("[" + class + "]")
+			i += end + 1
+		case '\\':
+			if i+1 < len(pattern) {
+				i++
+			}
+			b.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		default:
+			b.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		}
+	}
+
+	b.WriteString("$")
+	return regexp.Compile(b.String())
+}
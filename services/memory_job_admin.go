@@ -0,0 +1,178 @@
+package services
+
+import (
+	"context"
+	"sort"
+	"time"
+)
+
+func (q *MemoryJobQueue) ListJobs(ctx context.Context, state string, offset, limit int) ([]JobInfo, int64, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	var infos []JobInfo
+	switch state {
+	case JobStateQueued:
+		// Queue by queue, highest weight first, each in the order they run.
+		for _, queue := range q.config.Routing.Queues {
+			infos = append(infos, q.jobInfos(q.ready[queue.Name], state)...)
+		}
+	case JobStateProcessing:
+		infos = q.jobInfos(q.processing, state)
+		for i := range infos {
+			if leaseUntil, ok := q.leases[infos[i].ID]; ok {
+				infos[i].LeaseUntil = &leaseUntil
+			}
+		}
+	case JobStateScheduled:
+		infos = q.delayedInfos(q.scheduled, state)
+	case JobStateRetrying:
+		infos = q.delayedInfos(q.retries, state)
+	case JobStateDead:
+		for _, dead := range q.dead {
+			info := newJobInfo(&dead.Job, JobStateDead)
+			info.LastError = dead.Reason
+			failedAt := dead.FailedAt
+			info.FailedAt = &failedAt
+			infos = append(infos, info)
+		}
+	default:
+		return nil, 0, ErrInvalidJobState
+	}
+
+	total := int64(len(infos))
+	if offset >= len(infos) {
+		return []JobInfo{}, total, nil
+	}
+	end := min(offset+limit, len(infos))
+	return infos[offset:end], total, nil
+}
+
+// jobInfos describes the given jobs, skipping those already gone. The
+// caller holds q.mu.
+func (q *MemoryJobQueue) jobInfos(ids []string, state string) []JobInfo {
+	infos := make([]JobInfo, 0, len(ids))
+	for _, jobID := range ids {
+		if job, ok := q.jobs[jobID]; ok {
+			infos = append(infos, newJobInfo(job, state))
+		}
+	}
+	return infos
+}
+
+// delayedInfos describes delayed jobs in the order they are due. The
+// caller holds q.mu.
+func (q *MemoryJobQueue) delayedInfos(delayed map[string]time.Time, state string) []JobInfo {
+	ids := make([]string, 0, len(delayed))
+	for jobID := range delayed {
+		ids = append(ids, jobID)
+	}
+	sortByTime(ids, delayed)
+
+	infos := q.jobInfos(ids, state)
+	for i := range infos {
+		runAt := delayed[infos[i].ID]
+		infos[i].RunAt = &runAt
+	}
+	return infos
+}
+
+func (q *MemoryJobQueue) RetryDeadJob(ctx context.Context, jobID string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for i := range q.dead {
+		if q.dead[i].Job.ID == jobID {
+			q.revive(i)
+			return nil
+		}
+	}
+	return ErrJobNotFound
+}
+
+func (q *MemoryJobQueue) DiscardDeadJob(ctx context.Context, jobID string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for i := range q.dead {
+		if q.dead[i].Job.ID == jobID {
+			q.dead = append(q.dead[:i], q.dead[i+1:]...)
+			return nil
+		}
+	}
+	return ErrJobNotFound
+}
+
+func (q *MemoryJobQueue) RetryDeadJobs(ctx context.Context, jobType JobType) (int, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	count := 0
+	for i := 0; i < len(q.dead); {
+		if jobType != "" && q.dead[i].Job.Type != jobType {
+			i++
+			continue
+		}
+		q.revive(i)
+		count++
+	}
+	return count, nil
+}
+
+func (q *MemoryJobQueue) DiscardDeadJobs(ctx context.Context, jobType JobType) (int, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	kept := q.dead[:0]
+	for _, dead := range q.dead {
+		if jobType == "" || dead.Job.Type == jobType {
+			continue
+		}
+		kept = append(kept, dead)
+	}
+
+	count := len(q.dead) - len(kept)
+	q.dead = kept
+	return count, nil
+}
+
+// revive puts the dead job at index i back in its ready queue with fresh
+// attempts. The caller holds q.mu.
+func (q *MemoryJobQueue) revive(i int) {
+	dead := q.dead[i]
+	q.dead = append(q.dead[:i], q.dead[i+1:]...)
+
+	job := dead.Job
+	job.Attempts = 0
+	job.LastError = dead.Reason
+	q.jobs[job.ID] = &job
+	q.pushReady(&job)
+}
+
+// PauseJobType stops workers from running jobs of the type; they are set
+// aside and looked at again every PauseRecheck.
+func (q *MemoryJobQueue) PauseJobType(ctx context.Context, jobType JobType) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.paused[jobType] = true
+	return nil
+}
+
+func (q *MemoryJobQueue) ResumeJobType(ctx context.Context, jobType JobType) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	delete(q.paused, jobType)
+	return nil
+}
+
+func (q *MemoryJobQueue) PausedJobTypes(ctx context.Context) ([]JobType, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	types := make([]JobType, 0, len(q.paused))
+	for jobType := range q.paused {
+		types = append(types, jobType)
+	}
+	sort.Slice(types, func(i, j int) bool { return types[i] < types[j] })
+	return types, nil
+}
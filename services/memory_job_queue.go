@@ -0,0 +1,335 @@
+package services
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"smart-choice/metrics"
+
+	"github.com/rs/zerolog/log"
+)
+
+// MemoryJobQueue is an in-process JobQueue with the same semantics as
+// RedisJobQueue: named queues, leases, retries with backoff, delayed jobs,
+// a dead letter queue and paused job types. Jobs are lost when the process
+// exits, so it suits single-node deployments, local development and tests.
+type MemoryJobQueue struct {
+	mu     sync.Mutex
+	config JobQueueConfig
+	// wake is signalled when a job becomes ready, so a waiting Dequeue
+	// does not sleep its whole poll interval.
+	wake chan struct{}
+
+	jobs  map[string]*Job
+	ready map[string][]string
+	// processing holds the IDs of running jobs, newest first.
+	processing []string
+	leases     map[string]time.Time
+	scheduled  map[string]time.Time
+	retries    map[string]time.Time
+	// dead holds failed jobs, newest first.
+	dead   []DeadJob
+	paused map[JobType]bool
+}
+
+func NewMemoryJobQueue() (*MemoryJobQueue, error) {
+	routing, err := DefaultJobRouting()
+	if err != nil {
+		return nil, err
+	}
+
+	config := DefaultJobQueueConfig()
+	config.Routing = routing
+	return NewMemoryJobQueueWithConfig(config), nil
+}
+
+func NewMemoryJobQueueWithConfig(config JobQueueConfig) *MemoryJobQueue {
+	if config.MaxAttempts <= 0 {
+		config.MaxAttempts = 1
+	}
+	if config.PauseRecheck <= 0 {
+		config.PauseRecheck = 30 * time.Second
+	}
+	if config.PollInterval <= 0 {
+		config.PollInterval = 500 * time.Millisecond
+	}
+	if len(config.Routing.Queues) == 0 {
+		config.Routing = builtinJobRouting()
+	}
+
+	return &MemoryJobQueue{
+		config:    config,
+		wake:      make(chan struct{}, 1),
+		jobs:      make(map[string]*Job),
+		ready:     make(map[string][]string),
+		leases:    make(map[string]time.Time),
+		scheduled: make(map[string]time.Time),
+		retries:   make(map[string]time.Time),
+		paused:    make(map[JobType]bool),
+	}
+}
+
+func (q *MemoryJobQueue) Queues() []JobQueueSpec {
+	return append([]JobQueueSpec(nil), q.config.Routing.Queues...)
+}
+
+// Enqueue adds a job to the queue its type is routed to, or holds it until
+// its ScheduledAt when that is in the future.
+func (q *MemoryJobQueue) Enqueue(ctx context.Context, job Job) error {
+	job.ID = generateJobID()
+	job.CreatedAt = time.Now()
+	job.Attempts = 0
+	job.Queue = q.config.Routing.queueOf(&job)
+	if job.MaxAttempts <= 0 {
+		job.MaxAttempts = q.config.MaxAttempts
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.jobs[job.ID] = &job
+	if job.ScheduledAt.After(job.CreatedAt) {
+		q.scheduled[job.ID] = job.ScheduledAt
+		return nil
+	}
+	q.pushReady(&job)
+	return nil
+}
+
+// Dequeue takes the next job from the queues in priority order, waiting up
+// to PollInterval and returning nil when all of them are empty.
+func (q *MemoryJobQueue) Dequeue(ctx context.Context) (*Job, error) {
+	for _, queue := range q.config.Routing.Queues {
+		job, err := q.DequeueFrom(ctx, queue.Name)
+		if err != nil || job != nil {
+			return job, err
+		}
+	}
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-q.wake:
+		return nil, nil
+	case <-time.After(q.config.PollInterval):
+		return nil, nil
+	}
+}
+
+// DequeueFrom takes the next job of a queue and leases it for
+// VisibilityTimeout. Jobs of a paused type are set aside for PauseRecheck
+// and nil is returned.
+func (q *MemoryJobQueue) DequeueFrom(ctx context.Context, queue string) (*Job, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	ids := q.ready[queue]
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	jobID := ids[0]
+	q.ready[queue] = ids[1:]
+
+	job, ok := q.jobs[jobID]
+	if !ok {
+		log.Warn().Str("job_id", jobID).Msg("Dropping job without a stored body")
+		return nil, nil
+	}
+
+	now := time.Now()
+	if q.paused[job.Type] {
+		q.scheduled[jobID] = now.Add(q.config.PauseRecheck)
+		return nil, nil
+	}
+
+	job.Attempts++
+	q.processing = append([]string{jobID}, q.processing...)
+	q.leases[jobID] = now.Add(q.config.VisibilityTimeout)
+
+	taken := *job
+	return &taken, nil
+}
+
+func (q *MemoryJobQueue) Complete(ctx context.Context, jobID string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.removeProcessing(jobID)
+	delete(q.jobs, jobID)
+	return nil
+}
+
+// Fail schedules another attempt with exponential backoff, or moves the
+// whole job to the dead letter queue once it has used all its attempts.
+func (q *MemoryJobQueue) Fail(ctx context.Context, jobID string, reason string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	job, ok := q.jobs[jobID]
+	if !ok {
+		return nil // Already completed or dead-lettered
+	}
+
+	if job.Attempts >= job.MaxAttempts {
+		q.deadLetter(job, reason)
+		return nil
+	}
+
+	if q.removeProcessing(jobID) {
+		job.LastError = reason
+		q.retries[jobID] = time.Now().Add(RetryBackoff(job.Attempts, q.config.RetryBackoff, q.config.MaxRetryBackoff))
+	}
+	return nil
+}
+
+// Release re-queues a processing job without counting the attempt it was
+// dequeued with.
+func (q *MemoryJobQueue) Release(ctx context.Context, jobID string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	job, ok := q.jobs[jobID]
+	if !ok {
+		return nil // Already completed or dead-lettered
+	}
+
+	if q.removeProcessing(jobID) {
+		if job.Attempts > 0 {
+			job.Attempts--
+		}
+		q.pushReady(job)
+	}
+	return nil
+}
+
+// deadLetter moves a processing job to the dead letter queue. The caller
+// holds q.mu.
+func (q *MemoryJobQueue) deadLetter(job *Job, reason string) {
+	if !q.removeProcessing(job.ID) {
+		return
+	}
+	delete(q.jobs, job.ID)
+
+	job.LastError = reason
+	q.dead = append([]DeadJob{{Job: *job, Reason: reason, FailedAt: time.Now()}}, q.dead...)
+
+	metrics.JobsDeadLetteredTotal.WithLabelValues(string(job.Type)).Inc()
+	log.Warn().Str("job_id", job.ID).Str("job_type", string(job.Type)).Str("reason", reason).
+		Msg("Job moved to dead letter queue")
+}
+
+// PromoteDue moves delayed jobs and retries whose time has come to their
+// ready queue.
+func (q *MemoryJobQueue) PromoteDue(ctx context.Context) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	now := time.Now()
+	for _, delayed := range []map[string]time.Time{q.scheduled, q.retries} {
+		for _, jobID := range dueJobs(delayed, now) {
+			delete(delayed, jobID)
+			if job, ok := q.jobs[jobID]; ok {
+				q.pushReady(job)
+			}
+		}
+	}
+	return nil
+}
+
+// Reap re-queues jobs whose lease expired, dead-lettering those out of
+// attempts.
+func (q *MemoryJobQueue) Reap(ctx context.Context) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for _, jobID := range dueJobs(q.leases, time.Now()) {
+		job, ok := q.jobs[jobID]
+		if !ok {
+			q.removeProcessing(jobID)
+			continue
+		}
+
+		if job.Attempts >= job.MaxAttempts {
+			q.deadLetter(job, "lease expired on the last attempt")
+			continue
+		}
+
+		if q.removeProcessing(jobID) {
+			q.pushReady(job)
+			log.Warn().Str("job_id", jobID).Str("job_type", string(job.Type)).Msg("Job lease expired, re-queued")
+		}
+	}
+	return nil
+}
+
+// GetStats counts the jobs in each state, with the same keys as
+// RedisJobQueue.
+func (q *MemoryJobQueue) GetStats(ctx context.Context) (map[string]int, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	stats := make(map[string]int)
+	queueLen := 0
+	for _, queue := range q.config.Routing.Queues {
+		length := len(q.ready[queue.Name])
+		stats["queue_length:"+queue.Name] = length
+		queueLen += length
+	}
+
+	stats["queue_length"] = queueLen
+	stats["processing_length"] = len(q.processing)
+	stats["retry_length"] = len(q.retries)
+	stats["scheduled_length"] = len(q.scheduled)
+	stats["dead_letter_count"] = len(q.dead)
+	return stats, nil
+}
+
+// pushReady appends a job to its ready queue and wakes a waiting Dequeue.
+// The caller holds q.mu.
+func (q *MemoryJobQueue) pushReady(job *Job) {
+	job.Queue = q.config.Routing.queueOf(job)
+	q.ready[job.Queue] = append(q.ready[job.Queue], job.ID)
+
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// removeProcessing takes a job out of the processing list and drops its
+// lease, reporting whether it was there. The caller holds q.mu.
+func (q *MemoryJobQueue) removeProcessing(jobID string) bool {
+	delete(q.leases, jobID)
+	for i, id := range q.processing {
+		if id == jobID {
+			q.processing = append(q.processing[:i], q.processing[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// dueJobs returns the IDs due at or before now, earliest first.
+func dueJobs(delayed map[string]time.Time, now time.Time) []string {
+	var due []string
+	for jobID, at := range delayed {
+		if !at.After(now) {
+			due = append(due, jobID)
+		}
+	}
+	sortByTime(due, delayed)
+	return due
+}
+
+// sortByTime orders job IDs by their time in delayed, then by ID.
+func sortByTime(ids []string, delayed map[string]time.Time) {
+	sort.Slice(ids, func(i, j int) bool {
+		a, b := delayed[ids[i]], delayed[ids[j]]
+		if !a.Equal(b) {
+			return a.Before(b)
+		}
+		return ids[i] < ids[j]
+	})
+}
@@ -0,0 +1,77 @@
+package services
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryRateLimit is an in-process RateLimitService with the same limits
+// as RedisRateLimit. Counters are per process, so each node of a cluster
+// would allow the full limit.
+type MemoryRateLimit struct {
+	limits    rateLimits
+	mu        sync.Mutex
+	counters  map[string]*UserRateLimit
+	lastSweep time.Time
+}
+
+func NewMemoryRateLimit() *MemoryRateLimit {
+	return &MemoryRateLimit{limits: defaultRateLimits(), counters: make(map[string]*UserRateLimit), lastSweep: time.Now()}
+}
+
+func (m *MemoryRateLimit) Allow(ctx context.Context, userID uint, ip string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	m.sweep(now)
+
+	userAllowed := m.counter(userRateLimitKey(userID)).take(m.limits.user, userRequestWindow, now)
+	ipAllowed := m.counter(ipRateLimitKey(ip)).take(m.limits.ip, ipRequestWindow, now)
+
+	return userAllowed && ipAllowed
+}
+
+func (m *MemoryRateLimit) GetRemaining(ctx context.Context, userID uint, ip string) (int, time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var userLimit UserRateLimit
+	if counter, ok := m.counters[userRateLimitKey(userID)]; ok {
+		userLimit = *counter
+	}
+	return userLimit.remaining(m.limits.user, userRequestWindow, time.Now())
+}
+
+func (m *MemoryRateLimit) ResetUserLimits(ctx context.Context, userID uint) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.counters, userRateLimitKey(userID))
+	return nil
+}
+
+// counter returns the counter of a key, creating it. The caller holds m.mu.
+func (m *MemoryRateLimit) counter(key string) *UserRateLimit {
+	counter, ok := m.counters[key]
+	if !ok {
+		counter = &UserRateLimit{}
+		m.counters[key] = counter
+	}
+	return counter
+}
+
+// sweep drops counters whose window is over, at most once per
+// memorySweepInterval. The caller holds m.mu.
+func (m *MemoryRateLimit) sweep(now time.Time) {
+	if now.Sub(m.lastSweep) < memorySweepInterval {
+		return
+	}
+	m.lastSweep = now
+
+	for key, counter := range m.counters {
+		if now.Sub(counter.LastReset) >= time.Duration(counter.Window)*time.Second {
+			delete(m.counters, key)
+		}
+	}
+}
@@ -0,0 +1,468 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"smart-choice/database"
+	"smart-choice/models"
+	"smart-choice/repository"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrOrderNotPayable   = errors.New("order is not awaiting payment")
+	ErrPaymentInProgress = errors.New("order already has a payment in progress")
+)
+
+// OrderPaymentRequest chooses how to pay an order. A gift card and store
+// credit are spent first; whatever they leave is charged with Method.
+type OrderPaymentRequest struct {
+	Method         string `json:"method"`
+	GiftCardCode   string `json:"gift_card_code"`
+	UseStoreCredit bool   `json:"use_store_credit"`
+}
+
+// StartOrderPayment charges the order through the configured PaymentProvider.
+// Authorized intents are captured right away; asynchronous ones stay pending
+// until the provider reports back. Gift card and store credit tenders are
+// settled immediately, and when they cover the whole order no provider is
+// involved. The provider is called outside the database transaction, against
+// a pending payment committed beforehand. The last payment created is
+// returned.
+func StartOrderPayment(ctx context.Context, user *models.User, orderID uint, req OrderPaymentRequest) (*models.Payment, error) {
+	method := req.Method
+	if method == "" {
+		method = models.PaymentMethodCard
+	}
+
+	provider, err := GetPaymentProviderForMethod(method)
+	if err != nil {
+		return nil, err
+	}
+
+	var payment models.Payment
+	charging := false
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		var order models.Order
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("user_id = ?", user.ID).First(&order, orderID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrOrderNotFound
+			}
+			return err
+		}
+
+		if order.Status != models.OrderStatusPending {
+			return ErrOrderNotPayable
+		}
+
+		var active int64
+		if err := tx.Model(&models.Payment{}).
+			Where("order_id = ? AND provider <> ? AND status IN ?", order.ID, models.PaymentProviderInternal,
+				[]string{models.PaymentStatusPending, models.PaymentStatusAuthorized, models.PaymentStatusSucceeded}).
+			Count(&active).Error; err != nil {
+			return err
+		}
+		if active > 0 {
+			return ErrPaymentInProgress
+		}
+
+		paid, err := paidAmount(tx, order.ID)
+		if err != nil {
+			return err
+		}
+		due := roundCents(order.Total - paid)
+
+		if req.GiftCardCode != "" && due > 0 {
+			amount, txn, err := spendGiftCardTx(tx, req.GiftCardCode, order.ID, due)
+			if err != nil {
+				return err
+			}
+			payment, err = createBalancePayment(tx, &order, models.PaymentMethodGiftCard, fmt.Sprintf("giftcard-%d", txn.ID), amount)
+			if err != nil {
+				return err
+			}
+			due = roundCents(due - amount)
+		}
+
+		if req.UseStoreCredit && due > 0 {
+			amount, entry, err := spendStoreCreditTx(tx, user.ID, order.ID, due)
+			if err != nil {
+				return err
+			}
+			payment, err = createBalancePayment(tx, &order, models.PaymentMethodStoreCredit, fmt.Sprintf("storecredit-%d", entry.ID), amount)
+			if err != nil {
+				return err
+			}
+			due = roundCents(due - amount)
+		}
+
+		if due <= 0 {
+			return nil
+		}
+
+		// Only card payments are split; PIX and boleto charge the cash price.
+		// When balances already paid part of the order, the rest is split
+		// over the same number of installments.
+		amount := due
+		var installments uint
+		if method == models.PaymentMethodCard && order.InstallmentPlan.Count > 1 {
+			installments = order.InstallmentPlan.Count
+			if paid == 0 && due == order.Total {
+				amount = order.InstallmentPlan.Total
+			} else {
+				option, err := FindInstallmentOption(due, int(installments), DefaultInstallmentRules())
+				if err != nil {
+					return err
+				}
+				amount = option.Total
+			}
+		}
+
+		// The charge is recorded before the provider is called, so that a
+		// provider that takes the money is never left without a payment
+		// row, and so that it counts as in progress for concurrent requests.
+		payment = models.Payment{
+			OrderID:           order.ID,
+			Provider:          provider.Name(),
+			ProviderReference: "pending_" + uuid.New().String(),
+			Method:            method,
+			Amount:            amount,
+			Currency:          "BRL",
+			Installments:      installments,
+			Status:            models.PaymentStatusPending,
+		}
+		charging = true
+		return tx.Create(&payment).Error
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	if charging {
+		if err := chargePayment(ctx, provider, &payment); err != nil {
+			return nil, err
+		}
+	}
+
+	if payment.PixBRCode != "" {
+		qrCode, err := GeneratePixQRCode(payment.PixBRCode)
+		if err != nil {
+			log.Error().Err(err).Uint("payment_id", payment.ID).Msg("Failed to render PIX QR code")
+		}
+		payment.PixQRCode = qrCode
+	}
+
+	if payment.BoletoBarcode != "" {
+		image, err := GenerateBoletoBarcodeImage(payment.BoletoBarcode)
+		if err != nil {
+			log.Error().Err(err).Uint("payment_id", payment.ID).Msg("Failed to render boleto barcode")
+		}
+		payment.BoletoBarcodeImage = image
+	}
+
+	return &payment, nil
+}
+
+// chargePayment creates and captures the provider intent of a pending
+// payment, then records the result. A failed call marks the payment failed;
+// a charge that cannot be recorded is refunded with the provider so the
+// payer is not charged for a payment the store does not know about.
+func chargePayment(ctx context.Context, provider PaymentProvider, payment *models.Payment) error {
+	intent, err := provider.CreateIntent(ctx, PaymentIntentRequest{
+		OrderID:      payment.OrderID,
+		Amount:       payment.Amount,
+		Currency:     payment.Currency,
+		Method:       payment.Method,
+		Description:  fmt.Sprintf("Smart Choice pedido %d", payment.OrderID),
+		Installments: payment.Installments,
+	})
+	if err == nil && intent.Status == models.PaymentStatusAuthorized {
+		var captured *PaymentIntent
+		captured, err = provider.Capture(ctx, intent.Reference, payment.Amount)
+		if err != nil {
+			// Keep the provider's reference so the authorization can be
+			// found in reconciliation, and release the hold on the card.
+			payment.ProviderReference = intent.Reference
+			releaseAuthorization(ctx, provider, payment, intent.Reference)
+		} else {
+			intent = captured
+		}
+	}
+	if err != nil {
+		failPendingPayment(payment, err)
+		return err
+	}
+
+	payment.ProviderReference = intent.Reference
+	payment.ExpiresAt = intent.ExpiresAt
+	payment.PixBRCode = intent.PixBRCode
+	payment.BoletoBarcode = intent.BoletoBarcode
+	payment.BoletoDigitableLine = intent.BoletoDigitableLine
+	payment.DueDate = intent.DueDate
+	if payment.Method == models.PaymentMethodPix {
+		payment.PixTxID = intent.Reference
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		return applyPaymentIntent(tx, payment, intent)
+	})
+	if err == nil {
+		return nil
+	}
+
+	log.Error().Err(err).Uint("payment_id", payment.ID).Str("reference", intent.Reference).
+		Msg("Failed to record payment, refunding it with the provider")
+	if intent.Status == models.PaymentStatusSucceeded || intent.Status == models.PaymentStatusAuthorized {
+		if _, refundErr := provider.Refund(ctx, intent.Reference, payment.Amount); refundErr != nil {
+			log.Error().Err(refundErr).Uint("payment_id", payment.ID).Str("reference", intent.Reference).
+				Msg("Failed to refund unrecorded payment")
+		}
+	}
+	failPendingPayment(payment, err)
+	return err
+}
+
+// releaseAuthorization voids an authorized intent that could not be
+// captured, or refunds it when the provider cannot void.
+func releaseAuthorization(ctx context.Context, provider PaymentProvider, payment *models.Payment, reference string) {
+	var err error
+	if voider, ok := provider.(PaymentVoider); ok {
+		_, err = voider.Void(ctx, reference)
+	} else {
+		_, err = provider.Refund(ctx, reference, payment.Amount)
+	}
+	if err != nil {
+		log.Error().Err(err).Uint("payment_id", payment.ID).Str("reference", reference).
+			Msg("Failed to release uncaptured authorization")
+	}
+}
+
+// failPendingPayment marks a payment whose provider call did not go through
+// as failed, so the order can be paid again.
+func failPendingPayment(payment *models.Payment, cause error) {
+	payment.Status = models.PaymentStatusFailed
+	payment.FailureReason = cause.Error()
+	if err := database.DB.Model(&models.Payment{}).Where("id = ?", payment.ID).Updates(map[string]interface{}{
+		"status":             payment.Status,
+		"failure_reason":     payment.FailureReason,
+		"provider_reference": payment.ProviderReference,
+	}).Error; err != nil {
+		log.Error().Err(err).Uint("payment_id", payment.ID).Msg("Failed to mark payment as failed")
+	}
+}
+
+// ListOrderPayments returns the payments of one of the user's orders,
+// refreshing any that are still pending with the provider.
+func ListOrderPayments(ctx context.Context, userID, orderID uint) ([]models.Payment, error) {
+	if _, err := GetOrder(userID, orderID); err != nil {
+		return nil, err
+	}
+
+	payments, err := repository.GetPaymentsByOrderID(orderID)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range payments {
+		if payments[i].Status != models.PaymentStatusPending {
+			continue
+		}
+		if err := SyncPayment(ctx, &payments[i]); err != nil {
+			log.Warn().Err(err).Uint("payment_id", payments[i].ID).Msg("Failed to refresh payment status")
+		}
+	}
+
+	return payments, nil
+}
+
+// SyncPayment fetches the payment's current status from its provider and
+// applies it to the payment and its order.
+func SyncPayment(ctx context.Context, payment *models.Payment) error {
+	provider, err := GetPaymentProviderByName(payment.Provider)
+	if err != nil {
+		return err
+	}
+
+	intent, err := provider.FetchStatus(ctx, payment.ProviderReference)
+	if err != nil {
+		return err
+	}
+
+	if intent.Status == payment.Status {
+		return nil
+	}
+
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		return applyPaymentIntent(tx, payment, intent)
+	})
+}
+
+// settlePendingPayment records the payer's settlement of a charge that has
+// no authorization step (PIX, boleto). Settling an already paid or reviewed
+// charge again is a no-op so that retried notifications are harmless. A
+// settlement that does not match the charge, or that arrives after the
+// charge expired or was canceled, goes to payment review instead and the
+// review is returned.
+func settlePendingPayment(tx *gorm.DB, payment *models.Payment, amount float64, currency string, paidAt time.Time) (*models.PaymentReview, error) {
+	if payment.Status == models.PaymentStatusSucceeded || payment.Status == models.PaymentStatusReview {
+		return nil, nil
+	}
+
+	reason := models.PaymentReviewLateSettlement
+	if payment.Status == models.PaymentStatusPending {
+		reason = ClassifyPaymentDiscrepancy(payment.Amount, amount, payment.Currency, currency)
+	}
+	if reason != "" {
+		payment.Status = models.PaymentStatusReview
+		if err := tx.Save(payment).Error; err != nil {
+			return nil, err
+		}
+		return flagPaymentReview(tx, payment.OrderID, payment, payment.Provider, reason, amount, currency)
+	}
+
+	if !paidAt.IsZero() {
+		payment.CapturedAt = &paidAt
+	}
+
+	return nil, applyPaymentIntent(tx, payment, &PaymentIntent{
+		Reference: payment.ProviderReference,
+		Status:    models.PaymentStatusSucceeded,
+		Amount:    amount,
+	})
+}
+
+// applyPaymentIntent saves the provider status on the payment and marks the
+// order paid once its succeeded payments cover the total.
+func applyPaymentIntent(tx *gorm.DB, payment *models.Payment, intent *PaymentIntent) error {
+	payment.Status = intent.Status
+	payment.FailureReason = intent.FailureReason
+	if intent.Status == models.PaymentStatusSucceeded && payment.CapturedAt == nil {
+		now := time.Now()
+		payment.CapturedAt = &now
+	}
+
+	if err := tx.Save(payment).Error; err != nil {
+		return err
+	}
+
+	if payment.Status != models.PaymentStatusSucceeded {
+		return nil
+	}
+
+	// Split payments settle the order only once together they cover it.
+	var order models.Order
+	if err := tx.Select("id", "user_id", "total").First(&order, payment.OrderID).Error; err != nil {
+		return err
+	}
+	paid, err := paidAmount(tx, order.ID)
+	if err != nil {
+		return err
+	}
+	if paid < order.Total-0.005 {
+		return nil
+	}
+
+	result := tx.Model(&models.Order{}).
+		Where("id = ? AND status = ?", payment.OrderID, models.OrderStatusPending).
+		Update("status", models.OrderStatusPaid)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil
+	}
+
+	activityLog := models.ActivityLog{
+		UserID:    order.UserID,
+		Action:    fmt.Sprintf("Order %d paid via %s (payment %d)", order.ID, payment.Provider, payment.ID),
+		Timestamp: time.Now(),
+	}
+	return tx.Create(&activityLog).Error
+}
+
+// createBalancePayment records a gift card or store credit tender, which is
+// settled as soon as the balance is taken.
+func createBalancePayment(tx *gorm.DB, order *models.Order, method, reference string, amount float64) (models.Payment, error) {
+	payment := models.Payment{
+		OrderID:           order.ID,
+		Provider:          models.PaymentProviderInternal,
+		ProviderReference: reference,
+		Method:            method,
+		Amount:            amount,
+		Currency:          "BRL",
+	}
+	err := applyPaymentIntent(tx, &payment, &PaymentIntent{
+		Reference: reference,
+		Status:    models.PaymentStatusSucceeded,
+		Amount:    amount,
+	})
+	return payment, err
+}
+
+// paidAmount sums the order's succeeded payments.
+func paidAmount(tx *gorm.DB, orderID uint) (float64, error) {
+	var paid float64
+	err := tx.Model(&models.Payment{}).
+		Where("order_id = ? AND status = ?", orderID, models.PaymentStatusSucceeded).
+		Select("coalesce(sum(amount), 0)").Row().Scan(&paid)
+	return roundCents(paid), err
+}
+
+// releaseBalancePaymentsTx gives the gift card and store credit an order
+// spent back when the order is canceled.
+func releaseBalancePaymentsTx(tx *gorm.DB, order *models.Order) error {
+	reason := fmt.Sprintf("order %d canceled", order.ID)
+
+	var spent []struct {
+		GiftCardID uint
+		Amount     float64
+	}
+	if err := tx.Model(&models.GiftCardTransaction{}).
+		Select("gift_card_id, sum(amount) as amount").
+		Where("order_id = ?", order.ID).Group("gift_card_id").
+		Scan(&spent).Error; err != nil {
+		return err
+	}
+	for _, s := range spent {
+		if roundCents(s.Amount) >= 0 {
+			continue
+		}
+		var card models.GiftCard
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&card, s.GiftCardID).Error; err != nil {
+			return err
+		}
+		if _, err := moveGiftCardBalanceTx(tx, &card, roundCents(-s.Amount), &order.ID, reason); err != nil {
+			return err
+		}
+	}
+
+	var credit float64
+	if err := tx.Model(&models.StoreCreditEntry{}).
+		Where("user_id = ? AND order_id = ?", order.UserID, order.ID).
+		Select("coalesce(sum(amount), 0)").Row().Scan(&credit); err != nil {
+		return err
+	}
+	if roundCents(credit) < 0 {
+		if _, err := moveStoreCreditTx(tx, models.StoreCreditEntry{
+			UserID:  order.UserID,
+			Amount:  roundCents(-credit),
+			Reason:  reason,
+			OrderID: &order.ID,
+		}); err != nil {
+			return err
+		}
+	}
+
+	return tx.Model(&models.Payment{}).
+		Where("order_id = ? AND provider = ? AND status = ?", order.ID, models.PaymentProviderInternal, models.PaymentStatusSucceeded).
+		Update("status", models.PaymentStatusRefunded).Error
+}
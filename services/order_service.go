@@ -0,0 +1,300 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"smart-choice/database"
+	"smart-choice/models"
+	"smart-choice/repository"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrEmptyOrder              = errors.New("order must contain at least one item")
+	ErrOrderNotFound           = errors.New("order not found")
+	ErrProductNotFound         = errors.New("product not found")
+	ErrInsufficientStock       = errors.New("insufficient stock")
+	ErrShippingAddressRequired = errors.New("a shipping address is required to place an order")
+	ErrInvalidItemQuantity     = errors.New("item quantity must be greater than zero")
+	ErrDuplicateOrderItem      = errors.New("each product may appear only once per order")
+)
+
+type OrderItemRequest struct {
+	ProductID uint `json:"product_id" binding:"required"`
+	Quantity  uint `json:"quantity" binding:"required"`
+}
+
+type PlaceOrderRequest struct {
+	Items             []OrderItemRequest `json:"items" binding:"required,dive"`
+	ShippingAddressID *uint              `json:"shipping_address_id"`
+	BillingAddressID  *uint              `json:"billing_address_id"`
+	Installments      int                `json:"installments"`
+	CouponCode        string             `json:"coupon_code"`
+	LoyaltyPoints     int                `json:"loyalty_points"`
+}
+
+// PlaceOrder creates an order for the user inside a single transaction:
+// product rows are locked, stock is decremented, item prices are frozen and
+// the shipping/billing addresses are copied onto the order as snapshots.
+// Automatic promotions are applied per line and a coupon, if given, is
+// redeemed in the same transaction on top of them, followed by any loyalty
+// points. The chosen installment plan is priced against the final total and
+// stored too.
+func PlaceOrder(user *models.User, req PlaceOrderRequest) (*models.Order, error) {
+	if len(req.Items) == 0 {
+		return nil, ErrEmptyOrder
+	}
+
+	if !user.HasTaxDocument() {
+		return nil, ErrTaxDocumentRequired
+	}
+
+	order := models.Order{
+		UserID: user.ID,
+		Status: models.OrderStatusPending,
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		shipping, billing, err := resolveOrderAddresses(tx, user.ID, req.ShippingAddressID, req.BillingAddressID)
+		if err != nil {
+			return err
+		}
+		order.ShippingAddress = shipping.Snapshot()
+		order.BillingAddress = billing.Snapshot()
+
+		seen := make(map[uint]bool)
+		var promotionLines []PromotionLine
+		for _, item := range req.Items {
+			if item.Quantity == 0 {
+				return ErrInvalidItemQuantity
+			}
+			if seen[item.ProductID] {
+				return ErrDuplicateOrderItem
+			}
+			seen[item.ProductID] = true
+
+			var product models.Product
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&product, item.ProductID).Error; err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					return fmt.Errorf("%w: %d", ErrProductNotFound, item.ProductID)
+				}
+				return err
+			}
+
+			if product.Stock < item.Quantity {
+				return fmt.Errorf("%w for product %s", ErrInsufficientStock, product.Name)
+			}
+
+			product.Stock -= item.Quantity
+			if err := tx.Save(&product).Error; err != nil {
+				return err
+			}
+
+			order.OrderItems = append(order.OrderItems, models.OrderItem{
+				ProductID: product.ID,
+				Quantity:  item.Quantity,
+				Price:     product.Price,
+			})
+			order.Subtotal += product.Price * float64(item.Quantity)
+			promotionLines = append(promotionLines, PromotionLine{
+				ProductID: product.ID,
+				Category:  product.Category,
+				Quantity:  item.Quantity,
+				UnitPrice: product.Price,
+			})
+		}
+		order.Subtotal = roundCents(order.Subtotal)
+
+		// Promotions apply first; a coupon then works on what is left of
+		// each line.
+		promotions, err := applyPromotions(tx, promotionLines)
+		if err != nil {
+			return err
+		}
+		couponLines := make([]CouponLine, 0, len(order.OrderItems))
+		for i := range order.OrderItems {
+			item := &order.OrderItems[i]
+			item.Discount = promotions.DiscountFor(item.ProductID)
+			couponLines = append(couponLines, CouponLine{
+				ProductID: item.ProductID,
+				Category:  promotionLines[i].Category,
+				Amount:    item.Price*float64(item.Quantity) - item.Discount,
+			})
+		}
+		for _, applied := range promotions.Applied {
+			order.Promotions = append(order.Promotions, models.OrderPromotion{
+				PromotionID: applied.PromotionID,
+				Name:        applied.Name,
+				Discount:    applied.Discount,
+			})
+		}
+		order.PromotionDiscount = promotions.Discount
+		order.Total = roundCents(order.Subtotal - order.PromotionDiscount)
+
+		var coupon *models.Coupon
+		if req.CouponCode != "" {
+			quote, err := redeemCouponTx(tx, user.ID, req.CouponCode, couponLines)
+			if err != nil {
+				return err
+			}
+			coupon = quote.Coupon
+			order.CouponID = &coupon.ID
+			order.Discount = quote.Discount
+			order.FreeShipping = quote.FreeShipping
+			order.Total = roundCents(order.Total - quote.Discount)
+		}
+
+		if req.LoyaltyPoints != 0 {
+			points, discount, err := quoteLoyaltyRedemptionTx(tx, user.ID, req.LoyaltyPoints, order.Total)
+			if err != nil {
+				return err
+			}
+			order.LoyaltyPointsRedeemed = points
+			order.LoyaltyDiscount = discount
+			order.Total = roundCents(order.Total - discount)
+		}
+
+		installments := req.Installments
+		if installments == 0 {
+			installments = 1
+		}
+		option, err := FindInstallmentOption(order.Total, installments, DefaultInstallmentRules())
+		if err != nil {
+			return err
+		}
+		order.InstallmentPlan = installmentPlan(option)
+
+		if err := tx.Create(&order).Error; err != nil {
+			return err
+		}
+
+		if coupon != nil {
+			redemption := models.CouponRedemption{
+				CouponID: coupon.ID,
+				OrderID:  order.ID,
+				UserID:   user.ID,
+				Discount: order.Discount,
+			}
+			if err := tx.Create(&redemption).Error; err != nil {
+				return err
+			}
+		}
+
+		if order.LoyaltyPointsRedeemed > 0 {
+			if err := redeemLoyaltyPointsTx(tx, user.ID, order.ID, order.LoyaltyPointsRedeemed); err != nil {
+				return err
+			}
+		}
+
+		activityLog := models.ActivityLog{
+			UserID:    user.ID,
+			Action:    fmt.Sprintf("Order %d placed", order.ID),
+			Timestamp: time.Now(),
+		}
+		return tx.Create(&activityLog).Error
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &order, nil
+}
+
+func ListOrders(userID uint) ([]models.Order, error) {
+	return repository.GetOrdersByUserID(userID)
+}
+
+func GetOrder(userID, orderID uint) (*models.Order, error) {
+	order, err := repository.GetUserOrder(userID, orderID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrOrderNotFound
+		}
+		return nil, err
+	}
+	return &order, nil
+}
+
+// cancelOrderTx cancels an unpaid order, returns its items to stock and
+// releases the coupon, gift card, store credit and loyalty points it used.
+func cancelOrderTx(tx *gorm.DB, order *models.Order, reason string) error {
+	if order.Status != models.OrderStatusPending && order.Status != models.OrderStatusPaymentReview {
+		return fmt.Errorf("cannot cancel order %d in status %s", order.ID, order.Status)
+	}
+
+	var items []models.OrderItem
+	if err := tx.Where("order_id = ?", order.ID).Find(&items).Error; err != nil {
+		return err
+	}
+
+	for _, item := range items {
+		if err := tx.Model(&models.Product{}).Where("id = ?", item.ProductID).
+			UpdateColumn("stock", gorm.Expr("stock + ?", item.Quantity)).Error; err != nil {
+			return err
+		}
+	}
+
+	if err := releaseCouponTx(tx, order.ID); err != nil {
+		return err
+	}
+	if err := releaseBalancePaymentsTx(tx, order); err != nil {
+		return err
+	}
+	if err := restoreLoyaltyPointsTx(tx, order); err != nil {
+		return err
+	}
+
+	order.Status = models.OrderStatusCanceled
+	if err := tx.Model(order).Update("status", order.Status).Error; err != nil {
+		return err
+	}
+
+	activityLog := models.ActivityLog{
+		UserID:    order.UserID,
+		Action:    fmt.Sprintf("Order %d canceled: %s", order.ID, reason),
+		Timestamp: time.Now(),
+	}
+	return tx.Create(&activityLog).Error
+}
+
+// resolveOrderAddresses loads the addresses chosen for the order, falling back
+// to the user's defaults. Billing falls back to the shipping address.
+func resolveOrderAddresses(tx *gorm.DB, userID uint, shippingID, billingID *uint) (*models.Address, *models.Address, error) {
+	var shipping models.Address
+	var err error
+	if shippingID != nil {
+		err = tx.Where("user_id = ?", userID).First(&shipping, *shippingID).Error
+	} else {
+		shipping, err = repository.GetDefaultShippingAddress(tx, userID)
+	}
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			if shippingID != nil {
+				return nil, nil, ErrAddressNotFound
+			}
+			return nil, nil, ErrShippingAddressRequired
+		}
+		return nil, nil, err
+	}
+
+	billing := shipping
+	if billingID != nil {
+		var chosen models.Address
+		if err := tx.Where("user_id = ?", userID).First(&chosen, *billingID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, nil, ErrAddressNotFound
+			}
+			return nil, nil, err
+		}
+		billing = chosen
+	} else if defaultBilling, err := repository.GetDefaultBillingAddress(tx, userID); err == nil {
+		billing = defaultBilling
+	}
+
+	return &shipping, &billing, nil
+}
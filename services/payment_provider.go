@@ -0,0 +1,129 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"smart-choice/models"
+)
+
+var (
+	ErrUnknownPaymentProvider   = errors.New("unknown payment provider")
+	ErrPaymentNotFound          = errors.New("payment not found")
+	ErrRefundNotSupported       = errors.New("provider does not support automatic refunds")
+	ErrUnsupportedPaymentMethod = errors.New("payment method must be card, pix or boleto")
+)
+
+type PaymentIntentRequest struct {
+	OrderID     uint
+	Amount      float64
+	Currency    string
+	Method      string
+	Description string
+	// Installments is the card installment count; 0 or 1 is a single charge.
+	Installments uint
+}
+
+// PaymentIntent is the provider's view of a charge. Method-specific fields
+// are only filled by providers that support the method.
+type PaymentIntent struct {
+	Reference     string
+	Status        string
+	Amount        float64
+	FailureReason string
+	PixBRCode     string
+	ExpiresAt     *time.Time
+
+	BoletoBarcode       string
+	BoletoDigitableLine string
+	DueDate             *time.Time
+}
+
+type PaymentRefundResult struct {
+	Reference string
+	Status    string
+	Amount    float64
+}
+
+// PaymentProvider is implemented by every payment gateway integration.
+type PaymentProvider interface {
+	Name() string
+	CreateIntent(ctx context.Context, req PaymentIntentRequest) (*PaymentIntent, error)
+	Capture(ctx context.Context, reference string, amount float64) (*PaymentIntent, error)
+	Refund(ctx context.Context, reference string, amount float64) (*PaymentRefundResult, error)
+	FetchStatus(ctx context.Context, reference string) (*PaymentIntent, error)
+}
+
+// PaymentVoider is implemented by providers that can release an authorized
+// intent without capturing it. Providers without it are asked to refund.
+type PaymentVoider interface {
+	Void(ctx context.Context, reference string) (*PaymentIntent, error)
+}
+
+var (
+	paymentProvidersMu   sync.RWMutex
+	paymentProviders     = make(map[string]PaymentProvider)
+	paymentProvidersOnce sync.Once
+)
+
+// registerDefaultPaymentProviders is deferred until first use so that the
+// environment has already been loaded from .env.
+func registerDefaultPaymentProviders() {
+	paymentProvidersOnce.Do(func() {
+		mode := SimulatorMode(getEnv("PAYMENT_SIMULATOR_MODE", string(SimulatorModeSucceed)))
+		addPaymentProvider(NewSimulatorProvider(mode))
+		addPaymentProvider(NewPixProvider())
+		addPaymentProvider(NewBoletoProvider())
+	})
+}
+
+// RegisterPaymentProvider makes a provider selectable through PAYMENT_PROVIDER.
+// Registering under an existing name replaces the previous provider.
+func RegisterPaymentProvider(provider PaymentProvider) {
+	registerDefaultPaymentProviders()
+	addPaymentProvider(provider)
+}
+
+func addPaymentProvider(provider PaymentProvider) {
+	paymentProvidersMu.Lock()
+	defer paymentProvidersMu.Unlock()
+	paymentProviders[provider.Name()] = provider
+}
+
+func GetPaymentProviderByName(name string) (PaymentProvider, error) {
+	registerDefaultPaymentProviders()
+
+	paymentProvidersMu.RLock()
+	defer paymentProvidersMu.RUnlock()
+
+	provider, ok := paymentProviders[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownPaymentProvider, name)
+	}
+	return provider, nil
+}
+
+// GetPaymentProvider returns the provider configured by PAYMENT_PROVIDER,
+// defaulting to the local simulator.
+func GetPaymentProvider() (PaymentProvider, error) {
+	return GetPaymentProviderByName(getEnv("PAYMENT_PROVIDER", SimulatorProviderName))
+}
+
+// GetPaymentProviderForMethod routes methods with a dedicated integration
+// (PIX, boleto) to their provider and cards to PAYMENT_PROVIDER. Any other
+// method is rejected.
+func GetPaymentProviderForMethod(method string) (PaymentProvider, error) {
+	switch method {
+	case models.PaymentMethodCard:
+		return GetPaymentProvider()
+	case models.PaymentMethodPix:
+		return GetPaymentProviderByName(getEnv("PIX_PROVIDER", PixProviderName))
+	case models.PaymentMethodBoleto:
+		return GetPaymentProviderByName(getEnv("BOLETO_PROVIDER", BoletoProviderName))
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnsupportedPaymentMethod, method)
+	}
+}
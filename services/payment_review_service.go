@@ -0,0 +1,190 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"smart-choice/database"
+	"smart-choice/metrics"
+	"smart-choice/models"
+	"smart-choice/repository"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrPaymentReviewNotFound      = errors.New("payment review not found")
+	ErrPaymentReviewResolved      = errors.New("payment review is already resolved")
+	ErrInvalidPaymentReviewAction = errors.New("action must be accept or cancel")
+	ErrOrderNotInPaymentReview    = errors.New("order is not in payment review")
+)
+
+// ClassifyPaymentDiscrepancy compares a settlement with the charge and
+// returns the review reason, or "" when they match. An empty received
+// currency is taken as BRL, the only currency we charge in.
+func ClassifyPaymentDiscrepancy(expected, received float64, expectedCurrency, receivedCurrency string) string {
+	if expectedCurrency == "" {
+		expectedCurrency = "BRL"
+	}
+	if receivedCurrency == "" {
+		receivedCurrency = "BRL"
+	}
+
+	switch {
+	case receivedCurrency != expectedCurrency:
+		return models.PaymentReviewCurrencyMismatch
+	case math.Abs(expected-received) < 0.005:
+		return ""
+	case received < expected:
+		return models.PaymentReviewUnderpayment
+	default:
+		return models.PaymentReviewOverpayment
+	}
+}
+
+// flagPaymentReview records why a settlement needs an admin and moves the
+// order to payment_review. Orders that are no longer pending keep their
+// status: the money arrived after they were paid another way or canceled.
+// The review is returned so the caller can count it once the transaction
+// commits.
+func flagPaymentReview(tx *gorm.DB, orderID uint, payment *models.Payment, source, reason string, received float64, currency string) (*models.PaymentReview, error) {
+	var order models.Order
+	if err := tx.Select("id", "user_id", "total", "status").First(&order, orderID).Error; err != nil {
+		return nil, err
+	}
+
+	review := models.PaymentReview{
+		OrderID:          order.ID,
+		Source:           source,
+		Reason:           reason,
+		ExpectedAmount:   order.Total,
+		ReceivedAmount:   received,
+		ExpectedCurrency: "BRL",
+		ReceivedCurrency: currency,
+	}
+	if payment != nil {
+		review.PaymentID = &payment.ID
+		review.ExpectedAmount = payment.Amount
+		review.ExpectedCurrency = payment.Currency
+	}
+	if review.ReceivedCurrency == "" {
+		review.ReceivedCurrency = "BRL"
+	}
+
+	if err := tx.Create(&review).Error; err != nil {
+		return nil, err
+	}
+
+	if order.Status == models.OrderStatusPending {
+		if err := tx.Model(&order).Update("status", models.OrderStatusPaymentReview).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	activityLog := models.ActivityLog{
+		UserID: order.UserID,
+		Action: fmt.Sprintf("Order %d sent to payment review (%s): expected %.2f %s, received %.2f %s",
+			order.ID, reason, review.ExpectedAmount, review.ExpectedCurrency, review.ReceivedAmount, review.ReceivedCurrency),
+		Timestamp: time.Now(),
+	}
+	if err := tx.Create(&activityLog).Error; err != nil {
+		return nil, err
+	}
+
+	return &review, nil
+}
+
+// countPaymentReview records a committed review in the metrics.
+func countPaymentReview(review *models.PaymentReview) {
+	if review != nil {
+		metrics.PaymentReviewsTotal.WithLabelValues(review.Source, review.Reason).Inc()
+	}
+}
+
+func ListPaymentReviews(includeResolved bool) ([]models.PaymentReview, error) {
+	return repository.GetPaymentReviews(includeResolved)
+}
+
+// ResolvePaymentReview closes a review. Accepting marks the order (and the
+// reviewed payment) as paid; cancelling cancels the order and restocks it.
+// A review of an order that left payment_review, such as a late settlement
+// for a canceled order, can only be cancelled, which leaves the order as it
+// is. Any money received for a cancelled review is returned outside the
+// system.
+func ResolvePaymentReview(admin *models.User, reviewID uint, action, note string) (*models.PaymentReview, error) {
+	if action != models.PaymentReviewActionAccept && action != models.PaymentReviewActionCancel {
+		return nil, ErrInvalidPaymentReviewAction
+	}
+
+	var review models.PaymentReview
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&review, reviewID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrPaymentReviewNotFound
+			}
+			return err
+		}
+		if review.ResolvedAt != nil {
+			return ErrPaymentReviewResolved
+		}
+
+		var order models.Order
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&order, review.OrderID).Error; err != nil {
+			return err
+		}
+		inReview := order.Status == models.OrderStatusPaymentReview
+		if !inReview && action == models.PaymentReviewActionAccept {
+			return ErrOrderNotInPaymentReview
+		}
+
+		if action == models.PaymentReviewActionAccept {
+			if review.PaymentID != nil {
+				now := time.Now()
+				if err := tx.Model(&models.Payment{}).Where("id = ?", *review.PaymentID).
+					Updates(map[string]interface{}{"status": models.PaymentStatusSucceeded, "captured_at": now}).Error; err != nil {
+					return err
+				}
+			}
+			if err := tx.Model(&order).Update("status", models.OrderStatusPaid).Error; err != nil {
+				return err
+			}
+		} else {
+			if review.PaymentID != nil {
+				if err := tx.Model(&models.Payment{}).Where("id = ?", *review.PaymentID).
+					Update("status", models.PaymentStatusCanceled).Error; err != nil {
+					return err
+				}
+			}
+			if inReview {
+				if err := cancelOrderTx(tx, &order, "payment review rejected"); err != nil {
+					return err
+				}
+			}
+		}
+
+		now := time.Now()
+		review.Resolution = action
+		review.ResolutionNote = note
+		review.ResolvedByID = &admin.ID
+		review.ResolvedAt = &now
+		if err := tx.Save(&review).Error; err != nil {
+			return err
+		}
+
+		activityLog := models.ActivityLog{
+			UserID:    order.UserID,
+			Action:    fmt.Sprintf("Payment review %d for order %d resolved by admin %d: %s", review.ID, order.ID, admin.ID, action),
+			Timestamp: time.Now(),
+		}
+		return tx.Create(&activityLog).Error
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &review, nil
+}
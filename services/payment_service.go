@@ -1,38 +1,102 @@
 package services
 
 import (
-	"crypto/hmac"
-	"crypto/sha256"
-	"encoding/hex"
+	"encoding/json"
 	"errors"
-	"os"
+	"fmt"
+	"time"
 
-	"smart-choice/database"
-	"smart-choice/repository"
+	"smart-choice/models"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
-func ProcessPaymentWebhook(payload, signature string, orderID uint) error {
-	if !validatePayload(payload, signature) {
-		return errors.New("invalid signature")
+var ErrUnsupportedPaymentStatus = errors.New("payment notifications may only set paid or canceled")
+
+// PaymentStatusNotification is the body of the generic payment webhook.
+type PaymentStatusNotification struct {
+	OrderID  uint    `json:"order_id"`
+	Status   string  `json:"status"`
+	Amount   float64 `json:"amount"`
+	Currency string  `json:"currency"`
+}
+
+// HandlePaymentEvent applies the order status from a verified payment
+// notification. Only "paid" and "canceled" are accepted, and only for
+// pending orders. A "paid" notification whose amount or currency differs
+// from the charge sends the order to payment review instead; a cancellation
+// goes through cancelOrderTx so stock, coupon, balances and points are
+// released. Changes are made within tx; a review the notification opened
+// is returned.
+func HandlePaymentEvent(tx *gorm.DB, payload string) (*models.PaymentReview, error) {
+	var notification PaymentStatusNotification
+	if err := json.Unmarshal([]byte(payload), &notification); err != nil {
+		return nil, err
+	}
+	if notification.Status != models.OrderStatusPaid && notification.Status != models.OrderStatusCanceled {
+		return nil, fmt.Errorf("%w: %q", ErrUnsupportedPaymentStatus, notification.Status)
 	}
 
-	return database.DB.Transaction(func(tx *gorm.DB) error {
-		if err := repository.UpdateOrderStatus(orderID, "paid"); err != nil {
-			return err
+	var order models.Order
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&order, notification.OrderID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrOrderNotFound
 		}
+		return nil, err
+	}
 
-		// Adicionar lógica adicional da transação aqui, se necessário
+	if order.Status == notification.Status || order.Status == models.OrderStatusPaymentReview {
+		return nil, nil
+	}
+	if order.Status != models.OrderStatusPending {
+		return nil, fmt.Errorf("%w: %s to %s", ErrInvalidOrderTransition, order.Status, notification.Status)
+	}
 
-		return nil
-	})
-}
+	if notification.Status == models.OrderStatusCanceled {
+		return nil, cancelOrderTx(tx, &order, "provider webhook")
+	}
 
-func validatePayload(payload, signature string) bool {
-	secret := os.Getenv("WEBHOOK_SECRET")
-	mac := hmac.New(sha256.New, []byte(secret))
-	mac.Write([]byte(payload))
-	expectedMAC := hex.EncodeToString(mac.Sum(nil))
-	return hmac.Equal([]byte(signature), []byte(expectedMAC))
+	// The active payment knows the charged amount, which includes
+	// installment interest; without one the part of the order total
+	// not paid from balances is expected.
+	var fromBalances float64
+	if err := tx.Model(&models.Payment{}).
+		Where("order_id = ? AND provider = ? AND status = ?", order.ID, models.PaymentProviderInternal, models.PaymentStatusSucceeded).
+		Select("coalesce(sum(amount), 0)").Row().Scan(&fromBalances); err != nil {
+		return nil, err
+	}
+	expected, currency := roundCents(order.Total-fromBalances), "BRL"
+	var payment *models.Payment
+	var active models.Payment
+	err := tx.Where("order_id = ? AND provider <> ? AND status IN ?", order.ID, models.PaymentProviderInternal,
+		[]string{models.PaymentStatusPending, models.PaymentStatusAuthorized, models.PaymentStatusSucceeded}).
+		Order("id desc").First(&active).Error
+	if err == nil {
+		payment = &active
+		expected, currency = active.Amount, active.Currency
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	if reason := ClassifyPaymentDiscrepancy(expected, notification.Amount, currency, notification.Currency); reason != "" {
+		if payment != nil {
+			if err := tx.Model(payment).Update("status", models.PaymentStatusReview).Error; err != nil {
+				return nil, err
+			}
+		}
+		return flagPaymentReview(tx, order.ID, payment, "payment", reason, notification.Amount, notification.Currency)
+	}
+
+	order.Status = notification.Status
+	if err := tx.Save(&order).Error; err != nil {
+		return nil, err
+	}
+
+	activityLog := models.ActivityLog{
+		UserID:    order.UserID,
+		Action:    "Payment status updated to " + notification.Status,
+		Timestamp: time.Now(),
+	}
+	return nil, tx.Create(&activityLog).Error
 }
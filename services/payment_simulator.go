@@ -0,0 +1,189 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"smart-choice/models"
+
+	"github.com/google/uuid"
+)
+
+const SimulatorProviderName = "simulator"
+
+type SimulatorMode string
+
+const (
+	// SimulatorModeSucceed authorizes every intent immediately
+	SimulatorModeSucceed SimulatorMode = "succeed"
+	// SimulatorModeFail declines every intent
+	SimulatorModeFail SimulatorMode = "fail"
+	// SimulatorModeAsync leaves intents pending until ResolveAsync is called
+	SimulatorModeAsync SimulatorMode = "async"
+)
+
+var ErrInvalidRefundAmount = errors.New("refund amount exceeds captured amount")
+
+// SimulatorProvider is a fully local PaymentProvider for development and
+// tests. It keeps its charges in memory.
+type SimulatorProvider struct {
+	mu       sync.Mutex
+	mode     SimulatorMode
+	intents  map[string]*PaymentIntent
+	refunded map[string]float64
+}
+
+func NewSimulatorProvider(mode SimulatorMode) *SimulatorProvider {
+	switch mode {
+	case SimulatorModeSucceed, SimulatorModeFail, SimulatorModeAsync:
+	default:
+		mode = SimulatorModeSucceed
+	}
+
+	return &SimulatorProvider{
+		mode:     mode,
+		intents:  make(map[string]*PaymentIntent),
+		refunded: make(map[string]float64),
+	}
+}
+
+func (s *SimulatorProvider) Name() string {
+	return SimulatorProviderName
+}
+
+// SetMode changes the outcome of intents created from now on.
+func (s *SimulatorProvider) SetMode(mode SimulatorMode) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.mode = mode
+}
+
+func (s *SimulatorProvider) CreateIntent(ctx context.Context, req PaymentIntentRequest) (*PaymentIntent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	intent := &PaymentIntent{
+		Reference: "sim_" + uuid.New().String(),
+		Amount:    req.Amount,
+	}
+
+	switch s.mode {
+	case SimulatorModeFail:
+		intent.Status = models.PaymentStatusFailed
+		intent.FailureReason = "card_declined"
+	case SimulatorModeAsync:
+		intent.Status = models.PaymentStatusPending
+	default:
+		intent.Status = models.PaymentStatusAuthorized
+	}
+
+	s.intents[intent.Reference] = intent
+	result := *intent
+	return &result, nil
+}
+
+func (s *SimulatorProvider) Capture(ctx context.Context, reference string, amount float64) (*PaymentIntent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	intent, ok := s.intents[reference]
+	if !ok {
+		return nil, ErrPaymentNotFound
+	}
+
+	if intent.Status != models.PaymentStatusAuthorized {
+		return nil, fmt.Errorf("cannot capture payment in status %s", intent.Status)
+	}
+
+	intent.Status = models.PaymentStatusSucceeded
+	intent.Amount = amount
+	result := *intent
+	return &result, nil
+}
+
+// Void releases an authorized intent without capturing it.
+func (s *SimulatorProvider) Void(ctx context.Context, reference string) (*PaymentIntent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	intent, ok := s.intents[reference]
+	if !ok {
+		return nil, ErrPaymentNotFound
+	}
+
+	if intent.Status != models.PaymentStatusAuthorized {
+		return nil, fmt.Errorf("cannot void payment in status %s", intent.Status)
+	}
+
+	intent.Status = models.PaymentStatusCanceled
+	result := *intent
+	return &result, nil
+}
+
+func (s *SimulatorProvider) Refund(ctx context.Context, reference string, amount float64) (*PaymentRefundResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	intent, ok := s.intents[reference]
+	if !ok {
+		return nil, ErrPaymentNotFound
+	}
+
+	if intent.Status != models.PaymentStatusSucceeded {
+		return nil, fmt.Errorf("cannot refund payment in status %s", intent.Status)
+	}
+
+	if s.refunded[reference]+amount > intent.Amount+0.005 {
+		return nil, ErrInvalidRefundAmount
+	}
+
+	s.refunded[reference] += amount
+	if s.refunded[reference] >= intent.Amount-0.005 {
+		intent.Status = models.PaymentStatusRefunded
+	}
+
+	return &PaymentRefundResult{
+		Reference: "simref_" + uuid.New().String(),
+		Status:    models.PaymentStatusSucceeded,
+		Amount:    amount,
+	}, nil
+}
+
+func (s *SimulatorProvider) FetchStatus(ctx context.Context, reference string) (*PaymentIntent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	intent, ok := s.intents[reference]
+	if !ok {
+		return nil, ErrPaymentNotFound
+	}
+
+	result := *intent
+	return &result, nil
+}
+
+// ResolveAsync settles a pending intent, as the real provider would do later
+// on its own. Succeeded intents go straight to succeeded (auto-captured).
+func (s *SimulatorProvider) ResolveAsync(reference string, succeed bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	intent, ok := s.intents[reference]
+	if !ok {
+		return ErrPaymentNotFound
+	}
+
+	if intent.Status != models.PaymentStatusPending {
+		return fmt.Errorf("payment %s is not pending", reference)
+	}
+
+	if succeed {
+		intent.Status = models.PaymentStatusSucceeded
+	} else {
+		intent.Status = models.PaymentStatusFailed
+		intent.FailureReason = "async_payment_failed"
+	}
+	return nil
+}
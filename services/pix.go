@@ -0,0 +1,194 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"smart-choice/models"
+	"smart-choice/repository"
+
+	"github.com/boombuler/barcode"
+	"github.com/boombuler/barcode/qr"
+	"github.com/google/uuid"
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+const PixProviderName = "pix"
+
+// PixBRCode holds the data encoded in a PIX BR Code (EMV QRCPS-MPM payload).
+// A static code carries the PIX key and amount; a dynamic code carries the
+// PSP location URL instead and is single-use.
+type PixBRCode struct {
+	Key          string
+	LocationURL  string
+	Amount       float64
+	MerchantName string
+	MerchantCity string
+	TxID         string
+}
+
+func (b PixBRCode) IsDynamic() bool {
+	return b.LocationURL != ""
+}
+
+// Payload renders the "copia e cola" string, including the trailing CRC16.
+func (b PixBRCode) Payload() string {
+	pointOfInitiation := "11"
+	account := emvField("00", "br.gov.bcb.pix")
+	txID := b.TxID
+	if b.IsDynamic() {
+		pointOfInitiation = "12"
+		account += emvField("25", strings.TrimPrefix(b.LocationURL, "https://"))
+		txID = "***"
+	} else {
+		account += emvField("01", b.Key)
+	}
+	if txID == "" {
+		txID = "***"
+	}
+
+	var payload strings.Builder
+	payload.WriteString(emvField("00", "01"))
+	payload.WriteString(emvField("01", pointOfInitiation))
+	payload.WriteString(emvField("26", account))
+	payload.WriteString(emvField("52", "0000"))
+	payload.WriteString(emvField("53", "986"))
+	if b.Amount > 0 {
+		payload.WriteString(emvField("54", fmt.Sprintf("%.2f", b.Amount)))
+	}
+	payload.WriteString(emvField("58", "BR"))
+	payload.WriteString(emvField("59", emvText(b.MerchantName, 25)))
+	payload.WriteString(emvField("60", emvText(b.MerchantCity, 15)))
+	payload.WriteString(emvField("62", emvField("05", txID)))
+	payload.WriteString("6304")
+
+	return payload.String() + CRC16CCITT(payload.String())
+}
+
+// CRC16CCITT computes the CRC16-CCITT-FALSE checksum (poly 0x1021, init
+// 0xFFFF) required by field 63 of the BR Code, as 4 uppercase hex digits.
+func CRC16CCITT(data string) string {
+	crc := uint16(0xFFFF)
+	for i := 0; i < len(data); i++ {
+		crc ^= uint16(data[i]) << 8
+		for bit := 0; bit < 8; bit++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return fmt.Sprintf("%04X", crc)
+}
+
+func emvField(id, value string) string {
+	return fmt.Sprintf("%s%02d%s", id, len(value), value)
+}
+
+// emvText fits free text into a field of at most length bytes. Accents are
+// stripped ("São Paulo" becomes "Sao Paulo") and any other non-ASCII rune is
+// dropped, because the field length counts bytes and many banking apps reject
+// anything outside ASCII.
+func emvText(s string, length int) string {
+	folded, _, err := transform.String(transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn))), s)
+	if err != nil {
+		folded = s
+	}
+
+	var b strings.Builder
+	for _, r := range folded {
+		if b.Len() == length {
+			break
+		}
+		if r < utf8.RuneSelf && unicode.IsPrint(r) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// GeneratePixQRCode renders the BR Code payload as a base64 encoded PNG.
+func GeneratePixQRCode(payload string) (string, error) {
+	code, err := qr.Encode(payload, qr.M, qr.Auto)
+	if err != nil {
+		return "", err
+	}
+
+	code, err = barcode.Scale(code, 300, 300)
+	if err != nil {
+		return "", err
+	}
+
+	return encodePNGBase64(code)
+}
+
+// PixProvider issues PIX charges directly from the merchant's key. PIX has
+// no authorization step: charges stay pending until a confirmation arrives
+// through the webhook pipeline, so the stored payment is the source of truth.
+type PixProvider struct{}
+
+func NewPixProvider() *PixProvider {
+	return &PixProvider{}
+}
+
+func (p *PixProvider) Name() string {
+	return PixProviderName
+}
+
+func (p *PixProvider) CreateIntent(ctx context.Context, req PaymentIntentRequest) (*PaymentIntent, error) {
+	txID := strings.ToUpper(strings.ReplaceAll(uuid.New().String(), "-", ""))[:25]
+	expiresAt := time.Now().Add(GetEnvDuration("PIX_EXPIRATION", 30*time.Minute))
+
+	code := PixBRCode{
+		Key:          getEnv("PIX_KEY", ""),
+		Amount:       req.Amount,
+		MerchantName: getEnv("PIX_MERCHANT_NAME", "SMART CHOICE"),
+		MerchantCity: getEnv("PIX_MERCHANT_CITY", "SAO PAULO"),
+		TxID:         txID,
+	}
+	if base := getEnv("PIX_LOCATION_BASE_URL", ""); base != "" {
+		code.LocationURL = strings.TrimSuffix(base, "/") + "/" + txID
+	}
+
+	if !code.IsDynamic() && code.Key == "" {
+		return nil, fmt.Errorf("PIX_KEY is not configured")
+	}
+
+	return &PaymentIntent{
+		Reference: txID,
+		Status:    models.PaymentStatusPending,
+		Amount:    req.Amount,
+		PixBRCode: code.Payload(),
+		ExpiresAt: &expiresAt,
+	}, nil
+}
+
+func (p *PixProvider) Capture(ctx context.Context, reference string, amount float64) (*PaymentIntent, error) {
+	return nil, fmt.Errorf("PIX charges are settled by the payer and cannot be captured")
+}
+
+func (p *PixProvider) Refund(ctx context.Context, reference string, amount float64) (*PaymentRefundResult, error) {
+	return nil, ErrRefundNotSupported
+}
+
+func (p *PixProvider) FetchStatus(ctx context.Context, reference string) (*PaymentIntent, error) {
+	payment, err := repository.GetPaymentByReference(PixProviderName, reference)
+	if err != nil {
+		return nil, ErrPaymentNotFound
+	}
+
+	return &PaymentIntent{
+		Reference: payment.ProviderReference,
+		Status:    payment.Status,
+		Amount:    payment.Amount,
+		PixBRCode: payment.PixBRCode,
+		ExpiresAt: payment.ExpiresAt,
+	}, nil
+}
@@ -0,0 +1,68 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"smart-choice/database"
+	"smart-choice/models"
+
+	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// PixConfirmation is the body of a PIX payment confirmation sent by the PSP.
+type PixConfirmation struct {
+	TxID       string    `json:"txid"`
+	EndToEndID string    `json:"end_to_end_id"`
+	Amount     float64   `json:"amount"`
+	Currency   string    `json:"currency"`
+	PaidAt     time.Time `json:"paid_at"`
+}
+
+// HandlePixEvent settles the charge named in a verified PIX confirmation.
+func HandlePixEvent(tx *gorm.DB, payload string) (*models.PaymentReview, error) {
+	var confirmation PixConfirmation
+	if err := json.Unmarshal([]byte(payload), &confirmation); err != nil {
+		return nil, err
+	}
+
+	return ConfirmPixPayment(tx, confirmation)
+}
+
+// ConfirmPixPayment marks the PIX charge identified by the txid as paid and
+// the order as paid within tx. Repeated confirmations for a settled charge
+// are no-ops. A review the settlement opened is returned.
+func ConfirmPixPayment(tx *gorm.DB, confirmation PixConfirmation) (*models.PaymentReview, error) {
+	var payment models.Payment
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("method = ? AND pix_tx_id = ?", models.PaymentMethodPix, confirmation.TxID).
+		First(&payment).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrPaymentNotFound
+		}
+		return nil, err
+	}
+
+	payment.PixEndToEndID = confirmation.EndToEndID
+	return settlePendingPayment(tx, &payment, confirmation.Amount, confirmation.Currency, confirmation.PaidAt)
+}
+
+// ExpirePixCharges marks pending PIX charges past their expiry as expired so
+// the order can be paid again.
+func ExpirePixCharges(ctx context.Context) error {
+	result := database.DB.WithContext(ctx).Model(&models.Payment{}).
+		Where("method = ? AND status = ? AND expires_at < ?", models.PaymentMethodPix, models.PaymentStatusPending, time.Now()).
+		Update("status", models.PaymentStatusExpired)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected > 0 {
+		log.Info().Int64("count", result.RowsAffected).Msg("Expired unpaid PIX charges")
+	}
+	return nil
+}
@@ -0,0 +1,337 @@
+package services
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"smart-choice/database"
+	"smart-choice/models"
+	"smart-choice/repository"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrJobQueueUnavailable    = errors.New("job queue unavailable")
+	ErrPrivacyRequestNotFound = errors.New("privacy request not found")
+	ErrExportNotReady         = errors.New("data export is not ready yet")
+	ErrInvalidPassword        = errors.New("invalid password")
+)
+
+type dataExportPayload struct {
+	PrivacyRequestID uint `json:"privacy_request_id"`
+}
+
+// RequestDataExport records an LGPD export request and enqueues the job that
+// builds the archive.
+func RequestDataExport(ctx context.Context, user *models.User) (*models.PrivacyRequest, error) {
+	jobQueue := GetServiceManager().GetJobQueue()
+	if jobQueue == nil {
+		return nil, ErrJobQueueUnavailable
+	}
+
+	request := models.PrivacyRequest{
+		UserID: user.ID,
+		Type:   models.PrivacyRequestExport,
+		Status: models.PrivacyStatusPending,
+	}
+	if err := repository.CreatePrivacyRequest(&request); err != nil {
+		return nil, err
+	}
+
+	payload, err := json.Marshal(dataExportPayload{PrivacyRequestID: request.ID})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := jobQueue.Enqueue(ctx, Job{Type: JobTypeDataExport, Payload: payload}); err != nil {
+		request.Status = models.PrivacyStatusFailed
+		request.Error = "failed to enqueue export job"
+		repository.UpdatePrivacyRequest(&request)
+		return nil, err
+	}
+
+	if err := repository.CreateActivityLog(&models.ActivityLog{
+		UserID:    user.ID,
+		Action:    fmt.Sprintf("LGPD data export requested (request %d)", request.ID),
+		Timestamp: time.Now(),
+	}); err != nil {
+		log.Error().Err(err).Uint("user_id", user.ID).Msg("Failed to log data export request")
+	}
+
+	return &request, nil
+}
+
+func GetPrivacyRequest(userID, requestID uint) (*models.PrivacyRequest, error) {
+	request, err := repository.GetUserPrivacyRequest(userID, requestID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrPrivacyRequestNotFound
+		}
+		return nil, err
+	}
+	return &request, nil
+}
+
+// GetDataExportFile returns the archive path of a completed export request.
+func GetDataExportFile(userID, requestID uint) (string, error) {
+	request, err := GetPrivacyRequest(userID, requestID)
+	if err != nil {
+		return "", err
+	}
+
+	if request.Type != models.PrivacyRequestExport {
+		return "", ErrPrivacyRequestNotFound
+	}
+
+	if request.Status != models.PrivacyStatusCompleted || request.FilePath == "" {
+		return "", ErrExportNotReady
+	}
+
+	return request.FilePath, nil
+}
+
+// HandleDataExportJob builds the export archive for a JobTypeDataExport job.
+func HandleDataExportJob(ctx context.Context, job *Job) error {
+	var payload dataExportPayload
+	if err := json.Unmarshal(job.Payload, &payload); err != nil {
+		return err
+	}
+
+	request, err := repository.GetPrivacyRequestByID(payload.PrivacyRequestID)
+	if err != nil {
+		return err
+	}
+
+	request.Status = models.PrivacyStatusProcessing
+	if err := repository.UpdatePrivacyRequest(&request); err != nil {
+		return err
+	}
+
+	path, err := buildDataExportArchive(request.UserID, request.ID)
+	if err != nil {
+		request.Status = models.PrivacyStatusFailed
+		request.Error = err.Error()
+		repository.UpdatePrivacyRequest(&request)
+		return err
+	}
+
+	now := time.Now()
+	request.Status = models.PrivacyStatusCompleted
+	request.FilePath = path
+	request.Error = ""
+	request.CompletedAt = &now
+	return repository.UpdatePrivacyRequest(&request)
+}
+
+// buildDataExportArchive writes a zip with one JSON file per data category.
+func buildDataExportArchive(userID, requestID uint) (string, error) {
+	user, err := repository.GetUserByID(userID)
+	if err != nil {
+		return "", err
+	}
+
+	addresses, err := repository.GetAddressesByUserID(userID)
+	if err != nil {
+		return "", err
+	}
+
+	orders, err := repository.GetOrdersByUserID(userID)
+	if err != nil {
+		return "", err
+	}
+
+	activityLogs, err := repository.GetActivityLogsByUserID(userID)
+	if err != nil {
+		return "", err
+	}
+
+	// Payments carry the PIX and boleto data of the payer.
+	payments, err := repository.GetPaymentsByUserID(userID)
+	if err != nil {
+		return "", err
+	}
+
+	refunds, err := repository.GetRefundsByUserID(userID)
+	if err != nil {
+		return "", err
+	}
+
+	storeCredit, err := repository.GetStoreCreditEntries(userID)
+	if err != nil {
+		return "", err
+	}
+
+	giftCardTransactions, err := repository.GetGiftCardTransactionsByUserID(userID)
+	if err != nil {
+		return "", err
+	}
+
+	loyaltyEntries, err := repository.GetLoyaltyEntries(userID)
+	if err != nil {
+		return "", err
+	}
+
+	couponRedemptions, err := repository.GetCouponRedemptionsByUserID(userID)
+	if err != nil {
+		return "", err
+	}
+
+	// The profile is exported unmasked: the data subject is entitled to it.
+	profile := map[string]interface{}{
+		"id":         user.ID,
+		"name":       user.Name,
+		"email":      user.Email,
+		"cpf":        user.CPF,
+		"cnpj":       user.CNPJ,
+		"two_fa":     user.TwoFA,
+		"created_at": user.CreatedAt,
+		"updated_at": user.UpdatedAt,
+	}
+
+	dir := getEnv("DATA_EXPORT_DIR", "exports")
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", err
+	}
+
+	path := filepath.Join(dir, fmt.Sprintf("user-%d-export-%d.zip", userID, requestID))
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	archive := zip.NewWriter(file)
+	entries := []struct {
+		name string
+		data interface{}
+	}{
+		{"profile.json", profile},
+		{"addresses.json", addresses},
+		{"orders.json", orders},
+		{"activity_logs.json", activityLogs},
+		{"payments.json", payments},
+		{"refunds.json", refunds},
+		{"store_credit.json", storeCredit},
+		{"gift_card_transactions.json", giftCardTransactions},
+		{"loyalty_entries.json", loyaltyEntries},
+		{"coupon_redemptions.json", couponRedemptions},
+	}
+
+	for _, entry := range entries {
+		w, err := archive.Create(entry.name)
+		if err != nil {
+			return "", err
+		}
+
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(entry.data); err != nil {
+			return "", err
+		}
+	}
+
+	if err := archive.Close(); err != nil {
+		return "", err
+	}
+
+	return path, nil
+}
+
+// EraseAccount anonymises the user's personal data. Addresses, loyalty points
+// and export archives are removed. Orders (with their address snapshots),
+// payments, refunds, store credit, gift card transactions and coupon
+// redemptions are kept under fiscal and accounting retention (LGPD art. 16,
+// I) but lose the link to any identifying profile data.
+func EraseAccount(user *models.User, password string) error {
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)); err != nil {
+		return ErrInvalidPassword
+	}
+
+	var exportFiles []string
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		request := models.PrivacyRequest{
+			UserID:      user.ID,
+			Type:        models.PrivacyRequestErasure,
+			Status:      models.PrivacyStatusCompleted,
+			CompletedAt: &now,
+		}
+		if err := tx.Create(&request).Error; err != nil {
+			return err
+		}
+
+		var exports []models.PrivacyRequest
+		if err := tx.Where("user_id = ? AND type = ? AND file_path <> ''", user.ID, models.PrivacyRequestExport).
+			Find(&exports).Error; err != nil {
+			return err
+		}
+		for _, export := range exports {
+			exportFiles = append(exportFiles, export.FilePath)
+		}
+		if err := tx.Model(&models.PrivacyRequest{}).Where("user_id = ? AND type = ?", user.ID, models.PrivacyRequestExport).
+			Update("file_path", "").Error; err != nil {
+			return err
+		}
+
+		if err := tx.Unscoped().Where("user_id = ?", user.ID).Delete(&models.Address{}).Error; err != nil {
+			return err
+		}
+
+		// Points have no fiscal value and can no longer be redeemed.
+		if err := tx.Unscoped().Where("user_id = ?", user.ID).Delete(&models.LoyaltyEntry{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("user_id = ?", user.ID).Delete(&models.LoyaltyAccount{}).Error; err != nil {
+			return err
+		}
+
+		unusable, err := bcrypt.GenerateFromPassword([]byte(uuid.New().String()), bcrypt.DefaultCost)
+		if err != nil {
+			return err
+		}
+
+		user.Name = "Usuário removido"
+		user.Email = fmt.Sprintf("anonimizado-%d@removido.invalid", user.ID)
+		user.Password = string(unusable)
+		user.TwoFA = false
+		user.TwoFASecret = ""
+		user.CPF = nil
+		user.CNPJ = nil
+		if err := tx.Save(user).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Delete(user).Error; err != nil {
+			return err
+		}
+
+		activityLog := models.ActivityLog{
+			UserID:    user.ID,
+			Action:    fmt.Sprintf("LGPD account erasure completed (request %d)", request.ID),
+			Timestamp: now,
+		}
+		return tx.Create(&activityLog).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, path := range exportFiles {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			log.Error().Err(err).Str("path", path).Msg("Failed to remove data export archive")
+		}
+	}
+
+	return nil
+}
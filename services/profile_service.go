@@ -0,0 +1,84 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"smart-choice/models"
+	"smart-choice/repository"
+	"smart-choice/utils"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/gorm"
+)
+
+// pgUniqueViolation is the Postgres error code for a unique index conflict.
+const pgUniqueViolation = "23505"
+
+var (
+	ErrInvalidCPF          = errors.New("invalid CPF")
+	ErrInvalidCNPJ         = errors.New("invalid CNPJ")
+	ErrTaxDocumentConflict = errors.New("provide either a CPF or a CNPJ, not both")
+	ErrTaxDocumentMissing  = errors.New("a CPF or CNPJ is required")
+	ErrTaxDocumentInUse    = errors.New("tax document already registered to another account")
+	ErrTaxDocumentRequired = errors.New("a CPF or CNPJ must be registered before placing an order")
+)
+
+// SetTaxDocument validates and stores the user's CPF or CNPJ. Setting one
+// clears the other, since invoices are issued against a single document.
+// The lookup only gives a friendly early answer; the unique index decides
+// when two accounts register the same document at once.
+func SetTaxDocument(user *models.User, cpf, cnpj string) error {
+	cpf = utils.OnlyDigits(cpf)
+	cnpj = utils.OnlyDigits(cnpj)
+
+	switch {
+	case cpf != "" && cnpj != "":
+		return ErrTaxDocumentConflict
+	case cpf == "" && cnpj == "":
+		return ErrTaxDocumentMissing
+	case cpf != "" && !utils.ValidateCPF(cpf):
+		return ErrInvalidCPF
+	case cnpj != "" && !utils.ValidateCNPJ(cnpj):
+		return ErrInvalidCNPJ
+	}
+
+	column, document := "cpf", cpf
+	if cnpj != "" {
+		column, document = "cnpj", cnpj
+	}
+
+	existing, err := repository.GetUserByTaxDocument(column, document)
+	if err == nil && existing.ID != user.ID {
+		return ErrTaxDocumentInUse
+	}
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+
+	user.CPF, user.CNPJ = nil, nil
+	if column == "cpf" {
+		user.CPF = &document
+	} else {
+		user.CNPJ = &document
+	}
+
+	if err := repository.UpdateUser(user); err != nil {
+		if isUniqueViolation(err) {
+			return ErrTaxDocumentInUse
+		}
+		return err
+	}
+
+	return repository.CreateActivityLog(&models.ActivityLog{
+		UserID:    user.ID,
+		Action:    fmt.Sprintf("Tax document (%s) updated", column),
+		Timestamp: time.Now(),
+	})
+}
+
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == pgUniqueViolation
+}
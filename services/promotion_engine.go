@@ -0,0 +1,216 @@
+package services
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"smart-choice/models"
+)
+
+// PromotionLine is a cart line as the promotions engine sees it.
+type PromotionLine struct {
+	ProductID uint
+	Category  string
+	Quantity  uint
+	UnitPrice float64
+}
+
+func (l PromotionLine) subtotal() float64 {
+	return l.UnitPrice * float64(l.Quantity)
+}
+
+// LineDiscount is the share of a promotion that fell on one cart line.
+type LineDiscount struct {
+	ProductID   uint    `json:"product_id"`
+	PromotionID uint    `json:"promotion_id"`
+	Promotion   string  `json:"promotion"`
+	Amount      float64 `json:"amount"`
+}
+
+type AppliedPromotion struct {
+	PromotionID uint    `json:"promotion_id"`
+	Name        string  `json:"name"`
+	Discount    float64 `json:"discount"`
+}
+
+// PromotionResult is the outcome of running the promotions against a cart.
+type PromotionResult struct {
+	Applied  []AppliedPromotion `json:"applied"`
+	Lines    []LineDiscount     `json:"lines"`
+	Discount float64            `json:"discount"`
+}
+
+// DiscountFor sums every promotion discount on a product's line.
+func (r *PromotionResult) DiscountFor(productID uint) float64 {
+	var total float64
+	for _, line := range r.Lines {
+		if line.ProductID == productID {
+			total += line.Amount
+		}
+	}
+	return roundCents(total)
+}
+
+// EvaluatePromotions applies the promotions running at now to the cart, by
+// descending priority. A line is never discounted below zero. An exclusive
+// promotion is skipped once another one applied and stops evaluation when it
+// applies itself; a promotion that is not stackable ignores lines an earlier
+// promotion already discounted.
+func EvaluatePromotions(promotions []models.Promotion, lines []PromotionLine, now time.Time) PromotionResult {
+	sorted := make([]models.Promotion, len(promotions))
+	copy(sorted, promotions)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].Priority != sorted[j].Priority {
+			return sorted[i].Priority > sorted[j].Priority
+		}
+		return sorted[i].ID < sorted[j].ID
+	})
+
+	remaining := make([]float64, len(lines))
+	touched := make([]bool, len(lines))
+	for i, line := range lines {
+		remaining[i] = roundCents(line.subtotal())
+	}
+
+	result := PromotionResult{Applied: []AppliedPromotion{}, Lines: []LineDiscount{}}
+	for i := range sorted {
+		promotion := &sorted[i]
+		if !promotion.ActiveAt(now) {
+			continue
+		}
+		if promotion.Exclusive && len(result.Applied) > 0 {
+			continue
+		}
+
+		available := make([]bool, len(lines))
+		for j := range lines {
+			available[j] = remaining[j] > 0 && (promotion.Stackable || !touched[j])
+		}
+
+		amounts := promotionDiscounts(promotion, lines, available)
+		var total float64
+		for j, amount := range amounts {
+			amount = math.Min(roundCents(amount), remaining[j])
+			if amount <= 0 {
+				continue
+			}
+			remaining[j] = roundCents(remaining[j] - amount)
+			touched[j] = true
+			total += amount
+			result.Lines = append(result.Lines, LineDiscount{
+				ProductID:   lines[j].ProductID,
+				PromotionID: promotion.ID,
+				Promotion:   promotion.Name,
+				Amount:      amount,
+			})
+		}
+		if total <= 0 {
+			continue
+		}
+
+		total = roundCents(total)
+		result.Applied = append(result.Applied, AppliedPromotion{PromotionID: promotion.ID, Name: promotion.Name, Discount: total})
+		result.Discount = roundCents(result.Discount + total)
+		if promotion.Exclusive {
+			break
+		}
+	}
+
+	return result
+}
+
+// promotionDiscounts works out the raw discount of one promotion on each
+// available line. The result is indexed like lines.
+func promotionDiscounts(promotion *models.Promotion, lines []PromotionLine, available []bool) []float64 {
+	amounts := make([]float64, len(lines))
+
+	switch promotion.Type {
+	case models.PromotionBuyXPayY:
+		if promotion.BuyQuantity == 0 || promotion.PayQuantity >= promotion.BuyQuantity {
+			return amounts
+		}
+
+		var qualifying []int
+		var units uint
+		for j, line := range lines {
+			if available[j] && promotionQualifies(promotion, line) {
+				qualifying = append(qualifying, j)
+				units += line.Quantity
+			}
+		}
+
+		// The cheapest units are the free ones.
+		free := units / promotion.BuyQuantity * (promotion.BuyQuantity - promotion.PayQuantity)
+		sort.SliceStable(qualifying, func(a, b int) bool {
+			return lines[qualifying[a]].UnitPrice < lines[qualifying[b]].UnitPrice
+		})
+		for _, j := range qualifying {
+			if free == 0 {
+				break
+			}
+			n := lines[j].Quantity
+			if n > free {
+				n = free
+			}
+			amounts[j] = lines[j].UnitPrice * float64(n)
+			free -= n
+		}
+
+	case models.PromotionCartPercentage:
+		if promotion.Percentage <= 0 {
+			return amounts
+		}
+
+		var subtotal float64
+		for j, line := range lines {
+			if available[j] && promotionQualifies(promotion, line) {
+				subtotal += line.subtotal()
+			}
+		}
+		if subtotal < promotion.MinAmount {
+			return amounts
+		}
+		for j, line := range lines {
+			if available[j] && promotionQualifies(promotion, line) {
+				amounts[j] = line.subtotal() * promotion.Percentage / 100
+			}
+		}
+
+	case models.PromotionFreeItem:
+		if promotion.FreeProductID == nil {
+			return amounts
+		}
+
+		reward := -1
+		var triggers uint
+		for j, line := range lines {
+			if line.ProductID == *promotion.FreeProductID {
+				reward = j
+				continue
+			}
+			if promotionQualifies(promotion, line) {
+				triggers += line.Quantity
+			}
+		}
+		if reward < 0 || !available[reward] {
+			return amounts
+		}
+
+		buy := max(promotion.BuyQuantity, 1)
+		free := triggers / buy * max(promotion.FreeQuantity, 1)
+		if free > lines[reward].Quantity {
+			free = lines[reward].Quantity
+		}
+		amounts[reward] = lines[reward].UnitPrice * float64(free)
+	}
+
+	return amounts
+}
+
+func promotionQualifies(promotion *models.Promotion, line PromotionLine) bool {
+	if len(promotion.ProductIDs) == 0 && len(promotion.Categories) == 0 {
+		return true
+	}
+	return containsID(promotion.ProductIDs, line.ProductID) || containsFold(promotion.Categories, line.Category)
+}
@@ -0,0 +1,67 @@
+package services
+
+import (
+	"errors"
+	"time"
+
+	"smart-choice/database"
+	"smart-choice/models"
+	"smart-choice/repository"
+	"smart-choice/utils"
+
+	"gorm.io/gorm"
+)
+
+var ErrPromotionNotFound = errors.New("promotion not found")
+
+func ListPromotions(pagination *utils.Pagination, active string) ([]models.Promotion, error) {
+	return repository.GetPromotions(pagination, active)
+}
+
+func GetPromotion(id uint) (*models.Promotion, error) {
+	promotion, err := repository.GetPromotionByID(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrPromotionNotFound
+		}
+		return nil, err
+	}
+	return &promotion, nil
+}
+
+func CreatePromotion(promotion *models.Promotion) error {
+	return database.DB.Create(promotion).Error
+}
+
+// UpdatePromotion replaces the promotion's rules. Orders already placed keep
+// the discounts they were given.
+func UpdatePromotion(id uint, changes models.Promotion) (*models.Promotion, error) {
+	promotion, err := GetPromotion(id)
+	if err != nil {
+		return nil, err
+	}
+
+	changes.Model = promotion.Model
+	if err := database.DB.Save(&changes).Error; err != nil {
+		return nil, err
+	}
+	return &changes, nil
+}
+
+func DeletePromotion(id uint) error {
+	promotion, err := GetPromotion(id)
+	if err != nil {
+		return err
+	}
+	return database.DB.Delete(promotion).Error
+}
+
+// applyPromotions runs the active promotions against a cart, reading them
+// through db so order placement sees them inside its transaction.
+func applyPromotions(db *gorm.DB, lines []PromotionLine) (PromotionResult, error) {
+	promotions, err := repository.GetActivePromotions(db)
+	if err != nil {
+		return PromotionResult{}, err
+	}
+	return EvaluatePromotions(promotions, lines, time.Now()), nil
+}
@@ -18,15 +18,70 @@ type RateLimitService interface {
 
 type RedisRateLimit struct {
 	client *redis.Client
+	limits rateLimits
 	mu     sync.Mutex
 }
 
+// Counters use fixed windows: one hour per user and one minute per IP.
+const (
+	userRequestWindow = time.Hour
+	ipRequestWindow   = time.Minute
+)
+
+// rateLimits are the requests allowed per user and per IP in each window.
+type rateLimits struct {
+	user int
+	ip   int
+}
+
+func defaultRateLimits() rateLimits {
+	return rateLimits{
+		user: getEnvInt("RATE_LIMIT_REQUESTS_PER_HOUR", 100),
+		ip:   getEnvInt("RATE_LIMIT_REQUESTS_PER_MINUTE", 20),
+	}
+}
+
+// UserRateLimit counts the requests of a user or an IP in the current
+// window.
 type UserRateLimit struct {
 	Requests  int       `json:"requests"`
 	Window    int       `json:"window"`
 	LastReset time.Time `json:"last_reset"`
 }
 
+// take counts a request, starting a new window if the last one is over,
+// and reports whether it is within the limit.
+func (l *UserRateLimit) take(limit int, window time.Duration, now time.Time) bool {
+	if l.LastReset.IsZero() || now.Sub(l.LastReset) >= window {
+		l.Requests = 0
+		l.Window = int(window.Seconds())
+		l.LastReset = now
+	}
+
+	if l.Requests >= limit {
+		return false
+	}
+	l.Requests++
+	return true
+}
+
+// remaining returns the requests left and the time until the window
+// resets.
+func (l UserRateLimit) remaining(limit int, window time.Duration, now time.Time) (int, time.Duration) {
+	if l.LastReset.IsZero() || now.Sub(l.LastReset) >= window {
+		return limit, window
+	}
+	return max(limit-l.Requests, 0), l.LastReset.Add(window).Sub(now)
+}
+
+func userRateLimitKey(userID uint) string {
+	return fmt.Sprintf("rate_limit:user:%d", userID)
+}
+
+func ipRateLimitKey(ip string) string {
+	return fmt.Sprintf("rate_limit:ip:%s", ip)
+}
+
 func NewRedisRateLimit(addr, password string) (*RedisRateLimit, error) {
 	rdb := redis.NewClient(&redis.Options{
 		Addr:     addr,
@@ -41,118 +96,51 @@ func NewRedisRateLimit(addr, password string) (*RedisRateLimit, error) {
 		return nil, err
 	}
 
-	return &RedisRateLimit{client: rdb}, nil
+	return &RedisRateLimit{client: rdb, limits: defaultRateLimits()}, nil
 }
 
 func (r *RedisRateLimit) Allow(ctx context.Context, userID uint, ip string) bool {
-	// Check user-specific rate limit
-	userKey := fmt.Sprintf("rate_limit:user:%d", userID)
-	userAllowed := r.checkUserLimit(ctx, userKey, 100, time.Hour) // 100 requests per hour per user
-
-	// Check IP-based rate limit
-	ipKey := fmt.Sprintf("rate_limit:ip:%s", ip)
-	ipAllowed := r.checkIPLimit(ctx, ipKey, 20, time.Minute) // 20 requests per minute per IP
+	userAllowed := r.checkLimit(ctx, userRateLimitKey(userID), r.limits.user, userRequestWindow)
+	ipAllowed := r.checkLimit(ctx, ipRateLimitKey(ip), r.limits.ip, ipRequestWindow)
 
 	return userAllowed && ipAllowed
 }
 
-func (r *RedisRateLimit) checkUserLimit(ctx context.Context, key string, limit int, window time.Duration) bool {
-	r.mu.Lock()
-	defer r.mu.Unlock()
-
-	val, err := r.client.Get(ctx, key).Result()
-	if err != nil {
-		return true // Allow on Redis errors
-	}
-
-	var userLimit UserRateLimit
-	if err := json.Unmarshal([]byte(val), &userLimit); err != nil {
-		userLimit = UserRateLimit{Window: int(window.Seconds())}
-	} else {
-		// Reset if window expired
-		if time.Since(userLimit.LastReset) > window {
-			userLimit.Requests = 0
-			userLimit.LastReset = time.Now()
-		}
-	}
-
-	if userLimit.Requests >= limit {
-		return false
-	}
-
-	userLimit.Requests++
-
-	// Save back to Redis
-	jsonData, _ := json.Marshal(userLimit)
-	r.client.Set(ctx, key, jsonData, window)
-
-	return true
-}
-
-func (r *RedisRateLimit) checkIPLimit(ctx context.Context, key string, limit int, window time.Duration) bool {
+func (r *RedisRateLimit) checkLimit(ctx context.Context, key string, limit int, window time.Duration) bool {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
+	var counter UserRateLimit
 	val, err := r.client.Get(ctx, key).Result()
-	if err != nil {
+	if err != nil && err != redis.Nil {
 		return true // Allow on Redis errors
 	}
-
-	var ipLimit struct {
-		Requests int `json:"requests"`
-		Window   int `json:"window"`
-	}
-
-	if err := json.Unmarshal([]byte(val), &ipLimit); err != nil {
-		ipLimit = struct {
-			Requests int `json:"requests"`
-			Window   int `json:"window"`
-		}{Window: int(window.Seconds())}
+	if err == nil {
+		json.Unmarshal([]byte(val), &counter)
 	}
 
-	if ipLimit.Requests >= limit {
+	now := time.Now()
+	if !counter.take(limit, window, now) {
 		return false
 	}
 
-	ipLimit.Requests++
-
-	// Save back to Redis
-	jsonData, _ := json.Marshal(ipLimit)
-	r.client.Set(ctx, key, jsonData, window)
+	// Save back to Redis, expiring with the window
+	jsonData, _ := json.Marshal(counter)
+	r.client.Set(ctx, key, jsonData, counter.LastReset.Add(window).Sub(now))
 
 	return true
 }
 
 func (r *RedisRateLimit) GetRemaining(ctx context.Context, userID uint, ip string) (int, time.Duration) {
-	userKey := fmt.Sprintf("rate_limit:user:%d", userID)
-	val, err := r.client.Get(ctx, userKey).Result()
-	if err != nil {
-		return 100, time.Hour
-	}
-
 	var userLimit UserRateLimit
-	if err := json.Unmarshal([]byte(val), &userLimit); err != nil {
-		return 100, time.Hour
+	val, err := r.client.Get(ctx, userRateLimitKey(userID)).Result()
+	if err == nil {
+		json.Unmarshal([]byte(val), &userLimit)
 	}
 
-	remaining := 100 - userLimit.Requests
-	if remaining < 0 {
-		remaining = 0
-	}
-
-	return remaining, time.Until(userLimit.LastReset.Add(time.Hour))
+	return userLimit.remaining(r.limits.user, userRequestWindow, time.Now())
 }
 
 func (r *RedisRateLimit) ResetUserLimits(ctx context.Context, userID uint) error {
-	pattern := fmt.Sprintf("rate_limit:user:%d", userID)
-	keys, err := r.client.Keys(ctx, pattern).Result()
-	if err != nil {
-		return err
-	}
-
-	if len(keys) > 0 {
-		return r.client.Del(ctx, keys...).Err()
-	}
-
-	return nil
+	return r.client.Del(ctx, userRateLimitKey(userID)).Err()
 }
@@ -0,0 +1,428 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"smart-choice/database"
+	"smart-choice/models"
+	"smart-choice/repository"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInvalidSettlementFile  = errors.New("invalid settlement file")
+	ErrReconciliationNotFound = errors.New("reconciliation run not found")
+	ErrReconciliationNotReady = errors.New("reconciliation run has not completed yet")
+)
+
+// SettlementLine is one entry of a provider settlement file.
+type SettlementLine struct {
+	Line      int
+	Reference string
+	Amount    float64
+	Currency  string
+}
+
+type reconciliationPayload struct {
+	RunID uint `json:"run_id"`
+}
+
+var settlementColumns = map[string][]string{
+	"reference": {"reference", "referencia", "provider_reference"},
+	"amount":    {"amount", "valor"},
+	"currency":  {"currency", "moeda"},
+}
+
+// ParseSettlementFile reads a CSV settlement file. The header names the
+// columns (reference and amount are required, currency is optional); both
+// "," and ";" separators and Brazilian amounts such as "1.234,56" are accepted.
+func ParseSettlementFile(r io.Reader) ([]SettlementLine, error) {
+	content, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	header, _, _ := bytes.Cut(content, []byte("\n"))
+	reader := csv.NewReader(bytes.NewReader(content))
+	if bytes.Count(header, []byte(";")) > bytes.Count(header, []byte(",")) {
+		reader.Comma = ';'
+	}
+	reader.TrimLeadingSpace = true
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSettlementFile, err)
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("%w: missing header", ErrInvalidSettlementFile)
+	}
+
+	columns := make(map[string]int)
+	for i, name := range records[0] {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		for column, aliases := range settlementColumns {
+			for _, alias := range aliases {
+				if name == alias {
+					columns[column] = i
+				}
+			}
+		}
+	}
+	for _, required := range []string{"reference", "amount"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("%w: missing %s column", ErrInvalidSettlementFile, required)
+		}
+	}
+
+	var lines []SettlementLine
+	for i, record := range records[1:] {
+		lineNumber := i + 2
+		reference := strings.TrimSpace(record[columns["reference"]])
+		if reference == "" {
+			continue
+		}
+
+		amount, err := parseSettlementAmount(record[columns["amount"]])
+		if err != nil {
+			return nil, fmt.Errorf("%w: line %d: invalid amount", ErrInvalidSettlementFile, lineNumber)
+		}
+
+		line := SettlementLine{Line: lineNumber, Reference: reference, Amount: amount}
+		if index, ok := columns["currency"]; ok {
+			line.Currency = strings.ToUpper(strings.TrimSpace(record[index]))
+		}
+		lines = append(lines, line)
+	}
+
+	return lines, nil
+}
+
+// parseSettlementAmount accepts both "1.234,56" and "1,234.56": whichever of
+// '.' and ',' comes last is the decimal separator, the other one groups
+// thousands.
+func parseSettlementAmount(value string) (float64, error) {
+	value = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(value), "R$"))
+	decimal, thousands := ".", ","
+	if strings.LastIndex(value, ",") > strings.LastIndex(value, ".") {
+		decimal, thousands = ",", "."
+	}
+	value = strings.ReplaceAll(value, thousands, "")
+	value = strings.Replace(value, decimal, ".", 1)
+	return strconv.ParseFloat(value, 64)
+}
+
+// MatchSettlementLines compares settlement lines with our payments. Every
+// payment that no line refers to is reported as missing from the file, so
+// callers pass the referenced payments plus those settled in the period.
+func MatchSettlementLines(lines []SettlementLine, payments []models.Payment) []models.ReconciliationItem {
+	byReference := make(map[string]*models.Payment, len(payments))
+	for i := range payments {
+		byReference[payments[i].ProviderReference] = &payments[i]
+	}
+
+	occurrences := make(map[string]int, len(lines))
+	for _, line := range lines {
+		occurrences[line.Reference]++
+	}
+
+	var items []models.ReconciliationItem
+	for _, line := range lines {
+		item := models.ReconciliationItem{
+			Line:       line.Line,
+			Reference:  line.Reference,
+			FileAmount: line.Amount,
+		}
+
+		payment, found := byReference[line.Reference]
+		if found {
+			item.PaymentID = &payment.ID
+			item.OrderID = &payment.OrderID
+			item.ExpectedAmount = payment.Amount
+			item.PaymentStatus = payment.Status
+		}
+
+		switch {
+		case occurrences[line.Reference] > 1:
+			item.Result = models.ReconciliationDuplicate
+		case !found:
+			item.Result = models.ReconciliationNotFound
+		case ClassifyPaymentDiscrepancy(payment.Amount, line.Amount, payment.Currency, line.Currency) != "":
+			item.Result = models.ReconciliationAmountMismatch
+		case payment.Status != models.PaymentStatusSucceeded && payment.Status != models.PaymentStatusRefunded:
+			item.Result = models.ReconciliationStatusMismatch
+		default:
+			item.Result = models.ReconciliationMatched
+		}
+		items = append(items, item)
+	}
+
+	for i := range payments {
+		payment := &payments[i]
+		if occurrences[payment.ProviderReference] > 0 {
+			continue
+		}
+		items = append(items, models.ReconciliationItem{
+			Reference:      payment.ProviderReference,
+			Result:         models.ReconciliationMissingFromFile,
+			ExpectedAmount: payment.Amount,
+			PaymentID:      &payment.ID,
+			OrderID:        &payment.OrderID,
+			PaymentStatus:  payment.Status,
+		})
+	}
+
+	return items
+}
+
+// RequestReconciliation stores an uploaded settlement file and enqueues the
+// job that reconciles it. When a period is given, payments settled in it
+// that are absent from the file are reported too.
+func RequestReconciliation(ctx context.Context, admin *models.User, provider, fileName string, content []byte, periodStart, periodEnd *time.Time) (*models.ReconciliationRun, error) {
+	if _, err := GetPaymentProviderByName(provider); err != nil {
+		return nil, err
+	}
+
+	if _, err := ParseSettlementFile(bytes.NewReader(content)); err != nil {
+		return nil, err
+	}
+
+	jobQueue := GetServiceManager().GetJobQueue()
+	if jobQueue == nil {
+		return nil, ErrJobQueueUnavailable
+	}
+
+	dir := getEnv("RECONCILIATION_DIR", filepath.Join("exports", "reconciliation"))
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	path := filepath.Join(dir, uuid.New().String()+".csv")
+	if err := os.WriteFile(path, content, 0o600); err != nil {
+		return nil, err
+	}
+
+	run := models.ReconciliationRun{
+		Provider:    provider,
+		FileName:    filepath.Base(fileName),
+		FilePath:    path,
+		PeriodStart: periodStart,
+		PeriodEnd:   periodEnd,
+		Status:      models.ReconciliationStatusPending,
+		CreatedByID: admin.ID,
+	}
+	if err := repository.CreateReconciliationRun(&run); err != nil {
+		return nil, err
+	}
+
+	payload, err := json.Marshal(reconciliationPayload{RunID: run.ID})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := jobQueue.Enqueue(ctx, Job{Type: JobTypeReconciliation, Payload: payload}); err != nil {
+		run.Status = models.ReconciliationStatusFailed
+		run.Error = "failed to enqueue reconciliation job"
+		repository.UpdateReconciliationRun(&run)
+		return nil, err
+	}
+
+	return &run, nil
+}
+
+// HandleReconciliationJob reconciles the settlement file of a run.
+func HandleReconciliationJob(ctx context.Context, job *Job) error {
+	var payload reconciliationPayload
+	if err := json.Unmarshal(job.Payload, &payload); err != nil {
+		return err
+	}
+
+	run, err := repository.GetReconciliationRun(payload.RunID)
+	if err != nil {
+		return err
+	}
+
+	run.Status = models.ReconciliationStatusProcessing
+	if err := repository.UpdateReconciliationRun(&run); err != nil {
+		return err
+	}
+
+	if err := reconcileRun(&run); err != nil {
+		run.Status = models.ReconciliationStatusFailed
+		run.Error = err.Error()
+		repository.UpdateReconciliationRun(&run)
+		return err
+	}
+
+	return nil
+}
+
+func reconcileRun(run *models.ReconciliationRun) error {
+	file, err := os.Open(run.FilePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	lines, err := ParseSettlementFile(file)
+	if err != nil {
+		return err
+	}
+
+	payments, err := reconciliationPayments(run, lines)
+	if err != nil {
+		return err
+	}
+
+	items := MatchSettlementLines(lines, payments)
+
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		// A retried job replaces the results of the previous attempt.
+		if err := tx.Where("run_id = ?", run.ID).Delete(&models.ReconciliationItem{}).Error; err != nil {
+			return err
+		}
+
+		run.TotalLines = len(lines)
+		run.Matched, run.Mismatched, run.Duplicates, run.NotFound, run.MissingFromFile = 0, 0, 0, 0, 0
+		for i := range items {
+			items[i].RunID = run.ID
+			switch items[i].Result {
+			case models.ReconciliationMatched:
+				run.Matched++
+			case models.ReconciliationAmountMismatch, models.ReconciliationStatusMismatch:
+				run.Mismatched++
+			case models.ReconciliationDuplicate:
+				run.Duplicates++
+			case models.ReconciliationNotFound:
+				run.NotFound++
+			case models.ReconciliationMissingFromFile:
+				run.MissingFromFile++
+			}
+		}
+
+		if len(items) > 0 {
+			if err := tx.CreateInBatches(items, 500).Error; err != nil {
+				return err
+			}
+		}
+
+		now := time.Now()
+		run.Status = models.ReconciliationStatusCompleted
+		run.Error = ""
+		run.CompletedAt = &now
+		return tx.Omit("Items").Save(run).Error
+	})
+}
+
+// reconciliationPayments loads the payments referenced by the file plus,
+// when the run has a period, every payment settled in it.
+func reconciliationPayments(run *models.ReconciliationRun, lines []SettlementLine) ([]models.Payment, error) {
+	seen := make(map[uint]bool)
+	var payments []models.Payment
+	add := func(batch []models.Payment) {
+		for _, payment := range batch {
+			if !seen[payment.ID] {
+				seen[payment.ID] = true
+				payments = append(payments, payment)
+			}
+		}
+	}
+
+	references := make([]string, 0, len(lines))
+	for _, line := range lines {
+		references = append(references, line.Reference)
+	}
+	for start := 0; start < len(references); start += 1000 {
+		end := min(start+1000, len(references))
+		batch, err := repository.GetPaymentsByReferences(run.Provider, references[start:end])
+		if err != nil {
+			return nil, err
+		}
+		add(batch)
+	}
+
+	if run.PeriodStart != nil && run.PeriodEnd != nil {
+		batch, err := repository.GetSettledPayments(run.Provider, *run.PeriodStart, *run.PeriodEnd)
+		if err != nil {
+			return nil, err
+		}
+		add(batch)
+	}
+
+	return payments, nil
+}
+
+func ListReconciliationRuns() ([]models.ReconciliationRun, error) {
+	return repository.GetReconciliationRuns()
+}
+
+// GetReconciliationRun returns a run with its items, optionally only those
+// with the given result.
+func GetReconciliationRun(id uint, result string) (*models.ReconciliationRun, error) {
+	run, err := repository.GetReconciliationRun(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrReconciliationNotFound
+		}
+		return nil, err
+	}
+
+	run.Items, err = repository.GetReconciliationItems(run.ID, result)
+	if err != nil {
+		return nil, err
+	}
+	return &run, nil
+}
+
+// WriteReconciliationCSV exports the results of a completed run.
+func WriteReconciliationCSV(w io.Writer, id uint) error {
+	run, err := GetReconciliationRun(id, "")
+	if err != nil {
+		return err
+	}
+	if run.Status != models.ReconciliationStatusCompleted {
+		return ErrReconciliationNotReady
+	}
+
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"line", "reference", "result", "file_amount", "expected_amount", "payment_id", "order_id", "payment_status"})
+	for _, item := range run.Items {
+		writer.Write([]string{
+			formatOptionalInt(item.Line),
+			item.Reference,
+			item.Result,
+			strconv.FormatFloat(item.FileAmount, 'f', 2, 64),
+			strconv.FormatFloat(item.ExpectedAmount, 'f', 2, 64),
+			formatOptionalID(item.PaymentID),
+			formatOptionalID(item.OrderID),
+			item.PaymentStatus,
+		})
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+func formatOptionalInt(value int) string {
+	if value == 0 {
+		return ""
+	}
+	return strconv.Itoa(value)
+}
+
+func formatOptionalID(id *uint) string {
+	if id == nil {
+		return ""
+	}
+	return strconv.FormatUint(uint64(*id), 10)
+}
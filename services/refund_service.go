@@ -0,0 +1,435 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"smart-choice/database"
+	"smart-choice/models"
+	"smart-choice/repository"
+
+	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrOrderNotRefundable      = errors.New("only paid orders can be refunded")
+	ErrRefundExceedsPaid       = errors.New("refund exceeds the amount left to refund")
+	ErrRefundItemNotFound      = errors.New("order item not found")
+	ErrRefundQuantityExceeded  = errors.New("refund quantity exceeds the quantity left to refund")
+	ErrRefundAmountRequired    = errors.New("refund amount must be greater than zero")
+	ErrRefundRequiresManual    = errors.New("the payment provider cannot refund automatically; record a manual refund")
+	ErrRefundReasonRequired    = errors.New("a refund reason is required")
+	ErrDuplicateRefundLineItem = errors.New("each order item may appear only once per refund")
+	ErrRefundDeclined          = errors.New("the payment provider declined the refund")
+)
+
+type RefundItemRequest struct {
+	OrderItemID uint `json:"order_item_id" binding:"required"`
+	Quantity    uint `json:"quantity" binding:"required"`
+}
+
+// RefundRequest describes an admin refund. Without items or amount the whole
+// remaining balance is refunded and every remaining unit restocked; with items
+// only those quantities are refunded and restocked; an amount alone refunds
+// money without returning goods. StoreCredit sends the money to the
+// customer's store credit instead of back through the provider.
+type RefundRequest struct {
+	Items       []RefundItemRequest `json:"items" binding:"dive"`
+	Amount      float64             `json:"amount"`
+	Reason      string              `json:"reason"`
+	Manual      bool                `json:"manual"`
+	StoreCredit bool                `json:"store_credit"`
+}
+
+// RefundOrder refunds a paid order through its payment provider, or records a
+// refund settled elsewhere when req.Manual is set. What the provider payment
+// cannot cover, such as the part paid with a gift card or store credit, is
+// returned as store credit. Provider refunds are committed as pending first
+// and the provider is called outside the transaction; the refund is completed
+// once the provider accepts it.
+func RefundOrder(ctx context.Context, admin *models.User, orderID uint, req RefundRequest) (*models.Refund, error) {
+	if req.Reason == "" {
+		return nil, ErrRefundReasonRequired
+	}
+
+	var refund models.Refund
+	var provider PaymentProvider
+	var providerReference string
+	providerShare := 0.0
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		order, err := lockRefundableOrder(tx, orderID)
+		if err != nil {
+			return err
+		}
+
+		var payment *models.Payment
+		var external models.Payment
+		err = tx.Where("order_id = ? AND provider <> ? AND status IN ?", order.ID, models.PaymentProviderInternal,
+			[]string{models.PaymentStatusSucceeded, models.PaymentStatusRefunded}).
+			Order("id desc").First(&external).Error
+		if err == nil {
+			payment = &external
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		remaining, err := refundableBalance(tx, order.ID)
+		if err != nil {
+			return err
+		}
+
+		refund = models.Refund{
+			OrderID:     order.ID,
+			Reason:      req.Reason,
+			Manual:      req.Manual,
+			CreatedByID: admin.ID,
+		}
+		if payment != nil {
+			refund.PaymentID = &payment.ID
+		}
+
+		pending, err := pendingRefundQuantities(tx, order.ID)
+		if err != nil {
+			return err
+		}
+
+		items, amount, err := refundLines(order, req, remaining, pending)
+		if err != nil {
+			return err
+		}
+		refund.Items = items
+		refund.Amount = amount
+
+		if refund.Amount <= 0 {
+			return ErrRefundAmountRequired
+		}
+		if refund.Amount > remaining+0.005 {
+			return ErrRefundExceedsPaid
+		}
+
+		// The provider can give back at most what its payment has left;
+		// the rest becomes store credit.
+		if payment != nil && !req.StoreCredit {
+			providerRefunded, err := refundedThroughPayment(tx, payment.ID)
+			if err != nil {
+				return err
+			}
+			providerShare = math.Min(refund.Amount, roundCents(payment.Amount-providerRefunded))
+		}
+
+		switch {
+		case req.Manual:
+			providerShare = 0
+			refund.Status = models.RefundStatusSucceeded
+		case providerShare > 0:
+			provider, err = GetPaymentProviderByName(payment.Provider)
+			if err != nil {
+				return err
+			}
+			providerReference = payment.ProviderReference
+			// Pending refunds count against the balance, so a concurrent
+			// refund cannot return the same money while the provider works.
+			refund.Status = models.RefundStatusPending
+			refund.StoreCreditAmount = roundCents(refund.Amount - providerShare)
+		default:
+			refund.Status = models.RefundStatusSucceeded
+			refund.StoreCreditAmount = refund.Amount
+		}
+
+		if err := tx.Create(&refund).Error; err != nil {
+			return err
+		}
+		if providerShare > 0 {
+			return nil
+		}
+		return completeRefundTx(tx, order, &refund, admin)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+	if providerShare == 0 {
+		return &refund, nil
+	}
+
+	result, err := provider.Refund(ctx, providerReference, providerShare)
+	if err == nil && result.Status == models.RefundStatusFailed {
+		err = ErrRefundDeclined
+	}
+	if err != nil {
+		failPendingRefund(&refund, err)
+		if errors.Is(err, ErrRefundNotSupported) {
+			return nil, ErrRefundRequiresManual
+		}
+		return nil, err
+	}
+
+	refund.Status = result.Status
+	refund.ProviderReference = result.Reference
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		order, err := lockRefundableOrder(tx, orderID)
+		if err != nil {
+			return err
+		}
+		if err := tx.Model(&models.Refund{}).Where("id = ?", refund.ID).Updates(map[string]interface{}{
+			"status":             refund.Status,
+			"provider_reference": refund.ProviderReference,
+		}).Error; err != nil {
+			return err
+		}
+		return completeRefundTx(tx, order, &refund, admin)
+	})
+
+	if err != nil {
+		// The provider has already returned the money. The refund stays
+		// pending, keeping its share reserved, for an admin to complete.
+		log.Error().Err(err).Uint("refund_id", refund.ID).Str("reference", result.Reference).
+			Msg("Failed to complete provider refund")
+		database.DB.Model(&models.Refund{}).Where("id = ?", refund.ID).Update("provider_reference", result.Reference)
+		return nil, err
+	}
+
+	return &refund, nil
+}
+
+// lockRefundableOrder locks an order with its items and checks that it can
+// still be refunded.
+func lockRefundableOrder(tx *gorm.DB, orderID uint) (*models.Order, error) {
+	var order models.Order
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Preload("OrderItems").First(&order, orderID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrOrderNotFound
+		}
+		return nil, err
+	}
+
+	switch order.Status {
+	case models.OrderStatusPaid, models.OrderStatusPartiallyRefunded,
+		models.OrderStatusShipped, models.OrderStatusDelivered:
+		return &order, nil
+	}
+	return nil, ErrOrderNotRefundable
+}
+
+// refundableBalance is what the order's payments have left to refund once
+// pending and succeeded refunds are taken out.
+func refundableBalance(tx *gorm.DB, orderID uint) (float64, error) {
+	var totalPaid float64
+	if err := tx.Model(&models.Payment{}).
+		Where("order_id = ? AND status IN ?", orderID, []string{models.PaymentStatusSucceeded, models.PaymentStatusRefunded}).
+		Select("coalesce(sum(amount), 0)").Row().Scan(&totalPaid); err != nil {
+		return 0, err
+	}
+	if totalPaid <= 0 {
+		return 0, ErrOrderNotRefundable
+	}
+
+	var alreadyRefunded float64
+	if err := tx.Model(&models.Refund{}).
+		Where("order_id = ? AND status IN ?", orderID, []string{models.RefundStatusPending, models.RefundStatusSucceeded}).
+		Select("coalesce(sum(amount), 0)").Row().Scan(&alreadyRefunded); err != nil {
+		return 0, err
+	}
+	return roundCents(totalPaid - alreadyRefunded), nil
+}
+
+// pendingRefundQuantities counts, per order item, the units of refunds still
+// waiting on the provider. They are only added to RefundedQuantity once the
+// refund completes, so without them a concurrent refund could return and
+// restock the same units twice.
+func pendingRefundQuantities(tx *gorm.DB, orderID uint) (map[uint]uint, error) {
+	var rows []struct {
+		OrderItemID uint
+		Quantity    uint
+	}
+	err := tx.Model(&models.RefundItem{}).
+		Select("refund_items.order_item_id, coalesce(sum(refund_items.quantity), 0) AS quantity").
+		Joins("JOIN refunds ON refunds.id = refund_items.refund_id AND refunds.deleted_at IS NULL").
+		Where("refunds.order_id = ? AND refunds.status = ?", orderID, models.RefundStatusPending).
+		Group("refund_items.order_item_id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	pending := make(map[uint]uint, len(rows))
+	for _, row := range rows {
+		pending[row.OrderItemID] = row.Quantity
+	}
+	return pending, nil
+}
+
+// completeRefundTx applies a refund the provider accepted, or one that needs
+// no provider: it credits the store credit share, restocks the returned
+// units and moves the order to its refunded status.
+func completeRefundTx(tx *gorm.DB, order *models.Order, refund *models.Refund, admin *models.User) error {
+	if refund.StoreCreditAmount > 0 {
+		if _, err := moveStoreCreditTx(tx, models.StoreCreditEntry{
+			UserID:      order.UserID,
+			Amount:      refund.StoreCreditAmount,
+			Reason:      fmt.Sprintf("refund %d: %s", refund.ID, refund.Reason),
+			OrderID:     &order.ID,
+			RefundID:    &refund.ID,
+			CreatedByID: &admin.ID,
+		}); err != nil {
+			return err
+		}
+	}
+
+	productIDs := make(map[uint]uint, len(order.OrderItems))
+	for _, orderItem := range order.OrderItems {
+		productIDs[orderItem.ID] = orderItem.ProductID
+	}
+
+	for _, item := range refund.Items {
+		if err := tx.Model(&models.OrderItem{}).Where("id = ?", item.OrderItemID).
+			UpdateColumn("refunded_quantity", gorm.Expr("refunded_quantity + ?", item.Quantity)).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.Product{}).Where("id = ?", productIDs[item.OrderItemID]).
+			UpdateColumn("stock", gorm.Expr("stock + ?", item.Quantity)).Error; err != nil {
+			return err
+		}
+	}
+
+	// The refund itself is already counted, so nothing left means the
+	// order is fully refunded.
+	remaining, err := refundableBalance(tx, order.ID)
+	if err != nil {
+		return err
+	}
+	if err := clawbackLoyaltyPointsTx(tx, order, refund.Amount, remaining+refund.Amount); err != nil {
+		return err
+	}
+
+	// Shipped and delivered orders keep their fulfillment status on a
+	// partial refund, so they cannot be shipped or delivered again.
+	status := order.Status
+	switch {
+	case remaining <= 0.005:
+		status = models.OrderStatusRefunded
+		if err := tx.Model(&models.Payment{}).
+			Where("order_id = ? AND status = ?", order.ID, models.PaymentStatusSucceeded).
+			Update("status", models.PaymentStatusRefunded).Error; err != nil {
+			return err
+		}
+	case order.Status == models.OrderStatusPaid:
+		status = models.OrderStatusPartiallyRefunded
+	}
+	if err := tx.Model(order).Update("status", status).Error; err != nil {
+		return err
+	}
+
+	activityLog := models.ActivityLog{
+		UserID:    order.UserID,
+		Action:    fmt.Sprintf("Order %d refunded R$%.2f by admin %d: %s", order.ID, refund.Amount, admin.ID, refund.Reason),
+		Timestamp: time.Now(),
+	}
+	return tx.Create(&activityLog).Error
+}
+
+// failPendingRefund marks a refund the provider did not take as failed,
+// which releases its share of the balance.
+func failPendingRefund(refund *models.Refund, cause error) {
+	log.Warn().Err(cause).Uint("refund_id", refund.ID).Msg("Provider refund failed")
+	refund.Status = models.RefundStatusFailed
+	if err := database.DB.Model(&models.Refund{}).Where("id = ?", refund.ID).
+		Update("status", refund.Status).Error; err != nil {
+		log.Error().Err(err).Uint("refund_id", refund.ID).Msg("Failed to mark refund as failed")
+	}
+}
+
+// refundLines works out which units are returned and how much money goes
+// back for a refund request. Units are valued at what was paid for them,
+// net of promotions, the coupon and redeemed points.
+func refundLines(order *models.Order, req RefundRequest, remaining float64, pending map[uint]uint) ([]models.RefundItem, float64, error) {
+	orderItems := order.OrderItems
+	if len(req.Items) == 0 {
+		if req.Amount != 0 {
+			return nil, roundCents(req.Amount), nil
+		}
+
+		// Full refund: return every unit not refunded yet.
+		var items []models.RefundItem
+		for _, orderItem := range orderItems {
+			left := refundableQuantity(&orderItem, pending)
+			if left == 0 {
+				continue
+			}
+			items = append(items, models.RefundItem{
+				OrderItemID: orderItem.ID,
+				Quantity:    left,
+				Amount:      roundCents(order.NetUnitPrice(&orderItem) * float64(left)),
+			})
+		}
+		return items, remaining, nil
+	}
+
+	var items []models.RefundItem
+	var amount float64
+	seen := make(map[uint]bool)
+	for _, requested := range req.Items {
+		if seen[requested.OrderItemID] {
+			return nil, 0, ErrDuplicateRefundLineItem
+		}
+		seen[requested.OrderItemID] = true
+
+		var orderItem *models.OrderItem
+		for i := range orderItems {
+			if orderItems[i].ID == requested.OrderItemID {
+				orderItem = &orderItems[i]
+			}
+		}
+		if orderItem == nil {
+			return nil, 0, fmt.Errorf("%w: %d", ErrRefundItemNotFound, requested.OrderItemID)
+		}
+		if requested.Quantity == 0 || requested.Quantity > refundableQuantity(orderItem, pending) {
+			return nil, 0, ErrRefundQuantityExceeded
+		}
+
+		line := models.RefundItem{
+			OrderItemID: orderItem.ID,
+			Quantity:    requested.Quantity,
+			Amount:      roundCents(order.NetUnitPrice(orderItem) * float64(requested.Quantity)),
+		}
+		items = append(items, line)
+		amount += line.Amount
+	}
+
+	// An explicit amount overrides the item prices, e.g. to keep a restocking
+	// fee. Item prices are capped because discounts may leave less to refund.
+	if req.Amount != 0 {
+		return items, roundCents(req.Amount), nil
+	}
+
+	return items, roundCents(math.Min(amount, remaining)), nil
+}
+
+// refundableQuantity is how many units of the item are neither refunded nor
+// part of a pending refund.
+func refundableQuantity(orderItem *models.OrderItem, pending map[uint]uint) uint {
+	taken := orderItem.RefundedQuantity + pending[orderItem.ID]
+	if taken >= orderItem.Quantity {
+		return 0
+	}
+	return orderItem.Quantity - taken
+}
+
+// refundedThroughPayment sums what refunds already returned through a
+// provider payment, leaving out their store credit share.
+func refundedThroughPayment(tx *gorm.DB, paymentID uint) (float64, error) {
+	var refunded float64
+	err := tx.Model(&models.Refund{}).
+		Where("payment_id = ? AND status IN ?", paymentID, []string{models.RefundStatusPending, models.RefundStatusSucceeded}).
+		Select("coalesce(sum(amount - store_credit_amount), 0)").Row().Scan(&refunded)
+	return roundCents(refunded), err
+}
+
+func ListOrderRefunds(orderID uint) ([]models.Refund, error) {
+	return repository.GetRefundsByOrderID(orderID)
+}
@@ -0,0 +1,111 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+type ScheduledTask struct {
+	Name     string
+	Interval time.Duration
+	Run      func(ctx context.Context) error
+}
+
+// Scheduler runs maintenance tasks periodically inside the process. Every
+// instance keeps the same tickers; before running a task, an instance must
+// win a lock on the current interval, so each task runs once per interval
+// however many instances are up.
+type Scheduler struct {
+	tasks  []ScheduledTask
+	locks  CacheService
+	owner  string
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewScheduler returns an empty scheduler. Without locks every instance
+// runs every task.
+func NewScheduler(locks CacheService) *Scheduler {
+	owner, _ := os.Hostname()
+	return &Scheduler{locks: locks, owner: owner}
+}
+
+// NewDefaultScheduler returns a scheduler with the application's periodic
+// maintenance tasks registered.
+func NewDefaultScheduler(locks CacheService) *Scheduler {
+	s := NewScheduler(locks)
+	s.Every("pix_expiry", GetEnvDuration("PIX_EXPIRY_CHECK_INTERVAL", time.Minute), ExpirePixCharges)
+	s.Every("boleto_expiry", GetEnvDuration("BOLETO_EXPIRY_CHECK_INTERVAL", time.Hour), CancelExpiredBoletoOrders)
+	s.Every("loyalty_expiry", GetEnvDuration("LOYALTY_EXPIRY_CHECK_INTERVAL", time.Hour), ExpireLoyaltyPoints)
+	s.Every("job_promoter", GetEnvDuration("JOB_PROMOTE_INTERVAL", time.Second), PromoteJobQueue)
+	s.Every("job_reaper", GetEnvDuration("JOB_REAPER_INTERVAL", 15*time.Second), ReapJobQueue)
+	return s
+}
+
+func (s *Scheduler) Every(name string, interval time.Duration, run func(ctx context.Context) error) {
+	s.tasks = append(s.tasks, ScheduledTask{Name: name, Interval: interval, Run: run})
+}
+
+func (s *Scheduler) Start(ctx context.Context) {
+	ctx, s.cancel = context.WithCancel(ctx)
+
+	for _, task := range s.tasks {
+		s.wg.Add(1)
+		go func(task ScheduledTask) {
+			defer s.wg.Done()
+			s.loop(ctx, task)
+		}(task)
+	}
+
+	log.Info().Int("tasks", len(s.tasks)).Msg("Scheduler started")
+}
+
+// Stop cancels the tasks and waits for running executions to return.
+func (s *Scheduler) Stop() {
+	if s.cancel == nil {
+		return
+	}
+	s.cancel()
+	s.wg.Wait()
+	log.Info().Msg("Scheduler stopped")
+}
+
+func (s *Scheduler) loop(ctx context.Context, task ScheduledTask) {
+	ticker := time.NewTicker(task.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := s.RunOnce(ctx, task, time.Now()); err != nil {
+				log.Error().Err(err).Str("task", task.Name).Msg("Scheduled task failed")
+			}
+		}
+	}
+}
+
+// RunOnce runs the task for the interval containing at unless another
+// instance already claimed that interval. It reports whether this call ran
+// the task.
+func (s *Scheduler) RunOnce(ctx context.Context, task ScheduledTask, at time.Time) (bool, error) {
+	if s.locks != nil {
+		window := at.Truncate(task.Interval)
+		key := fmt.Sprintf("scheduled_task:%s:%d", task.Name, window.UnixNano())
+		acquired, err := s.locks.SetNX(ctx, key, s.owner, task.Interval)
+		if err != nil {
+			return false, err
+		}
+		if !acquired {
+			return false, nil
+		}
+	}
+
+	return true, task.Run(ctx)
+}
@@ -23,6 +23,9 @@ func GetProductMetaTags(productID uint) (*MetaTags, error) {
 	title := fmt.Sprintf("%s - Compre Agora | Smart Choice", product.Name)
 	description := fmt.Sprintf("Compre %s por apenas R$%.2f. %s. Frete rápido e seguro.",
 		product.Name, product.Price, truncateString(product.Description, 150))
+	if offer, ok := BestInstallmentOffer(product.Price, DefaultInstallmentRules()); ok {
+		description += fmt.Sprintf(" Ou em até %s.", offer.Label())
+	}
 
 	return &MetaTags{
 		Title:       title,
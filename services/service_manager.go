@@ -4,13 +4,21 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"strconv"
 	"sync"
 	"time"
 
 	"github.com/rs/zerolog/log"
 )
 
+// Service backends selectable with SERVICE_BACKEND.
+const (
+	ServiceBackendRedis  = "redis"
+	ServiceBackendMemory = "memory"
+)
+
 type ServiceManager struct {
+	backend          string
 	cacheService     CacheService
 	jobQueue         JobQueue
 	rateLimitService RateLimitService
@@ -38,6 +46,27 @@ func (sm *ServiceManager) InitializeServices() error {
 		return nil
 	}
 
+	backend := getEnv("SERVICE_BACKEND", ServiceBackendRedis)
+	switch backend {
+	case ServiceBackendRedis:
+		if err := sm.initializeRedisServices(); err != nil {
+			return err
+		}
+	case ServiceBackendMemory:
+		if err := sm.initializeMemoryServices(); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unknown SERVICE_BACKEND %q: use %s or %s", backend, ServiceBackendRedis, ServiceBackendMemory)
+	}
+
+	sm.backend = backend
+	sm.initialized = true
+	log.Info().Str("backend", backend).Msg("All services initialized successfully")
+	return nil
+}
+
+func (sm *ServiceManager) initializeRedisServices() error {
 	redisAddr := getEnv("REDIS_ADDR", "localhost:6379")
 	redisPassword := getEnv("REDIS_PASSWORD", "")
 
@@ -61,9 +90,21 @@ func (sm *ServiceManager) InitializeServices() error {
 		return fmt.Errorf("failed to initialize rate limit service: %w", err)
 	}
 	sm.rateLimitService = rateLimit
+	return nil
+}
 
-	sm.initialized = true
-	log.Info().Msg("All services initialized successfully")
+// initializeMemoryServices sets up in-process services. State is not shared
+// between instances, so this is meant for single-node deployments, local
+// development and tests.
+func (sm *ServiceManager) initializeMemoryServices() error {
+	jobQueue, err := NewMemoryJobQueue()
+	if err != nil {
+		return fmt.Errorf("failed to initialize job queue: %w", err)
+	}
+
+	sm.cacheService = NewMemoryCache()
+	sm.jobQueue = jobQueue
+	sm.rateLimitService = NewMemoryRateLimit()
 	return nil
 }
 
@@ -160,6 +201,7 @@ func (sm *ServiceManager) HealthCheck(ctx context.Context) map[string]interface{
 	}
 
 	health["services_initialized"] = sm.initialized
+	health["services_backend"] = sm.backend
 
 	return health
 }
@@ -170,3 +212,32 @@ func getEnv(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+func getEnvInt(key string, defaultValue int) int {
+	if value := os.Getenv(key); value != "" {
+		if intValue, err := strconv.Atoi(value); err == nil {
+			return intValue
+		}
+	}
+	return defaultValue
+}
+
+func getEnvFloat(key string, defaultValue float64) float64 {
+	if value := os.Getenv(key); value != "" {
+		if floatValue, err := strconv.ParseFloat(value, 64); err == nil {
+			return floatValue
+		}
+	}
+	return defaultValue
+}
+
+// GetEnvDuration reads a duration such as "90s" from the environment,
+// falling back to defaultValue when it is unset or invalid.
+func GetEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	if value := os.Getenv(key); value != "" {
+		if duration, err := time.ParseDuration(value); err == nil {
+			return duration
+		}
+	}
+	return defaultValue
+}
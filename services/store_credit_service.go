@@ -0,0 +1,144 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"smart-choice/database"
+	"smart-choice/models"
+	"smart-choice/repository"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrUserNotFound              = errors.New("user not found")
+	ErrStoreCreditEmpty          = errors.New("no store credit available")
+	ErrStoreCreditAmountRequired = errors.New("store credit amount must not be zero")
+	ErrStoreCreditReasonRequired = errors.New("a reason is required to adjust store credit")
+)
+
+// StoreCreditAdjustment is an admin crediting (positive amount) or debiting
+// (negative amount) a user's store credit.
+type StoreCreditAdjustment struct {
+	Amount float64 `json:"amount" binding:"required"`
+	Reason string  `json:"reason"`
+}
+
+// StoreCreditStatement is a user's store credit balance and ledger.
+type StoreCreditStatement struct {
+	Balance float64                   `json:"balance"`
+	Entries []models.StoreCreditEntry `json:"entries"`
+}
+
+func GetStoreCreditStatement(userID uint) (*StoreCreditStatement, error) {
+	account, err := repository.GetStoreCreditAccount(database.DB, userID)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	entries, err := repository.GetStoreCreditEntries(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &StoreCreditStatement{Balance: account.Balance, Entries: entries}, nil
+}
+
+func AdjustStoreCredit(admin *models.User, userID uint, req StoreCreditAdjustment) (*models.StoreCreditEntry, error) {
+	if roundCents(req.Amount) == 0 {
+		return nil, ErrStoreCreditAmountRequired
+	}
+	if req.Reason == "" {
+		return nil, ErrStoreCreditReasonRequired
+	}
+	if _, err := repository.GetUserByID(userID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+
+	var entry *models.StoreCreditEntry
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		entry, err = moveStoreCreditTx(tx, models.StoreCreditEntry{
+			UserID:      userID,
+			Amount:      roundCents(req.Amount),
+			Reason:      req.Reason,
+			CreatedByID: &admin.ID,
+		})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+// spendStoreCreditTx takes up to due from the user's store credit for the
+// order.
+func spendStoreCreditTx(tx *gorm.DB, userID, orderID uint, due float64) (float64, *models.StoreCreditEntry, error) {
+	account, err := lockStoreCreditAccount(tx, userID)
+	if err != nil {
+		return 0, nil, err
+	}
+	if account.Balance <= 0 {
+		return 0, nil, ErrStoreCreditEmpty
+	}
+
+	amount := roundCents(math.Min(account.Balance, due))
+	entry, err := moveStoreCreditTx(tx, models.StoreCreditEntry{
+		UserID:  userID,
+		Amount:  -amount,
+		Reason:  fmt.Sprintf("order %d", orderID),
+		OrderID: &orderID,
+	})
+	if err != nil {
+		return 0, nil, err
+	}
+	return amount, entry, nil
+}
+
+// moveStoreCreditTx applies a ledger entry to the user's balance. Debits are
+// guarded in SQL so the balance never goes negative, even for concurrent
+// checkouts.
+func moveStoreCreditTx(tx *gorm.DB, entry models.StoreCreditEntry) (*models.StoreCreditEntry, error) {
+	account, err := lockStoreCreditAccount(tx, entry.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := tx.Model(&models.StoreCreditAccount{}).
+		Where("id = ? AND balance + ? >= 0", account.ID, entry.Amount).
+		UpdateColumn("balance", gorm.Expr("balance + ?", entry.Amount))
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrInsufficientBalance
+	}
+
+	entry.BalanceAfter = roundCents(account.Balance + entry.Amount)
+	if err := tx.Create(&entry).Error; err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// lockStoreCreditAccount opens the user's account on first use and locks it
+// for the rest of the transaction.
+func lockStoreCreditAccount(tx *gorm.DB, userID uint) (*models.StoreCreditAccount, error) {
+	if err := tx.Clauses(clause.OnConflict{Columns: []clause.Column{{Name: "user_id"}}, DoNothing: true}).
+		Create(&models.StoreCreditAccount{UserID: userID}).Error; err != nil {
+		return nil, err
+	}
+
+	var account models.StoreCreditAccount
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("user_id = ?", userID).First(&account).Error; err != nil {
+		return nil, err
+	}
+	return &account, nil
+}
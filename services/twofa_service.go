@@ -3,6 +3,7 @@ package services
 import (
 	"bytes"
 	"encoding/base64"
+	"image"
 	"image/png"
 
 	"smart-choice/models"
@@ -26,17 +27,28 @@ func Generate2FA(user *models.User) (string, string, error) {
 		return "", "", err
 	}
 
-	var buf bytes.Buffer
 	img, err := key.Image(200, 200)
 	if err != nil {
 		return "", "", err
 	}
-	png.Encode(&buf, img)
-	qrCode := base64.StdEncoding.EncodeToString(buf.Bytes())
+	qrCode, err := encodePNGBase64(img)
+	if err != nil {
+		return "", "", err
+	}
 
 	return qrCode, key.Secret(), nil
 }
 
+// encodePNGBase64 encodes an image as a base64 PNG, the format the API uses
+// for QR codes and barcodes.
+func encodePNGBase64(img image.Image) (string, error) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+}
+
 func Validate2FA(user *models.User, code string) bool {
 	return totp.Validate(code, user.TwoFASecret)
 }
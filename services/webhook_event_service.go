@@ -0,0 +1,149 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"smart-choice/database"
+	"smart-choice/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrWebhookTimestampOutOfRange = errors.New("webhook timestamp is outside the accepted window")
+	ErrWebhookEventNotFound       = errors.New("webhook event not found")
+	ErrUnknownWebhookProvider     = errors.New("no handler registered for webhook provider")
+)
+
+// WebhookEventHandler applies the payload of an already verified event
+// within tx, the transaction that records the event's outcome, and returns
+// any payment review it opened. Handlers must tolerate being run again for
+// the same event on replay.
+type WebhookEventHandler func(tx *gorm.DB, payload string) (*models.PaymentReview, error)
+
+var webhookEventHandlers = map[string]WebhookEventHandler{
+	"payment": HandlePaymentEvent,
+	"pix":     HandlePixEvent,
+	"boleto":  HandleBoletoEvent,
+}
+
+// WebhookEnvelope is a verified delivery together with its metadata.
+type WebhookEnvelope struct {
+	Provider  string
+	Timestamp time.Time
+	Payload   string
+}
+
+// WebhookEventID identifies a delivery for deduplication. Only the payload
+// is covered by every signature scheme, so the ID is its "event_id" field
+// or, without one, its SHA-256; headers could be changed by whoever resends
+// a captured body.
+func WebhookEventID(payload string) string {
+	var body struct {
+		EventID string `json:"event_id"`
+	}
+	if json.Unmarshal([]byte(payload), &body) == nil && body.EventID != "" {
+		return body.EventID
+	}
+
+	sum := sha256.Sum256([]byte(payload))
+	return hex.EncodeToString(sum[:])
+}
+
+// ReceiveWebhookEvent stores an incoming event and processes it once.
+// duplicate is true when the event had already been processed, in which case
+// the delivery should simply be acknowledged.
+func ReceiveWebhookEvent(envelope WebhookEnvelope) (event *models.WebhookEvent, duplicate bool, err error) {
+	tolerance := GetEnvDuration("WEBHOOK_TOLERANCE", 5*time.Minute)
+	if envelope.Timestamp.IsZero() || time.Since(envelope.Timestamp).Abs() > tolerance {
+		return nil, false, ErrWebhookTimestampOutOfRange
+	}
+
+	eventID := WebhookEventID(envelope.Payload)
+	record := models.WebhookEvent{
+		Provider:       envelope.Provider,
+		EventID:        eventID,
+		Payload:        envelope.Payload,
+		EventTimestamp: envelope.Timestamp,
+		Status:         models.WebhookEventStatusReceived,
+	}
+	if err := database.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&record).Error; err != nil {
+		return nil, false, err
+	}
+	if record.ID == 0 {
+		if err := database.DB.Where("provider = ? AND event_id = ?", envelope.Provider, eventID).
+			First(&record).Error; err != nil {
+			return nil, false, err
+		}
+	}
+
+	return processWebhookEvent(record.ID, false)
+}
+
+// ReplayWebhookEvent runs a stored event through its handler again, even if
+// it was processed before.
+func ReplayWebhookEvent(id uint) (*models.WebhookEvent, error) {
+	event, _, err := processWebhookEvent(id, true)
+	return event, err
+}
+
+// processWebhookEvent runs the handler while holding a lock on the event row,
+// so concurrent deliveries of one event are processed only once. The handler
+// works in the same transaction, under a savepoint, so its changes commit
+// together with the event's status and a failure only rolls back its own.
+// The handler's error is returned after the outcome has been recorded.
+func processWebhookEvent(id uint, replay bool) (*models.WebhookEvent, bool, error) {
+	var event models.WebhookEvent
+	var review *models.PaymentReview
+	var handlerErr error
+	skipped := false
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&event, id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrWebhookEventNotFound
+			}
+			return err
+		}
+
+		if event.Status == models.WebhookEventStatusProcessed && !replay {
+			skipped = true
+			return nil
+		}
+
+		handler, ok := webhookEventHandlers[event.Provider]
+		if !ok {
+			return ErrUnknownWebhookProvider
+		}
+
+		event.Attempts++
+		handlerErr = tx.Transaction(func(handlerTx *gorm.DB) error {
+			var err error
+			review, err = handler(handlerTx, event.Payload)
+			return err
+		})
+		if handlerErr != nil {
+			review = nil
+			event.Status = models.WebhookEventStatusFailed
+			event.LastError = handlerErr.Error()
+		} else {
+			now := time.Now()
+			event.Status = models.WebhookEventStatusProcessed
+			event.LastError = ""
+			event.ProcessedAt = &now
+		}
+		return tx.Save(&event).Error
+	})
+
+	if err != nil {
+		return nil, false, err
+	}
+
+	countPaymentReview(review)
+	return &event, skipped, handlerErr
+}
@@ -0,0 +1,189 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrInvalidSignature         = errors.New("invalid signature")
+	ErrMissingSignature         = errors.New("missing webhook signature")
+	ErrWebhookNotConfigured     = errors.New("no webhook secret configured for provider")
+	ErrUnknownWebhookVerifier   = errors.New("no webhook verifier registered for provider")
+	ErrMalformedSignatureHeader = errors.New("malformed webhook signature header")
+)
+
+const (
+	WebhookSchemeHMAC        = "hmac"
+	WebhookSchemeTimestamped = "timestamped"
+)
+
+// WebhookVerifier authenticates a webhook delivery from its raw body and
+// headers. It returns the signed delivery time when the scheme carries one.
+type WebhookVerifier interface {
+	Verify(body []byte, header http.Header) (time.Time, error)
+}
+
+// HMACVerifier expects the hex HMAC-SHA256 of the raw body in a header.
+// Any of the secrets is accepted so that they can be rotated.
+type HMACVerifier struct {
+	Header  string
+	Secrets []string
+}
+
+func (v HMACVerifier) Verify(body []byte, header http.Header) (time.Time, error) {
+	if len(v.Secrets) == 0 {
+		return time.Time{}, ErrWebhookNotConfigured
+	}
+
+	signature := header.Get(v.Header)
+	if signature == "" {
+		return time.Time{}, ErrMissingSignature
+	}
+
+	for _, secret := range v.Secrets {
+		if hmac.Equal([]byte(signature), []byte(signWebhookPayload(secret, body))) {
+			return time.Time{}, nil
+		}
+	}
+	return time.Time{}, ErrInvalidSignature
+}
+
+// TimestampedVerifier expects a header of the form "t=<unix>,v1=<hex>", where
+// v1 is the HMAC-SHA256 of "<unix>.<body>". The timestamp is covered by the
+// signature, so it cannot be altered to replay an old delivery. Senders may
+// include several v1 values while rotating secrets.
+type TimestampedVerifier struct {
+	Header  string
+	Secrets []string
+}
+
+func (v TimestampedVerifier) Verify(body []byte, header http.Header) (time.Time, error) {
+	if len(v.Secrets) == 0 {
+		return time.Time{}, ErrWebhookNotConfigured
+	}
+
+	value := header.Get(v.Header)
+	if value == "" {
+		return time.Time{}, ErrMissingSignature
+	}
+
+	var timestamp string
+	var signatures []string
+	for _, part := range strings.Split(value, ",") {
+		key, val, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			return time.Time{}, ErrMalformedSignatureHeader
+		}
+		switch key {
+		case "t":
+			timestamp = val
+		case "v1":
+			signatures = append(signatures, val)
+		}
+	}
+
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil || len(signatures) == 0 {
+		return time.Time{}, ErrMalformedSignatureHeader
+	}
+
+	signed := append([]byte(timestamp+"."), body...)
+	for _, secret := range v.Secrets {
+		expected := signWebhookPayload(secret, signed)
+		for _, signature := range signatures {
+			if hmac.Equal([]byte(signature), []byte(expected)) {
+				return time.Unix(unix, 0), nil
+			}
+		}
+	}
+	return time.Time{}, ErrInvalidSignature
+}
+
+// SignWebhookPayload returns the signature header value a sender would use
+// for a timestamped delivery. It is used by tests and local tooling.
+func SignWebhookPayload(secret string, timestamp time.Time, body []byte) string {
+	unix := strconv.FormatInt(timestamp.Unix(), 10)
+	return fmt.Sprintf("t=%s,v1=%s", unix, signWebhookPayload(secret, append([]byte(unix+"."), body...)))
+}
+
+func signWebhookPayload(secret string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+var (
+	webhookVerifiersMu   sync.RWMutex
+	webhookVerifiers     = make(map[string]WebhookVerifier)
+	webhookVerifiersOnce sync.Once
+)
+
+// registerDefaultWebhookVerifiers builds a verifier for each webhook route
+// from the environment, on first use so that .env has been loaded. Every
+// route defaults to the timestamped scheme, whose signature also covers the
+// delivery time; plain HMAC must be chosen explicitly.
+func registerDefaultWebhookVerifiers() {
+	webhookVerifiersOnce.Do(func() {
+		for _, provider := range []string{"payment", "pix", "boleto"} {
+			addWebhookVerifier(provider, webhookVerifierFromEnv(provider, WebhookSchemeTimestamped))
+		}
+	})
+}
+
+// webhookVerifierFromEnv reads <PROVIDER>_WEBHOOK_SCHEME and
+// <PROVIDER>_WEBHOOK_SECRETS (comma-separated, falling back to
+// WEBHOOK_SECRETS and then WEBHOOK_SECRET).
+func webhookVerifierFromEnv(provider, defaultScheme string) WebhookVerifier {
+	prefix := strings.ToUpper(provider) + "_WEBHOOK_"
+	secrets := webhookSecrets(getEnv(prefix+"SECRETS", getEnv("WEBHOOK_SECRETS", getEnv("WEBHOOK_SECRET", ""))))
+
+	if getEnv(prefix+"SCHEME", defaultScheme) == WebhookSchemeTimestamped {
+		return TimestampedVerifier{Header: "X-Webhook-Signature", Secrets: secrets}
+	}
+	return HMACVerifier{Header: "X-Webhook-Signature", Secrets: secrets}
+}
+
+func webhookSecrets(value string) []string {
+	var secrets []string
+	for _, secret := range strings.Split(value, ",") {
+		if secret = strings.TrimSpace(secret); secret != "" {
+			secrets = append(secrets, secret)
+		}
+	}
+	return secrets
+}
+
+// RegisterWebhookVerifier sets the verifier used for a webhook route.
+func RegisterWebhookVerifier(provider string, verifier WebhookVerifier) {
+	registerDefaultWebhookVerifiers()
+	addWebhookVerifier(provider, verifier)
+}
+
+func addWebhookVerifier(provider string, verifier WebhookVerifier) {
+	webhookVerifiersMu.Lock()
+	defer webhookVerifiersMu.Unlock()
+	webhookVerifiers[provider] = verifier
+}
+
+// VerifyWebhook authenticates a delivery with the verifier of its route.
+func VerifyWebhook(provider string, body []byte, header http.Header) (time.Time, error) {
+	registerDefaultWebhookVerifiers()
+
+	webhookVerifiersMu.RLock()
+	verifier, ok := webhookVerifiers[provider]
+	webhookVerifiersMu.RUnlock()
+	if !ok {
+		return time.Time{}, fmt.Errorf("%w: %s", ErrUnknownWebhookVerifier, provider)
+	}
+
+	return verifier.Verify(body, header)
+}
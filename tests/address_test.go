@@ -0,0 +1,32 @@
+package tests
+
+import (
+	"smart-choice/utils"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateCEP(t *testing.T) {
+	assert.True(t, utils.ValidateCEP("01310-100"))
+	assert.True(t, utils.ValidateCEP("01310100"))
+	assert.False(t, utils.ValidateCEP("01310-10"))
+	assert.False(t, utils.ValidateCEP("0131a-100"))
+	assert.False(t, utils.ValidateCEP("00000-000"))
+	assert.Equal(t, "01310100", utils.NormalizeCEP("01310-100"))
+}
+
+func TestValidateUF(t *testing.T) {
+	assert.True(t, utils.ValidateUF("SP"))
+	assert.True(t, utils.ValidateUF("rj"))
+	assert.False(t, utils.ValidateUF("XX"))
+	assert.False(t, utils.ValidateUF(""))
+}
+
+func TestValidateAddress(t *testing.T) {
+	errors := utils.ValidateAddress("01310-100", "Avenida Paulista", "1000", "Bela Vista", "São Paulo", "SP")
+	assert.Empty(t, errors)
+
+	errors = utils.ValidateAddress("123", "", "", "Bela Vista", "São Paulo", "ZZ")
+	assert.Len(t, errors, 4)
+}
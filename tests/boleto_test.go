@@ -0,0 +1,62 @@
+package tests
+
+import (
+	"smart-choice/services"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBoletoDueDateFactor(t *testing.T) {
+	factor, err := services.BoletoDueDateFactor(time.Date(2000, 7, 3, 0, 0, 0, 0, time.UTC))
+	assert.NoError(t, err)
+	assert.Equal(t, 1000, factor)
+
+	factor, err = services.BoletoDueDateFactor(time.Date(2025, 2, 21, 0, 0, 0, 0, time.UTC))
+	assert.NoError(t, err)
+	assert.Equal(t, 9999, factor)
+
+	// The factor wraps back to 1000 after 9999
+	factor, err = services.BoletoDueDateFactor(time.Date(2025, 2, 22, 0, 0, 0, 0, time.UTC))
+	assert.NoError(t, err)
+	assert.Equal(t, 1000, factor)
+}
+
+func TestBoletoBarcodeAndDigitableLine(t *testing.T) {
+	boleto := services.Boleto{
+		BankCode:  "001",
+		Agreement: "1234567",
+		Sequence:  "42",
+		Wallet:    "17",
+		Amount:    159.90,
+		DueDate:   time.Date(2026, 10, 25, 0, 0, 0, 0, time.UTC),
+	}
+
+	assert.Equal(t, "12345670000000042", boleto.NossoNumero())
+
+	barcode, err := boleto.Barcode()
+	assert.NoError(t, err)
+	assert.Equal(t, "00196161000000159900000001234567000000004217", barcode)
+
+	line, err := services.BoletoDigitableLine(barcode)
+	assert.NoError(t, err)
+	assert.Equal(t, "00190.00009 01234.567004 00000.042176 6 16100000015990", line)
+}
+
+func TestBoletoRejectsInvalidFields(t *testing.T) {
+	boleto := services.Boleto{
+		BankCode:  "001",
+		Agreement: "12A4567",
+		Sequence:  "42",
+		Wallet:    "17",
+		Amount:    10,
+		DueDate:   time.Date(2026, 10, 25, 0, 0, 0, 0, time.UTC),
+	}
+
+	_, err := boleto.Barcode()
+	assert.Error(t, err)
+
+	_, err = services.BoletoDigitableLine("123")
+	assert.Error(t, err)
+}
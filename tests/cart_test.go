@@ -0,0 +1,114 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"smart-choice/database"
+	"smart-choice/middlewares"
+	"smart-choice/models"
+	"smart-choice/routes"
+	"smart-choice/services"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupCartRouter builds the full router and a signed-in customer, so
+// requests go through the same middlewares as in production.
+func setupCartRouter(t *testing.T) (*gin.Engine, string) {
+	TestSetup(t)
+	t.Cleanup(func() { TestCleanup(t) })
+
+	gin.SetMode(gin.TestMode)
+	t.Setenv("SERVICE_BACKEND", services.ServiceBackendMemory)
+	require.NoError(t, services.GetServiceManager().InitializeServices())
+
+	user := models.User{Name: "Cart Test", Email: fmt.Sprintf("cart-%d@example.com", time.Now().UnixNano()), Password: "x"}
+	require.NoError(t, database.DB.Create(&user).Error)
+	t.Cleanup(func() { database.DB.Unscoped().Delete(&user) })
+
+	token, err := middlewares.GenerateJWT(user)
+	require.NoError(t, err)
+
+	router := gin.New()
+	routes.SetupRoutes(router)
+	return router, token
+}
+
+func createCartProduct(t *testing.T, name, category string, price float64) models.Product {
+	product := models.Product{Name: name, Category: category, Price: price, Stock: 50, StockLimit: 1}
+	require.NoError(t, database.DB.Create(&product).Error)
+	t.Cleanup(func() { database.DB.Unscoped().Delete(&product) })
+	return product
+}
+
+func postCartPreview(router *gin.Engine, token string, items []services.OrderItemRequest) *httptest.ResponseRecorder {
+	body, _ := json.Marshal(services.CartPreviewRequest{Items: items})
+	req, _ := http.NewRequest("POST", "/api/cart/preview", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestCartPreviewRoute(t *testing.T) {
+	router, token := setupCartRouter(t)
+	product := createCartProduct(t, "Caneca", "cozinha", 30)
+
+	w := postCartPreview(router, token, []services.OrderItemRequest{{ProductID: product.ID, Quantity: 2}})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var preview services.CartPreview
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &preview))
+	require.Len(t, preview.Items, 1)
+	assert.Equal(t, product.ID, preview.Items[0].ProductID)
+	assert.Equal(t, 60.0, preview.Subtotal)
+	assert.NotEmpty(t, preview.Installments)
+}
+
+func TestCartPreviewRouteRejectsEmptyCart(t *testing.T) {
+	router, token := setupCartRouter(t)
+
+	w := postCartPreview(router, token, []services.OrderItemRequest{})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestCartPreviewRouteShowsPromotionLines(t *testing.T) {
+	router, token := setupCartRouter(t)
+	shirt := createCartProduct(t, "Camiseta", "camisetas-preview", 50)
+
+	promotion := models.Promotion{
+		Name: "Leve 3 pague 2", Type: models.PromotionBuyXPayY, Active: true,
+		BuyQuantity: 3, PayQuantity: 2, ProductIDs: []uint{shirt.ID},
+	}
+	require.NoError(t, database.DB.Create(&promotion).Error)
+	t.Cleanup(func() { database.DB.Unscoped().Delete(&promotion) })
+
+	w := postCartPreview(router, token, []services.OrderItemRequest{{ProductID: shirt.ID, Quantity: 3}})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var preview services.CartPreview
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &preview))
+	assert.Equal(t, 150.0, preview.Subtotal)
+
+	// The cheapest of the three shirts is free, on the shirt's line.
+	var line *services.LineDiscount
+	for i := range preview.Promotions.Lines {
+		if preview.Promotions.Lines[i].PromotionID == promotion.ID {
+			line = &preview.Promotions.Lines[i]
+		}
+	}
+	require.NotNil(t, line)
+	assert.Equal(t, shirt.ID, line.ProductID)
+	assert.Equal(t, "Leve 3 pague 2", line.Promotion)
+	assert.Equal(t, 50.0, line.Amount)
+	assert.Equal(t, preview.Promotions.DiscountFor(shirt.ID), preview.Items[0].Discount)
+	assert.Equal(t, preview.Subtotal-preview.Discount, preview.Total)
+}
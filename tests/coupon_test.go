@@ -0,0 +1,93 @@
+package tests
+
+import (
+	"smart-choice/models"
+	"smart-choice/services"
+	"smart-choice/utils"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateCouponFields(t *testing.T) {
+	future := time.Now().Add(24 * time.Hour)
+
+	assert.Empty(t, utils.ValidateCoupon("BLACK_FRIDAY-10", "percentage", 10, future, 100, 50))
+
+	errors := utils.ValidateCoupon("ab", "percentage", 0, time.Now().Add(-time.Hour), 0, -1)
+	assert.Len(t, errors, 5)
+
+	assert.Len(t, utils.ValidateCoupon("PROMO", "percentage", 100.5, future, 1, 0), 1)
+	assert.Len(t, utils.ValidateCoupon("PROMO 10", "percentage", 10, future, 1, 0), 1)
+}
+
+func TestNormalizeCouponCode(t *testing.T) {
+	assert.Equal(t, "NATAL10", services.NormalizeCouponCode("  natal10 "))
+}
+
+func TestGenerateCouponBatchRejectsInvalidRequests(t *testing.T) {
+	future := time.Now().Add(24 * time.Hour)
+
+	_, err := services.GenerateCouponBatch(services.CouponBatchRequest{Count: 10, Discount: 10, ValidUntil: future})
+	assert.ErrorIs(t, err, services.ErrInvalidCouponBatch)
+
+	_, err = services.GenerateCouponBatch(services.CouponBatchRequest{Campaign: "natal", Count: 10001, Discount: 10, ValidUntil: future})
+	assert.ErrorIs(t, err, services.ErrInvalidCouponBatch)
+
+	_, err = services.GenerateCouponBatch(services.CouponBatchRequest{Campaign: "natal", Count: 10, Discount: 150, ValidUntil: future})
+	assert.ErrorIs(t, err, services.ErrInvalidCouponBatch)
+}
+
+func TestCouponDiscount(t *testing.T) {
+	coupon := &models.Coupon{Discount: 15}
+	assert.Equal(t, 29.99, services.CouponDiscount(coupon, 199.90))
+	assert.Equal(t, 0.0, services.CouponDiscount(coupon, 0))
+}
+
+func TestCouponDiscountTypes(t *testing.T) {
+	fixed := &models.Coupon{DiscountType: models.CouponTypeFixed, Discount: 50}
+	assert.Equal(t, 50.0, services.CouponDiscount(fixed, 120))
+	assert.Equal(t, 30.0, services.CouponDiscount(fixed, 30))
+
+	capped := &models.Coupon{DiscountType: models.CouponTypePercentage, Discount: 20, MaxDiscount: 100}
+	assert.Equal(t, 40.0, services.CouponDiscount(capped, 200))
+	assert.Equal(t, 100.0, services.CouponDiscount(capped, 1000))
+
+	freeShipping := &models.Coupon{DiscountType: models.CouponTypeFreeShipping}
+	assert.Equal(t, 0.0, services.CouponDiscount(freeShipping, 300))
+}
+
+func TestCouponEligibleAmount(t *testing.T) {
+	lines := []services.CouponLine{
+		{ProductID: 1, Category: "Eletrônicos", Amount: 1000},
+		{ProductID: 2, Category: "Livros", Amount: 80},
+		{ProductID: 3, Category: "livros", Amount: 45.5},
+	}
+
+	assert.Equal(t, 1125.5, services.CouponEligibleAmount(&models.Coupon{}, lines))
+	assert.Equal(t, 125.5, services.CouponEligibleAmount(&models.Coupon{IncludedCategories: []string{"Livros"}}, lines))
+	assert.Equal(t, 45.5, services.CouponEligibleAmount(&models.Coupon{
+		IncludedCategories: []string{"livros"},
+		ExcludedProducts:   []uint{2},
+	}, lines))
+	assert.Equal(t, 1080.0, services.CouponEligibleAmount(&models.Coupon{
+		IncludedProducts:   []uint{1},
+		IncludedCategories: []string{"Livros"},
+		ExcludedProducts:   []uint{3},
+	}, lines))
+	assert.Equal(t, 0.0, services.CouponEligibleAmount(&models.Coupon{ExcludedCategories: []string{"eletrônicos", "livros"}}, lines))
+}
+
+func TestValidateCouponDiscountTypeAndLimits(t *testing.T) {
+	future := time.Now().Add(24 * time.Hour)
+
+	assert.Empty(t, utils.ValidateCoupon("FRETEGRATIS", "free_shipping", 0, future, 10, 0))
+	assert.Empty(t, utils.ValidateCoupon("MENOS50", "fixed", 150, future, 10, 200))
+	assert.Len(t, utils.ValidateCoupon("MENOS50", "fixed", 0, future, 10, 0), 1)
+	assert.Len(t, utils.ValidateCoupon("MENOS50", "cashback", 10, future, 10, 0), 1)
+
+	starts := future.Add(time.Hour)
+	assert.Len(t, utils.ValidateCouponLimits(-1, &starts, future), 2)
+	assert.Empty(t, utils.ValidateCouponLimits(30, nil, future))
+}
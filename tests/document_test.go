@@ -0,0 +1,40 @@
+package tests
+
+import (
+	"encoding/json"
+	"smart-choice/models"
+	"smart-choice/utils"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateCPF(t *testing.T) {
+	assert.True(t, utils.ValidateCPF("529.982.247-25"))
+	assert.True(t, utils.ValidateCPF("52998224725"))
+	assert.False(t, utils.ValidateCPF("529.982.247-24"))
+	assert.False(t, utils.ValidateCPF("111.111.111-11"))
+	assert.False(t, utils.ValidateCPF("1234"))
+}
+
+func TestValidateCNPJ(t *testing.T) {
+	assert.True(t, utils.ValidateCNPJ("11.222.333/0001-81"))
+	assert.True(t, utils.ValidateCNPJ("11222333000181"))
+	assert.False(t, utils.ValidateCNPJ("11.222.333/0001-80"))
+	assert.False(t, utils.ValidateCNPJ("00.000.000/0000-00"))
+}
+
+func TestUserJSONMasksTaxDocument(t *testing.T) {
+	cpf := "52998224725"
+	user := models.User{Name: "Maria", Email: "maria@example.com", CPF: &cpf}
+
+	data, err := json.Marshal(user)
+	assert.NoError(t, err)
+
+	var body map[string]interface{}
+	assert.NoError(t, json.Unmarshal(data, &body))
+	assert.Equal(t, "***.982.247-**", body["cpf"])
+	assert.Equal(t, "maria@example.com", body["email"])
+	assert.NotContains(t, string(data), cpf)
+	assert.NotContains(t, body, "cnpj")
+}
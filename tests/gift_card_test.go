@@ -0,0 +1,42 @@
+package tests
+
+import (
+	"smart-choice/models"
+	"smart-choice/services"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGiftCardUsable(t *testing.T) {
+	now := time.Now()
+	past := now.Add(-time.Hour)
+	future := now.Add(time.Hour)
+
+	assert.True(t, (&models.GiftCard{Active: true}).Usable(now))
+	assert.True(t, (&models.GiftCard{Active: true, ExpiresAt: &future}).Usable(now))
+	assert.False(t, (&models.GiftCard{Active: true, ExpiresAt: &past}).Usable(now))
+	assert.False(t, (&models.GiftCard{Active: false}).Usable(now))
+}
+
+func TestIssueGiftCardValidation(t *testing.T) {
+	admin := &models.User{}
+	past := time.Now().Add(-time.Hour)
+
+	_, err := services.IssueGiftCard(admin, services.GiftCardRequest{Balance: 0.001})
+	assert.ErrorIs(t, err, services.ErrInvalidGiftCardBalance)
+
+	_, err = services.IssueGiftCard(admin, services.GiftCardRequest{Balance: 100, ExpiresAt: &past})
+	assert.ErrorIs(t, err, services.ErrInvalidGiftCardExpiry)
+}
+
+func TestAdjustStoreCreditValidation(t *testing.T) {
+	admin := &models.User{}
+
+	_, err := services.AdjustStoreCredit(admin, 1, services.StoreCreditAdjustment{Amount: 0.004, Reason: "goodwill"})
+	assert.ErrorIs(t, err, services.ErrStoreCreditAmountRequired)
+
+	_, err = services.AdjustStoreCredit(admin, 1, services.StoreCreditAdjustment{Amount: 50})
+	assert.ErrorIs(t, err, services.ErrStoreCreditReasonRequired)
+}
@@ -0,0 +1,130 @@
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"smart-choice/middlewares"
+	"smart-choice/services"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupIdempotencyRouter serves POST /orders behind IdempotencyMiddleware
+// with the in-memory cache. The X-Test-User header stands in for
+// AuthMiddleware, and handle decides each response.
+func setupIdempotencyRouter(t *testing.T, handle func(c *gin.Context, call int32)) (*gin.Engine, *int32) {
+	gin.SetMode(gin.TestMode)
+	t.Setenv("SERVICE_BACKEND", services.ServiceBackendMemory)
+	require.NoError(t, services.GetServiceManager().InitializeServices())
+
+	var calls int32
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		userID, _ := strconv.Atoi(c.GetHeader("X-Test-User"))
+		c.Set("user_id", uint(userID))
+		c.Next()
+	})
+	router.Use(middlewares.IdempotencyMiddleware())
+	router.POST("/orders", func(c *gin.Context) {
+		handle(c, atomic.AddInt32(&calls, 1))
+	})
+	return router, &calls
+}
+
+func postIdempotent(router *gin.Engine, user, key, body string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest("POST", "/orders", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Test-User", user)
+	req.Header.Set("Idempotency-Key", key)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestIdempotencyReplaysStoredResponse(t *testing.T) {
+	router, calls := setupIdempotencyRouter(t, func(c *gin.Context, call int32) {
+		c.JSON(http.StatusCreated, gin.H{"order": call})
+	})
+	key := t.Name()
+
+	first := postIdempotent(router, "1", key, `{"items":[1]}`)
+	require.Equal(t, http.StatusCreated, first.Code)
+
+	replay := postIdempotent(router, "1", key, `{"items":[1]}`)
+	assert.Equal(t, http.StatusCreated, replay.Code)
+	assert.Equal(t, first.Body.String(), replay.Body.String())
+	assert.Equal(t, "true", replay.Header().Get("Idempotent-Replayed"))
+	assert.Equal(t, int32(1), atomic.LoadInt32(calls))
+}
+
+func TestIdempotencyRejectsKeyReusedForDifferentBody(t *testing.T) {
+	router, calls := setupIdempotencyRouter(t, func(c *gin.Context, call int32) {
+		c.JSON(http.StatusCreated, gin.H{"order": call})
+	})
+	key := t.Name()
+
+	require.Equal(t, http.StatusCreated, postIdempotent(router, "1", key, `{"items":[1]}`).Code)
+
+	w := postIdempotent(router, "1", key, `{"items":[2]}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.Equal(t, int32(1), atomic.LoadInt32(calls))
+}
+
+func TestIdempotencyDoesNotStoreServerErrors(t *testing.T) {
+	router, calls := setupIdempotencyRouter(t, func(c *gin.Context, call int32) {
+		if call == 1 {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "boom"})
+			return
+		}
+		c.JSON(http.StatusCreated, gin.H{"order": call})
+	})
+	key := t.Name()
+
+	assert.Equal(t, http.StatusInternalServerError, postIdempotent(router, "1", key, `{}`).Code)
+
+	retry := postIdempotent(router, "1", key, `{}`)
+	assert.Equal(t, http.StatusCreated, retry.Code)
+	assert.Empty(t, retry.Header().Get("Idempotent-Replayed"))
+	assert.Equal(t, int32(2), atomic.LoadInt32(calls))
+}
+
+func TestIdempotencyKeysAreScopedPerUser(t *testing.T) {
+	router, calls := setupIdempotencyRouter(t, func(c *gin.Context, call int32) {
+		c.JSON(http.StatusCreated, gin.H{"order": call})
+	})
+	key := t.Name()
+
+	first := postIdempotent(router, "1", key, `{}`)
+	other := postIdempotent(router, "2", key, `{}`)
+
+	assert.Equal(t, http.StatusCreated, other.Code)
+	assert.NotEqual(t, first.Body.String(), other.Body.String())
+	assert.Empty(t, other.Header().Get("Idempotent-Replayed"))
+	assert.Equal(t, int32(2), atomic.LoadInt32(calls))
+}
+
+func TestIdempotencyRejectsRetryWhileInFlight(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	router, calls := setupIdempotencyRouter(t, func(c *gin.Context, call int32) {
+		close(started)
+		<-release
+		c.JSON(http.StatusCreated, gin.H{"order": call})
+	})
+	key := t.Name()
+
+	done := make(chan *httptest.ResponseRecorder)
+	go func() { done <- postIdempotent(router, "1", key, `{}`) }()
+	<-started
+
+	assert.Equal(t, http.StatusConflict, postIdempotent(router, "1", key, `{}`).Code)
+
+	close(release)
+	assert.Equal(t, http.StatusCreated, (<-done).Code)
+	assert.Equal(t, int32(1), atomic.LoadInt32(calls))
+}
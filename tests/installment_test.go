@@ -0,0 +1,84 @@
+package tests
+
+import (
+	"smart-choice/services"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var testInstallmentRules = services.InstallmentRules{
+	MaxInstallments:          12,
+	MinInstallmentValue:      5,
+	InterestFreeInstallments: 3,
+	MonthlyInterestRate:      0.0199,
+}
+
+func TestCalculateInstallments(t *testing.T) {
+	options := services.CalculateInstallments(1000, testInstallmentRules)
+	assert.Len(t, options, 12)
+
+	assert.Equal(t, 1000.0, options[0].Amount)
+	assert.True(t, options[0].InterestFree)
+
+	assert.Equal(t, 333.33, options[2].Amount)
+	assert.Equal(t, 333.34, options[2].FirstAmount)
+	assert.Equal(t, 1000.0, options[2].Total)
+	assert.True(t, options[2].InterestFree)
+
+	// 4x on the Price table at 1.99% a month
+	assert.Equal(t, 4, options[3].Count)
+	assert.Equal(t, 262.56, options[3].Amount)
+	assert.Equal(t, 1050.24, options[3].Total)
+	assert.False(t, options[3].InterestFree)
+}
+
+func TestInstallmentsAddUpToTotal(t *testing.T) {
+	for _, total := range []float64{100, 99.90, 1000, 15, 1234.57} {
+		for _, option := range services.CalculateInstallments(total, testInstallmentRules) {
+			sum := option.FirstAmount + option.Amount*float64(option.Count-1)
+			assert.InDelta(t, option.Total, sum, 0.001, "%.2f in %dx", total, option.Count)
+		}
+	}
+
+	option, err := services.FindInstallmentOption(100, 3, testInstallmentRules)
+	assert.NoError(t, err)
+	assert.Equal(t, 33.34, option.FirstAmount)
+	assert.Equal(t, 33.33, option.Amount)
+}
+
+func TestCalculateInstallmentsRespectsMinimumValue(t *testing.T) {
+	options := services.CalculateInstallments(15, testInstallmentRules)
+	assert.Len(t, options, 3)
+	assert.Equal(t, 5.0, options[2].Amount)
+
+	options = services.CalculateInstallments(3, testInstallmentRules)
+	assert.Len(t, options, 1)
+}
+
+func TestFindInstallmentOption(t *testing.T) {
+	option, err := services.FindInstallmentOption(120, 3, testInstallmentRules)
+	assert.NoError(t, err)
+	assert.Equal(t, "3x de R$ 40,00 sem juros", option.Label())
+
+	_, err = services.FindInstallmentOption(120, 24, testInstallmentRules)
+	assert.ErrorIs(t, err, services.ErrInstallmentsUnavailable)
+}
+
+func TestBestInstallmentOffer(t *testing.T) {
+	offer, ok := services.BestInstallmentOffer(99.90, services.InstallmentRules{
+		MaxInstallments:          12,
+		MinInstallmentValue:      5,
+		InterestFreeInstallments: 12,
+	})
+	assert.True(t, ok)
+	assert.Equal(t, "12x de R$ 8,33 sem juros", offer.Label())
+
+	_, ok = services.BestInstallmentOffer(4, testInstallmentRules)
+	assert.False(t, ok)
+}
+
+func TestFormatBRL(t *testing.T) {
+	assert.Equal(t, "R$ 1.234.567,89", services.FormatBRL(1234567.891))
+	assert.Equal(t, "R$ 0,50", services.FormatBRL(0.5))
+}
@@ -20,10 +20,11 @@ func TestIntegrationAuthFlow(t *testing.T) {
 
 	gin.SetMode(gin.TestMode)
 
-	// Initialize services
+	// Initialize in-process services so the test does not need Redis
+	t.Setenv("SERVICE_BACKEND", services.ServiceBackendMemory)
 	serviceManager := services.GetServiceManager()
 	if err := serviceManager.InitializeServices(); err != nil {
-		t.Skip("Skipping integration test - Redis not available")
+		t.Fatalf("Failed to initialize services: %v", err)
 	}
 
 	// Setup router
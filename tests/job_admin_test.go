@@ -0,0 +1,33 @@
+package tests
+
+import (
+	"context"
+	"smart-choice/services"
+	"smart-choice/utils"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJobPayloadPreviewIsTruncated(t *testing.T) {
+	assert.Equal(t, `{"to":"a@b.com"}`, services.JobPayloadPreview([]byte(`{"to":"a@b.com"}`)))
+
+	long := strings.Repeat("é", 150) // 300 bytes
+	preview := services.JobPayloadPreview([]byte(long))
+	assert.True(t, strings.HasSuffix(preview, "…"))
+	assert.Equal(t, strings.Repeat("é", 100)+"…", preview)
+}
+
+func TestJobAdminRejectsInvalidInput(t *testing.T) {
+	ctx := context.Background()
+	pagination := utils.Pagination{}
+
+	_, err := services.ListJobs(ctx, "finished", &pagination)
+	assert.ErrorIs(t, err, services.ErrInvalidJobState)
+
+	assert.ErrorIs(t, services.PauseJobType(ctx, "bitcoin_mining"), services.ErrUnknownJobType)
+
+	_, err = services.RetryDeadJobs(ctx, "bitcoin_mining")
+	assert.ErrorIs(t, err, services.ErrUnknownJobType)
+}
@@ -0,0 +1,19 @@
+package tests
+
+import (
+	"smart-choice/services"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetryBackoffDoublesUpToMax(t *testing.T) {
+	base, max := 10*time.Second, time.Minute
+
+	assert.Equal(t, 10*time.Second, services.RetryBackoff(1, base, max))
+	assert.Equal(t, 20*time.Second, services.RetryBackoff(2, base, max))
+	assert.Equal(t, 40*time.Second, services.RetryBackoff(3, base, max))
+	assert.Equal(t, time.Minute, services.RetryBackoff(4, base, max))
+	assert.Equal(t, time.Minute, services.RetryBackoff(30, base, max))
+}
@@ -0,0 +1,154 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"smart-choice/services"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeMultiJobQueue keeps one slice per named queue and records the queue
+// of every job handed out.
+type fakeMultiJobQueue struct {
+	*fakeJobQueue
+	specs []services.JobQueueSpec
+
+	mu    sync.Mutex
+	ready map[string][]services.Job
+	taken []string
+}
+
+func newFakeMultiJobQueue(specs []services.JobQueueSpec) *fakeMultiJobQueue {
+	return &fakeMultiJobQueue{fakeJobQueue: newFakeJobQueue(), specs: specs, ready: make(map[string][]services.Job)}
+}
+
+func (q *fakeMultiJobQueue) Enqueue(ctx context.Context, job services.Job) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.ready[job.Queue] = append(q.ready[job.Queue], job)
+	return nil
+}
+
+func (q *fakeMultiJobQueue) Queues() []services.JobQueueSpec {
+	return q.specs
+}
+
+func (q *fakeMultiJobQueue) DequeueFrom(ctx context.Context, queue string) (*services.Job, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.ready[queue]) == 0 {
+		return nil, nil
+	}
+	job := q.ready[queue][0]
+	q.ready[queue] = q.ready[queue][1:]
+	q.taken = append(q.taken, queue)
+	return &job, nil
+}
+
+func (q *fakeMultiJobQueue) takenFrom() []string {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return append([]string(nil), q.taken...)
+}
+
+func TestNewJobRoutingParsesQueuesAndRoutes(t *testing.T) {
+	routing, err := services.NewJobRouting("bulk:1:2, critical:5,default:3", "email_send:critical,report_generate:bulk")
+	require.NoError(t, err)
+
+	assert.Equal(t, []services.JobQueueSpec{
+		{Name: "critical", Weight: 5},
+		{Name: "default", Weight: 3},
+		{Name: "bulk", Weight: 1, Concurrency: 2},
+	}, routing.Queues)
+	assert.Equal(t, "critical", routing.QueueFor(services.JobTypeEmailSend))
+	assert.Equal(t, "bulk", routing.QueueFor(services.JobTypeReportGenerate))
+	assert.Equal(t, services.DefaultJobQueueName, routing.QueueFor(services.JobTypeDataExport))
+}
+
+func TestJobRoutingFallsBackToFirstQueue(t *testing.T) {
+	routing, err := services.NewJobRouting("fast:2,slow:1", "")
+	require.NoError(t, err)
+
+	assert.Equal(t, "fast", routing.QueueFor(services.JobTypeDataCleanup))
+}
+
+func TestNewJobRoutingRejectsBadConfiguration(t *testing.T) {
+	cases := map[string][2]string{
+		"no queues":         {"", ""},
+		"missing weight":    {"default", ""},
+		"zero weight":       {"default:0", ""},
+		"bad concurrency":   {"default:1:-1", ""},
+		"duplicate queue":   {"default:1,default:2", ""},
+		"unknown queue":     {"default:1", "email_send:critical"},
+		"malformed route":   {"default:1", "email_send"},
+		"too many segments": {"default:1:1:1", ""},
+	}
+
+	for name, spec := range cases {
+		t.Run(name, func(t *testing.T) {
+			_, err := services.NewJobRouting(spec[0], spec[1])
+			assert.True(t, errors.Is(err, services.ErrInvalidJobRouting))
+		})
+	}
+}
+
+func TestWorkerPoolSharesQueuesByWeight(t *testing.T) {
+	queue := newFakeMultiJobQueue([]services.JobQueueSpec{
+		{Name: "critical", Weight: 3},
+		{Name: "bulk", Weight: 1},
+	})
+	for i := 0; i < 20; i++ {
+		queue.Enqueue(context.Background(), services.Job{Type: services.JobTypeEmailSend, Queue: "critical"})
+		queue.Enqueue(context.Background(), services.Job{Type: services.JobTypeEmailSend, Queue: "bulk"})
+	}
+
+	registry := services.NewJobRegistry()
+	registry.Register(services.JobTypeEmailSend, func(ctx context.Context, job *services.Job) error { return nil })
+	pool := services.NewWorkerPool(queue, registry, services.WorkerPoolConfig{Concurrency: 1, PollInterval: 10 * time.Millisecond})
+	pool.Start(context.Background())
+
+	require.Eventually(t, func() bool { return len(queue.takenFrom()) >= 8 }, 2*time.Second, 10*time.Millisecond)
+	require.NoError(t, pool.Stop(context.Background()))
+
+	counts := map[string]int{}
+	for _, name := range queue.takenFrom()[:8] {
+		counts[name]++
+	}
+	assert.Equal(t, map[string]int{"critical": 6, "bulk": 2}, counts)
+}
+
+func TestWorkerPoolRespectsQueueConcurrency(t *testing.T) {
+	queue := newFakeMultiJobQueue([]services.JobQueueSpec{{Name: "bulk", Weight: 1, Concurrency: 1}})
+	for i := 0; i < 4; i++ {
+		queue.Enqueue(context.Background(), services.Job{Type: services.JobTypeReportGenerate, Queue: "bulk"})
+	}
+
+	var running, peak, done int32
+	registry := services.NewJobRegistry()
+	registry.Register(services.JobTypeReportGenerate, func(ctx context.Context, job *services.Job) error {
+		now := atomic.AddInt32(&running, 1)
+		for {
+			old := atomic.LoadInt32(&peak)
+			if now <= old || atomic.CompareAndSwapInt32(&peak, old, now) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		atomic.AddInt32(&running, -1)
+		atomic.AddInt32(&done, 1)
+		return nil
+	})
+
+	pool := services.NewWorkerPool(queue, registry, services.WorkerPoolConfig{Concurrency: 4, PollInterval: 5 * time.Millisecond})
+	pool.Start(context.Background())
+
+	require.Eventually(t, func() bool { return atomic.LoadInt32(&done) == 4 }, 2*time.Second, 10*time.Millisecond)
+	require.NoError(t, pool.Stop(context.Background()))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&peak))
+}
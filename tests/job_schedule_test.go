@@ -0,0 +1,122 @@
+package tests
+
+import (
+	"context"
+	"smart-choice/services"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCronScheduleNext(t *testing.T) {
+	from := time.Date(2024, 3, 10, 14, 37, 12, 0, time.UTC) // a Sunday
+
+	cases := []struct {
+		spec string
+		want time.Time
+	}{
+		{"@hourly", time.Date(2024, 3, 10, 15, 0, 0, 0, time.UTC)},
+		{"0 3 * * *", time.Date(2024, 3, 11, 3, 0, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2024, 3, 10, 14, 45, 0, 0, time.UTC)},
+		{"30 9 * * 1-5", time.Date(2024, 3, 11, 9, 30, 0, 0, time.UTC)},
+		{"0 0 1 * *", time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 12 29 2 *", time.Date(2028, 2, 29, 12, 0, 0, 0, time.UTC)},
+		{"0 8 * * 7", time.Date(2024, 3, 17, 8, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tc := range cases {
+		schedule, err := services.ParseCronSchedule(tc.spec)
+		require.NoError(t, err, tc.spec)
+		assert.Equal(t, tc.want, schedule.Next(from), tc.spec)
+	}
+}
+
+func TestCronScheduleDayFieldsMatchEither(t *testing.T) {
+	// Day 15 or any Monday.
+	schedule, err := services.ParseCronSchedule("0 0 15 * 1")
+	require.NoError(t, err)
+
+	next := schedule.Next(time.Date(2024, 3, 12, 0, 0, 0, 0, time.UTC))
+	assert.Equal(t, time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC), next)
+
+	next = schedule.Next(next)
+	assert.Equal(t, time.Date(2024, 3, 18, 0, 0, 0, 0, time.UTC), next)
+}
+
+func TestParseCronScheduleRejectsInvalidSpecs(t *testing.T) {
+	for _, spec := range []string{"", "* * * *", "60 * * * *", "0 24 * * *", "*/0 * * * *", "5-1 * * * *", "a * * * *"} {
+		_, err := services.ParseCronSchedule(spec)
+		assert.ErrorIs(t, err, services.ErrInvalidCronSpec, spec)
+	}
+}
+
+func TestCronSchedulerFiresEachOccurrenceOnce(t *testing.T) {
+	ctx := context.Background()
+	queue := newFakeJobQueue()
+	locks := services.NewMemoryCache()
+
+	// Two instances sharing the same lock store.
+	first := services.NewCronScheduler(queue, locks)
+	second := services.NewCronScheduler(queue, locks)
+	require.NoError(t, first.Add("low_stock_report", "@hourly", services.Job{Type: services.JobTypeReportGenerate}))
+	require.NoError(t, second.Add("low_stock_report", "@hourly", services.Job{Type: services.JobTypeReportGenerate}))
+
+	at := time.Date(2024, 3, 10, 15, 0, 0, 0, time.UTC)
+	fired, err := first.Fire(ctx, first.Schedules()[0], at)
+	require.NoError(t, err)
+	assert.True(t, fired)
+
+	fired, err = second.Fire(ctx, second.Schedules()[0], at)
+	require.NoError(t, err)
+	assert.False(t, fired)
+
+	fired, err = second.Fire(ctx, second.Schedules()[0], at.Add(time.Hour))
+	require.NoError(t, err)
+	assert.True(t, fired)
+
+	assert.Len(t, queue.jobs, 2)
+}
+
+func TestSchedulerRunsEachIntervalOnce(t *testing.T) {
+	ctx := context.Background()
+	locks := services.NewMemoryCache()
+	runs := 0
+	task := services.ScheduledTask{Name: "pix_expiry", Interval: time.Minute, Run: func(ctx context.Context) error {
+		runs++
+		return nil
+	}}
+
+	// Two instances sharing the same lock store, ticking a few seconds apart.
+	first := services.NewScheduler(locks)
+	second := services.NewScheduler(locks)
+
+	at := time.Date(2024, 3, 10, 15, 0, 10, 0, time.UTC)
+	ran, err := first.RunOnce(ctx, task, at)
+	require.NoError(t, err)
+	assert.True(t, ran)
+
+	ran, err = second.RunOnce(ctx, task, at.Add(3*time.Second))
+	require.NoError(t, err)
+	assert.False(t, ran)
+
+	ran, err = second.RunOnce(ctx, task, at.Add(time.Minute))
+	require.NoError(t, err)
+	assert.True(t, ran)
+
+	assert.Equal(t, 2, runs)
+}
+
+func TestDefaultCronSchedulesCanBeDisabled(t *testing.T) {
+	t.Setenv("JOB_SCHEDULE_DATA_CLEANUP", "off")
+
+	scheduler, err := services.NewDefaultCronScheduler(newFakeJobQueue(), nil)
+	require.NoError(t, err)
+	require.Len(t, scheduler.Schedules(), 1)
+	assert.Equal(t, "low_stock_report", scheduler.Schedules()[0].Name)
+
+	t.Setenv("JOB_SCHEDULE_LOW_STOCK_REPORT", "every hour")
+	_, err = services.NewDefaultCronScheduler(newFakeJobQueue(), nil)
+	assert.Error(t, err)
+}
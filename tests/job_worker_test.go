@@ -0,0 +1,213 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"smart-choice/services"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeJobQueue is a channel-backed JobQueue recording job outcomes.
+type fakeJobQueue struct {
+	jobs chan services.Job
+
+	mu        sync.Mutex
+	completed []string
+	failed    map[string]string
+}
+
+func newFakeJobQueue() *fakeJobQueue {
+	return &fakeJobQueue{jobs: make(chan services.Job, 16), failed: make(map[string]string)}
+}
+
+func (q *fakeJobQueue) Enqueue(ctx context.Context, job services.Job) error {
+	q.jobs <- job
+	return nil
+}
+
+func (q *fakeJobQueue) Dequeue(ctx context.Context) (*services.Job, error) {
+	select {
+	case job := <-q.jobs:
+		return &job, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-time.After(50 * time.Millisecond):
+		return nil, nil
+	}
+}
+
+func (q *fakeJobQueue) Complete(ctx context.Context, jobID string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.completed = append(q.completed, jobID)
+	return nil
+}
+
+func (q *fakeJobQueue) Fail(ctx context.Context, jobID string, reason string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.failed[jobID] = reason
+	return nil
+}
+
+func (q *fakeJobQueue) GetStats(ctx context.Context) (map[string]int, error) {
+	return map[string]int{"queue_length": len(q.jobs)}, nil
+}
+
+func (q *fakeJobQueue) outcome(jobID string) (bool, string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for _, id := range q.completed {
+		if id == jobID {
+			return true, ""
+		}
+	}
+	return false, q.failed[jobID]
+}
+
+func testWorkerPool(queue services.JobQueue, registry *services.JobRegistry) *services.WorkerPool {
+	return services.NewWorkerPool(queue, registry, services.WorkerPoolConfig{Concurrency: 2, JobTimeout: time.Second})
+}
+
+func TestWorkerPoolRunsRegisteredHandler(t *testing.T) {
+	queue := newFakeJobQueue()
+	registry := services.NewJobRegistry()
+	handled := make(chan string, 1)
+	registry.Register(services.JobTypeEmailSend, func(ctx context.Context, job *services.Job) error {
+		handled <- string(job.Payload)
+		return nil
+	})
+
+	pool := testWorkerPool(queue, registry)
+	pool.Start(context.Background())
+	queue.Enqueue(context.Background(), services.Job{ID: "job-1", Type: services.JobTypeEmailSend, Payload: []byte("hello")})
+
+	select {
+	case payload := <-handled:
+		assert.Equal(t, "hello", payload)
+	case <-time.After(2 * time.Second):
+		t.Fatal("job was not handled")
+	}
+	require.NoError(t, pool.Stop(context.Background()))
+
+	completed, _ := queue.outcome("job-1")
+	assert.True(t, completed)
+}
+
+func TestWorkerPoolFailsUnknownJobType(t *testing.T) {
+	queue := newFakeJobQueue()
+	pool := testWorkerPool(queue, services.NewJobRegistry())
+
+	err := pool.Process(&services.Job{ID: "job-2", Type: services.JobTypeDataCleanup})
+
+	assert.True(t, errors.Is(err, services.ErrNoJobHandler))
+	_, reason := queue.outcome("job-2")
+	assert.Contains(t, reason, "no handler")
+}
+
+func TestWorkerPoolRecoversFromPanic(t *testing.T) {
+	queue := newFakeJobQueue()
+	registry := services.NewJobRegistry()
+	registry.Register(services.JobTypeReportGenerate, func(ctx context.Context, job *services.Job) error {
+		panic("boom")
+	})
+	pool := testWorkerPool(queue, registry)
+
+	err := pool.Process(&services.Job{ID: "job-3", Type: services.JobTypeReportGenerate})
+
+	assert.Error(t, err)
+	_, reason := queue.outcome("job-3")
+	assert.Contains(t, reason, "boom")
+}
+
+func TestWorkerPoolAppliesJobTimeout(t *testing.T) {
+	queue := newFakeJobQueue()
+	registry := services.NewJobRegistry()
+	registry.Register(services.JobTypeReportGenerate, func(ctx context.Context, job *services.Job) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	pool := services.NewWorkerPool(queue, registry, services.WorkerPoolConfig{Concurrency: 1, JobTimeout: 20 * time.Millisecond})
+
+	err := pool.Process(&services.Job{ID: "job-4", Type: services.JobTypeReportGenerate})
+
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+}
+
+func TestWorkerPoolStopWaitsForInFlightJobs(t *testing.T) {
+	queue := newFakeJobQueue()
+	registry := services.NewJobRegistry()
+	started := make(chan struct{})
+	registry.Register(services.JobTypeDataCleanup, func(ctx context.Context, job *services.Job) error {
+		close(started)
+		time.Sleep(100 * time.Millisecond)
+		return ctx.Err()
+	})
+
+	pool := testWorkerPool(queue, registry)
+	pool.Start(context.Background())
+	queue.Enqueue(context.Background(), services.Job{ID: "job-5", Type: services.JobTypeDataCleanup})
+	<-started
+
+	require.NoError(t, pool.Stop(context.Background()))
+
+	completed, _ := queue.outcome("job-5")
+	assert.True(t, completed)
+}
+
+func TestWorkerPoolRequeuesJobsAbortedByStop(t *testing.T) {
+	queue := services.NewMemoryJobQueueWithConfig(services.JobQueueConfig{MaxAttempts: 1, VisibilityTimeout: time.Minute})
+	registry := services.NewJobRegistry()
+	started := make(chan struct{})
+	registry.Register(services.JobTypeDataCleanup, func(ctx context.Context, job *services.Job) error {
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	pool := testWorkerPool(queue, registry)
+	pool.Start(context.Background())
+	require.NoError(t, queue.Enqueue(context.Background(), services.Job{Type: services.JobTypeDataCleanup}))
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, pool.Stop(ctx), context.DeadlineExceeded)
+
+	// Process reports the outcome after Stop returns.
+	require.Eventually(t, func() bool {
+		stats, _ := queue.GetStats(context.Background())
+		return stats["queue_length"] == 1
+	}, time.Second, 5*time.Millisecond)
+
+	stats, err := queue.GetStats(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 0, stats["dead_letter_count"])
+
+	job, err := queue.Dequeue(context.Background())
+	require.NoError(t, err)
+	require.NotNil(t, job)
+	assert.Equal(t, 1, job.Attempts)
+}
+
+func TestDefaultJobRegistryCoversAllJobTypes(t *testing.T) {
+	registry := services.NewDefaultJobRegistry()
+
+	for _, jobType := range []services.JobType{
+		services.JobTypeEmailSend, services.JobTypeReportGenerate, services.JobTypeDataCleanup,
+		services.JobTypeDataExport, services.JobTypeReconciliation,
+	} {
+		_, ok := registry.Handler(jobType)
+		assert.True(t, ok, string(jobType))
+	}
+}
+
+func TestEmailJobRequiresRecipient(t *testing.T) {
+	err := services.HandleEmailSendJob(context.Background(), &services.Job{Payload: []byte(`{"subject":"Oi"}`)})
+	assert.ErrorIs(t, err, services.ErrInvalidEmailJob)
+}
@@ -0,0 +1,91 @@
+package tests
+
+import (
+	"smart-choice/models"
+	"smart-choice/services"
+	"smart-choice/utils"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testLoyaltySettings() services.LoyaltySettings {
+	return services.LoyaltySettings{PointsPerReal: 1, PointValue: 0.05, MaxRedeemRatio: 0.5, Validity: 365 * 24 * time.Hour}
+}
+
+func TestLoyaltyPointsBaseRate(t *testing.T) {
+	lines := []services.LoyaltyLine{{Category: "Livros", Amount: 120.75}, {Amount: 30}}
+
+	points := services.CalculateLoyaltyPoints(lines, nil, testLoyaltySettings(), time.Now())
+
+	assert.Equal(t, 150, points)
+}
+
+func TestLoyaltyPointsCategoryBonusAndCampaign(t *testing.T) {
+	now := time.Now()
+	past := now.Add(-time.Hour)
+	future := now.Add(time.Hour)
+	rules := []models.LoyaltyRule{
+		{Type: models.LoyaltyRuleCategoryBonus, Category: "eletronicos", BonusPerReal: 2, Active: true},
+		{Type: models.LoyaltyRuleCampaign, Multiplier: 2, Active: true, StartsAt: &past, EndsAt: &future},
+		{Type: models.LoyaltyRuleCampaign, Multiplier: 5, Active: false},
+	}
+	lines := []services.LoyaltyLine{{Category: "Eletronicos", Amount: 100}, {Category: "Livros", Amount: 50}}
+
+	points := services.CalculateLoyaltyPoints(lines, rules, testLoyaltySettings(), now)
+
+	// (100*3 + 50*1) doubled by the running campaign.
+	assert.Equal(t, 700, points)
+}
+
+func TestLoyaltyRedemptionIsCappedByTotal(t *testing.T) {
+	settings := testLoyaltySettings()
+
+	used, discount := services.LoyaltyRedemption(1000, 100, settings)
+	assert.Equal(t, 1000, used)
+	assert.Equal(t, 50.0, discount)
+
+	used, discount = services.LoyaltyRedemption(5000, 100, settings)
+	assert.Equal(t, 1000, used)
+	assert.Equal(t, 50.0, discount)
+
+	used, discount = services.LoyaltyRedemption(0, 100, settings)
+	assert.Equal(t, 0, used)
+	assert.Equal(t, 0.0, discount)
+}
+
+func TestLoyaltyClawbackFollowsRefundShare(t *testing.T) {
+	// A quarter of a R$200 order refunded takes back a quarter of its points.
+	assert.Equal(t, 50, services.LoyaltyClawback(200, 0, 50, 200))
+
+	// The rest of the order is then refunded: everything left comes back.
+	assert.Equal(t, 150, services.LoyaltyClawback(200, 50, 150, 150))
+
+	assert.Equal(t, 0, services.LoyaltyClawback(200, 200, 10, 10))
+	assert.Equal(t, 0, services.LoyaltyClawback(0, 0, 50, 200))
+}
+
+func TestLoyaltyRuleActiveWindow(t *testing.T) {
+	now := time.Now()
+	later := now.Add(24 * time.Hour)
+	rule := models.LoyaltyRule{Active: true, StartsAt: &later}
+
+	assert.False(t, rule.ActiveAt(now))
+	assert.True(t, rule.ActiveAt(later.Add(time.Minute)))
+
+	rule.Active = false
+	assert.False(t, rule.ActiveAt(later.Add(time.Minute)))
+}
+
+func TestValidateLoyaltyRule(t *testing.T) {
+	now := time.Now()
+	before := now.Add(-time.Hour)
+
+	assert.Empty(t, utils.ValidateLoyaltyRule("Bônus eletrônicos", "category_bonus", "eletronicos", 2, 0, nil, nil))
+	assert.Empty(t, utils.ValidateLoyaltyRule("Black Friday", "campaign_multiplier", "", 0, 3, &before, &now))
+	assert.NotEmpty(t, utils.ValidateLoyaltyRule("Sem categoria", "category_bonus", "", 2, 0, nil, nil))
+	assert.NotEmpty(t, utils.ValidateLoyaltyRule("Multiplicador", "campaign_multiplier", "", 0, 1, nil, nil))
+	assert.NotEmpty(t, utils.ValidateLoyaltyRule("Janela", "campaign_multiplier", "", 0, 2, &now, &before))
+	assert.NotEmpty(t, utils.ValidateLoyaltyRule("Tipo", "cashback", "", 0, 0, nil, nil))
+}
@@ -0,0 +1,17 @@
+package tests
+
+import (
+	"smart-choice/models"
+	"smart-choice/services"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClassifyPaymentDiscrepancy(t *testing.T) {
+	assert.Equal(t, "", services.ClassifyPaymentDiscrepancy(100, 100, "BRL", "BRL"))
+	assert.Equal(t, "", services.ClassifyPaymentDiscrepancy(100, 100.001, "BRL", ""))
+	assert.Equal(t, models.PaymentReviewUnderpayment, services.ClassifyPaymentDiscrepancy(100, 99.99, "BRL", "BRL"))
+	assert.Equal(t, models.PaymentReviewOverpayment, services.ClassifyPaymentDiscrepancy(100, 150, "BRL", "BRL"))
+	assert.Equal(t, models.PaymentReviewCurrencyMismatch, services.ClassifyPaymentDiscrepancy(100, 100, "BRL", "USD"))
+}
@@ -0,0 +1,78 @@
+package tests
+
+import (
+	"context"
+	"smart-choice/models"
+	"smart-choice/services"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSimulatorProvider(t *testing.T) {
+	ctx := context.Background()
+	req := services.PaymentIntentRequest{OrderID: 1, Amount: 100, Currency: "BRL", Method: models.PaymentMethodCard}
+
+	t.Run("Succeed", func(t *testing.T) {
+		provider := services.NewSimulatorProvider(services.SimulatorModeSucceed)
+
+		intent, err := provider.CreateIntent(ctx, req)
+		assert.NoError(t, err)
+		assert.Equal(t, models.PaymentStatusAuthorized, intent.Status)
+
+		captured, err := provider.Capture(ctx, intent.Reference, 100)
+		assert.NoError(t, err)
+		assert.Equal(t, models.PaymentStatusSucceeded, captured.Status)
+
+		_, err = provider.Refund(ctx, intent.Reference, 40)
+		assert.NoError(t, err)
+		_, err = provider.Refund(ctx, intent.Reference, 70)
+		assert.ErrorIs(t, err, services.ErrInvalidRefundAmount)
+
+		_, err = provider.Refund(ctx, intent.Reference, 60)
+		assert.NoError(t, err)
+		status, _ := provider.FetchStatus(ctx, intent.Reference)
+		assert.Equal(t, models.PaymentStatusRefunded, status.Status)
+	})
+
+	t.Run("Void", func(t *testing.T) {
+		provider := services.NewSimulatorProvider(services.SimulatorModeSucceed)
+
+		intent, err := provider.CreateIntent(ctx, req)
+		assert.NoError(t, err)
+
+		voided, err := provider.Void(ctx, intent.Reference)
+		assert.NoError(t, err)
+		assert.Equal(t, models.PaymentStatusCanceled, voided.Status)
+
+		_, err = provider.Capture(ctx, intent.Reference, 100)
+		assert.Error(t, err)
+	})
+
+	t.Run("Fail", func(t *testing.T) {
+		provider := services.NewSimulatorProvider(services.SimulatorModeFail)
+
+		intent, err := provider.CreateIntent(ctx, req)
+		assert.NoError(t, err)
+		assert.Equal(t, models.PaymentStatusFailed, intent.Status)
+		assert.NotEmpty(t, intent.FailureReason)
+	})
+
+	t.Run("Async", func(t *testing.T) {
+		provider := services.NewSimulatorProvider(services.SimulatorModeAsync)
+
+		intent, err := provider.CreateIntent(ctx, req)
+		assert.NoError(t, err)
+		assert.Equal(t, models.PaymentStatusPending, intent.Status)
+
+		assert.NoError(t, provider.ResolveAsync(intent.Reference, true))
+		status, err := provider.FetchStatus(ctx, intent.Reference)
+		assert.NoError(t, err)
+		assert.Equal(t, models.PaymentStatusSucceeded, status.Status)
+	})
+}
+
+func TestPaymentProviderForMethodRejectsUnknownMethods(t *testing.T) {
+	_, err := services.GetPaymentProviderForMethod("crypto")
+	assert.ErrorIs(t, err, services.ErrUnsupportedPaymentMethod)
+}
@@ -0,0 +1,74 @@
+package tests
+
+import (
+	"smart-choice/services"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCRC16CCITT(t *testing.T) {
+	// Standard check value of CRC-16/CCITT-FALSE
+	assert.Equal(t, "29B1", services.CRC16CCITT("123456789"))
+}
+
+func TestPixBRCodeStatic(t *testing.T) {
+	code := services.PixBRCode{
+		Key:          "123e4567-e12b-12d1-a456-426655440000",
+		Amount:       10.5,
+		MerchantName: "SMART CHOICE",
+		MerchantCity: "SAO PAULO",
+		TxID:         "PEDIDO42",
+	}
+
+	payload := code.Payload()
+
+	assert.True(t, strings.HasPrefix(payload, "000201010211"))
+	assert.Contains(t, payload, "0014br.gov.bcb.pix0136123e4567-e12b-12d1-a456-426655440000")
+	assert.Contains(t, payload, "540510.50")
+	assert.Contains(t, payload, "5303986")
+	assert.Contains(t, payload, "5802BR")
+	assert.Contains(t, payload, "62120508PEDIDO42")
+
+	body, crc := payload[:len(payload)-4], payload[len(payload)-4:]
+	assert.True(t, strings.HasSuffix(body, "6304"))
+	assert.Equal(t, services.CRC16CCITT(body), crc)
+}
+
+func TestPixBRCodeDynamic(t *testing.T) {
+	code := services.PixBRCode{
+		LocationURL:  "https://pix.example.com/qr/v2/abc",
+		MerchantName: "SMART CHOICE",
+		MerchantCity: "SAO PAULO",
+		TxID:         "IGNORED",
+	}
+
+	payload := code.Payload()
+
+	assert.True(t, code.IsDynamic())
+	assert.Contains(t, payload, "010212")
+	assert.Contains(t, payload, "2525pix.example.com/qr/v2/abc")
+	assert.Contains(t, payload, "62070503***")
+	assert.NotContains(t, payload, "IGNORED")
+}
+
+func TestPixBRCodeMerchantFieldsAreASCII(t *testing.T) {
+	code := services.PixBRCode{
+		Key:          "loja@example.com",
+		MerchantName: "Papelaria Conceição & Ação Ltda",
+		MerchantCity: "São João del-Rei",
+	}
+
+	payload := code.Payload()
+
+	// Lengths count bytes, so the text must be ASCII before truncation.
+	assert.Contains(t, payload, "5925Papelaria Conceicao & Aca")
+	assert.Contains(t, payload, "6015Sao Joao del-Re")
+}
+
+func TestGeneratePixQRCode(t *testing.T) {
+	qrCode, err := services.GeneratePixQRCode("00020101021126")
+	assert.NoError(t, err)
+	assert.NotEmpty(t, qrCode)
+}
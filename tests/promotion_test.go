@@ -0,0 +1,143 @@
+package tests
+
+import (
+	"smart-choice/models"
+	"smart-choice/services"
+	"smart-choice/utils"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func testPromotion(id uint, p models.Promotion) models.Promotion {
+	p.Model = gorm.Model{ID: id}
+	p.Active = true
+	return p
+}
+
+func TestBuyThreePayTwoFreesCheapestUnits(t *testing.T) {
+	promotions := []models.Promotion{testPromotion(1, models.Promotion{
+		Name: "Leve 3 pague 2", Type: models.PromotionBuyXPayY, BuyQuantity: 3, PayQuantity: 2,
+		Categories: []string{"camisetas"},
+	})}
+	lines := []services.PromotionLine{
+		{ProductID: 1, Category: "Camisetas", Quantity: 2, UnitPrice: 80},
+		{ProductID: 2, Category: "Camisetas", Quantity: 4, UnitPrice: 50},
+		{ProductID: 3, Category: "Calçados", Quantity: 1, UnitPrice: 300},
+	}
+
+	result := services.EvaluatePromotions(promotions, lines, time.Now())
+
+	// Six shirts make two groups: the two cheapest units are free.
+	assert.Equal(t, 100.0, result.Discount)
+	assert.Equal(t, 100.0, result.DiscountFor(2))
+	assert.Equal(t, 0.0, result.DiscountFor(1))
+	assert.Len(t, result.Applied, 1)
+}
+
+func TestCartPercentageAboveThreshold(t *testing.T) {
+	promotions := []models.Promotion{testPromotion(1, models.Promotion{
+		Name: "10% acima de R$300", Type: models.PromotionCartPercentage, Percentage: 10, MinAmount: 300,
+	})}
+
+	below := services.EvaluatePromotions(promotions, []services.PromotionLine{{ProductID: 1, Quantity: 1, UnitPrice: 299.99}}, time.Now())
+	assert.Equal(t, 0.0, below.Discount)
+	assert.Empty(t, below.Applied)
+
+	above := services.EvaluatePromotions(promotions, []services.PromotionLine{
+		{ProductID: 1, Quantity: 2, UnitPrice: 150},
+		{ProductID: 2, Quantity: 1, UnitPrice: 25.5},
+	}, time.Now())
+	assert.Equal(t, 32.55, above.Discount)
+	assert.Equal(t, 30.0, above.DiscountFor(1))
+	assert.Equal(t, 2.55, above.DiscountFor(2))
+}
+
+func TestFreeItemWithPurchase(t *testing.T) {
+	mug := uint(9)
+	promotions := []models.Promotion{testPromotion(1, models.Promotion{
+		Name: "Caneca grátis", Type: models.PromotionFreeItem, ProductIDs: []uint{1}, BuyQuantity: 2, FreeProductID: &mug,
+	})}
+	lines := []services.PromotionLine{
+		{ProductID: 1, Quantity: 5, UnitPrice: 30},
+		{ProductID: 9, Quantity: 3, UnitPrice: 25},
+	}
+
+	result := services.EvaluatePromotions(promotions, lines, time.Now())
+	assert.Equal(t, 50.0, result.DiscountFor(9))
+
+	withoutMug := services.EvaluatePromotions(promotions, lines[:1], time.Now())
+	assert.Equal(t, 0.0, withoutMug.Discount)
+}
+
+func TestPromotionPriorityStackingAndExclusivity(t *testing.T) {
+	lines := []services.PromotionLine{
+		{ProductID: 1, Quantity: 3, UnitPrice: 100},
+		{ProductID: 2, Quantity: 1, UnitPrice: 200},
+	}
+	buy3 := models.Promotion{Name: "3x2", Type: models.PromotionBuyXPayY, BuyQuantity: 3, PayQuantity: 2, ProductIDs: []uint{1}}
+	tenOff := models.Promotion{Name: "10%", Type: models.PromotionCartPercentage, Percentage: 10}
+
+	// Not stackable: the percentage skips the line the 3x2 already discounted.
+	result := services.EvaluatePromotions([]models.Promotion{
+		testPromotion(1, withStacking(tenOff, 1, false, false)),
+		testPromotion(2, withStacking(buy3, 5, false, false)),
+	}, lines, time.Now())
+	assert.Equal(t, []string{"3x2", "10%"}, appliedNames(result))
+	assert.Equal(t, 100.0, result.DiscountFor(1))
+	assert.Equal(t, 20.0, result.DiscountFor(2))
+
+	// Stackable: the percentage is taken on the whole line and capped by
+	// what is left of it.
+	result = services.EvaluatePromotions([]models.Promotion{
+		testPromotion(1, withStacking(tenOff, 1, false, true)),
+		testPromotion(2, withStacking(buy3, 5, false, false)),
+	}, lines, time.Now())
+	assert.Equal(t, 130.0, result.DiscountFor(1))
+
+	// An exclusive promotion applies alone.
+	result = services.EvaluatePromotions([]models.Promotion{
+		testPromotion(1, withStacking(tenOff, 1, false, true)),
+		testPromotion(2, withStacking(buy3, 5, true, false)),
+	}, lines, time.Now())
+	assert.Equal(t, []string{"3x2"}, appliedNames(result))
+	assert.Equal(t, 100.0, result.Discount)
+}
+
+func TestPromotionDateWindow(t *testing.T) {
+	now := time.Now()
+	later := now.Add(time.Hour)
+	p := testPromotion(1, models.Promotion{Name: "Futura", Type: models.PromotionCartPercentage, Percentage: 10, StartsAt: &later})
+
+	result := services.EvaluatePromotions([]models.Promotion{p}, []services.PromotionLine{{ProductID: 1, Quantity: 1, UnitPrice: 100}}, now)
+	assert.Equal(t, 0.0, result.Discount)
+
+	result = services.EvaluatePromotions([]models.Promotion{p}, []services.PromotionLine{{ProductID: 1, Quantity: 1, UnitPrice: 100}}, later)
+	assert.Equal(t, 10.0, result.Discount)
+}
+
+func TestValidatePromotion(t *testing.T) {
+	mug := uint(9)
+	assert.Empty(t, utils.ValidatePromotion("3x2", "buy_x_pay_y", 3, 2, 0, nil, nil, nil))
+	assert.Empty(t, utils.ValidatePromotion("Caneca", "free_item", 1, 0, 0, &mug, nil, nil))
+	assert.Len(t, utils.ValidatePromotion("3x3", "buy_x_pay_y", 3, 3, 0, nil, nil, nil), 1)
+	assert.Len(t, utils.ValidatePromotion("", "cart_percentage", 0, 0, 120, nil, nil, nil), 2)
+	assert.Len(t, utils.ValidatePromotion("Brinde", "gift", 0, 0, 0, nil, nil, nil), 1)
+}
+
+func withStacking(p models.Promotion, priority int, exclusive, stackable bool) models.Promotion {
+	p.Priority = priority
+	p.Exclusive = exclusive
+	p.Stackable = stackable
+	return p
+}
+
+func appliedNames(result services.PromotionResult) []string {
+	var names []string
+	for _, applied := range result.Applied {
+		names = append(names, applied.Name)
+	}
+	return names
+}
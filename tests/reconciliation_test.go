@@ -0,0 +1,91 @@
+package tests
+
+import (
+	"smart-choice/models"
+	"smart-choice/services"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestParseSettlementFile(t *testing.T) {
+	file := "referencia;valor;moeda\nabc;1.234,56;brl\n;10,00;BRL\nxyz;R$ 99,90;BRL\n"
+
+	lines, err := services.ParseSettlementFile(strings.NewReader(file))
+	assert.NoError(t, err)
+	assert.Len(t, lines, 2)
+	assert.Equal(t, services.SettlementLine{Line: 2, Reference: "abc", Amount: 1234.56, Currency: "BRL"}, lines[0])
+	assert.Equal(t, 4, lines[1].Line)
+	assert.Equal(t, 99.90, lines[1].Amount)
+
+	_, err = services.ParseSettlementFile(strings.NewReader("id,value\n1,2\n"))
+	assert.ErrorIs(t, err, services.ErrInvalidSettlementFile)
+
+	_, err = services.ParseSettlementFile(strings.NewReader("reference,amount\nabc,ten\n"))
+	assert.ErrorIs(t, err, services.ErrInvalidSettlementFile)
+}
+
+func TestParseSettlementFileAmounts(t *testing.T) {
+	tests := []struct {
+		value string
+		want  float64
+	}{
+		{"1.234,56", 1234.56},
+		{"1,234.56", 1234.56},
+		{"1234.56", 1234.56},
+		{"1234,56", 1234.56},
+		{"R$ 1.234.567,89", 1234567.89},
+		{"10", 10},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			lines, err := services.ParseSettlementFile(strings.NewReader("reference;amount\nabc;" + tt.value + "\n"))
+			assert.NoError(t, err)
+			if assert.Len(t, lines, 1) {
+				assert.InDelta(t, tt.want, lines[0].Amount, 0.001)
+			}
+		})
+	}
+}
+
+func TestMatchSettlementLines(t *testing.T) {
+	payment := func(id uint, reference string, amount float64, status string) models.Payment {
+		return models.Payment{Model: gorm.Model{ID: id}, OrderID: id * 10, ProviderReference: reference, Amount: amount, Currency: "BRL", Status: status}
+	}
+	payments := []models.Payment{
+		payment(1, "ok", 100, models.PaymentStatusSucceeded),
+		payment(2, "short", 100, models.PaymentStatusSucceeded),
+		payment(3, "pending", 50, models.PaymentStatusPending),
+		payment(4, "twice", 20, models.PaymentStatusSucceeded),
+		payment(5, "absent", 70, models.PaymentStatusSucceeded),
+	}
+	lines := []services.SettlementLine{
+		{Line: 2, Reference: "ok", Amount: 100},
+		{Line: 3, Reference: "short", Amount: 90},
+		{Line: 4, Reference: "pending", Amount: 50},
+		{Line: 5, Reference: "twice", Amount: 20},
+		{Line: 6, Reference: "twice", Amount: 20},
+		{Line: 7, Reference: "unknown", Amount: 5},
+	}
+
+	items := services.MatchSettlementLines(lines, payments)
+
+	results := make([]string, len(items))
+	for i, item := range items {
+		results[i] = item.Reference + ":" + item.Result
+	}
+	assert.Equal(t, []string{
+		"ok:" + models.ReconciliationMatched,
+		"short:" + models.ReconciliationAmountMismatch,
+		"pending:" + models.ReconciliationStatusMismatch,
+		"twice:" + models.ReconciliationDuplicate,
+		"twice:" + models.ReconciliationDuplicate,
+		"unknown:" + models.ReconciliationNotFound,
+		"absent:" + models.ReconciliationMissingFromFile,
+	}, results)
+	assert.Equal(t, uint(20), *items[1].OrderID)
+	assert.Nil(t, items[5].PaymentID)
+}
@@ -0,0 +1,51 @@
+package tests
+
+import (
+	"smart-choice/models"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNetUnitPriceWithoutOrderDiscounts(t *testing.T) {
+	order := models.Order{OrderItems: []models.OrderItem{
+		{Quantity: 3, Price: 50, Discount: 30},
+	}}
+
+	// Only the promotion comes off: 150 - 30 over three units.
+	assert.Equal(t, 40.0, order.NetUnitPrice(&order.OrderItems[0]))
+}
+
+func TestNetUnitPriceSpreadsCouponAndPointsByValue(t *testing.T) {
+	order := models.Order{
+		Subtotal:          400,
+		PromotionDiscount: 100,
+		Discount:          30,
+		LoyaltyDiscount:   15,
+		Total:             255,
+		OrderItems: []models.OrderItem{
+			{Quantity: 2, Price: 100},
+			{Quantity: 4, Price: 50, Discount: 100},
+		},
+	}
+
+	// 45 off 300 of goods is 15% off each line.
+	assert.InDelta(t, 85.0, order.NetUnitPrice(&order.OrderItems[0]), 0.001)
+	assert.InDelta(t, 21.25, order.NetUnitPrice(&order.OrderItems[1]), 0.001)
+
+	var refundable float64
+	for i := range order.OrderItems {
+		refundable += order.NetUnitPrice(&order.OrderItems[i]) * float64(order.OrderItems[i].Quantity)
+	}
+	assert.InDelta(t, order.Total, refundable, 0.001)
+}
+
+func TestNetUnitPriceNeverGoesNegative(t *testing.T) {
+	order := models.Order{
+		Discount:        80,
+		LoyaltyDiscount: 40,
+		OrderItems:      []models.OrderItem{{Quantity: 1, Price: 100}},
+	}
+
+	assert.Equal(t, 0.0, order.NetUnitPrice(&order.OrderItems[0]))
+}
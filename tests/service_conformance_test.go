@@ -0,0 +1,430 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"smart-choice/services"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// The Redis and in-memory services must behave the same. Each conformance
+// test runs against both; the Redis runs skip when Redis is not available.
+
+const testRedisAddr = "localhost:6379"
+
+// conformantJobQueue is what both job queue implementations provide.
+type conformantJobQueue interface {
+	services.JobQueue
+	services.JobReaper
+	services.JobPromoter
+	services.JobReleaser
+	services.JobInspector
+	services.MultiJobQueue
+}
+
+// newTestRedisJobQueue connects to a scratch Redis database, skipping the
+// test when Redis is not available.
+func newTestRedisJobQueue(t *testing.T, config services.JobQueueConfig) *services.RedisJobQueue {
+	t.Helper()
+	t.Setenv("JOB_QUEUE_DB", "15")
+
+	queue, err := services.NewRedisJobQueueWithConfig(testRedisAddr, "", config)
+	if err != nil {
+		t.Skip("Skipping Redis job queue test - Redis not available")
+	}
+
+	client := redis.NewClient(&redis.Options{Addr: testRedisAddr, DB: 15})
+	require.NoError(t, client.FlushDB(context.Background()).Err())
+	t.Cleanup(func() {
+		client.FlushDB(context.Background())
+		client.Close()
+	})
+	return queue
+}
+
+func forEachJobQueue(t *testing.T, config services.JobQueueConfig, test func(t *testing.T, queue conformantJobQueue)) {
+	t.Run("memory", func(t *testing.T) {
+		test(t, services.NewMemoryJobQueueWithConfig(config))
+	})
+	t.Run("redis", func(t *testing.T) {
+		test(t, newTestRedisJobQueue(t, config))
+	})
+}
+
+// forEachCache runs a test against both caches. The Redis cache shares its
+// database with the application, so every key lives under the given
+// prefix and is cleared afterwards.
+func forEachCache(t *testing.T, test func(t *testing.T, cache services.CacheService, prefix string)) {
+	prefix := fmt.Sprintf("conformance:%s:", uuid.New().String())
+
+	t.Run("memory", func(t *testing.T) {
+		test(t, services.NewMemoryCache(), prefix)
+	})
+	t.Run("redis", func(t *testing.T) {
+		cache, err := services.NewRedisCache(testRedisAddr, "")
+		if err != nil {
+			t.Skip("Skipping Redis cache test - Redis not available")
+		}
+		t.Cleanup(func() { cache.Clear(context.Background(), prefix+"*") })
+		test(t, cache, prefix)
+	})
+}
+
+func forEachRateLimit(t *testing.T, test func(t *testing.T, limiter services.RateLimitService)) {
+	t.Run("memory", func(t *testing.T) {
+		test(t, services.NewMemoryRateLimit())
+	})
+	t.Run("redis", func(t *testing.T) {
+		limiter, err := services.NewRedisRateLimit(testRedisAddr, "")
+		if err != nil {
+			t.Skip("Skipping Redis rate limit test - Redis not available")
+		}
+		test(t, limiter)
+	})
+}
+
+func TestJobQueueRetriesThenDeadLetters(t *testing.T) {
+	forEachJobQueue(t, services.JobQueueConfig{MaxAttempts: 2, VisibilityTimeout: time.Minute}, func(t *testing.T, queue conformantJobQueue) {
+		ctx := context.Background()
+		require.NoError(t, queue.Enqueue(ctx, services.Job{Type: services.JobTypeEmailSend, Payload: []byte(`{}`)}))
+
+		job, err := queue.Dequeue(ctx)
+		require.NoError(t, err)
+		require.NotNil(t, job)
+		assert.Equal(t, 1, job.Attempts)
+
+		require.NoError(t, queue.Fail(ctx, job.ID, "smtp down"))
+		stats, err := queue.GetStats(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 1, stats["retry_length"])
+		assert.Equal(t, 0, stats["processing_length"])
+
+		// No backoff configured: the retry is due right away.
+		require.NoError(t, queue.PromoteDue(ctx))
+		job, err = queue.Dequeue(ctx)
+		require.NoError(t, err)
+		require.NotNil(t, job)
+		assert.Equal(t, 2, job.Attempts)
+		assert.Equal(t, "smtp down", job.LastError)
+
+		require.NoError(t, queue.Fail(ctx, job.ID, "smtp still down"))
+		stats, err = queue.GetStats(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 1, stats["dead_letter_count"])
+		assert.Equal(t, 0, stats["retry_length"])
+	})
+}
+
+func TestJobQueueReleaseGivesBackTheAttempt(t *testing.T) {
+	forEachJobQueue(t, services.JobQueueConfig{MaxAttempts: 1, VisibilityTimeout: time.Minute}, func(t *testing.T, queue conformantJobQueue) {
+		ctx := context.Background()
+		require.NoError(t, queue.Enqueue(ctx, services.Job{Type: services.JobTypeEmailSend, Payload: []byte(`{}`)}))
+
+		job, err := queue.Dequeue(ctx)
+		require.NoError(t, err)
+		require.NotNil(t, job)
+		require.NoError(t, queue.Release(ctx, job.ID))
+
+		stats, err := queue.GetStats(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 1, stats["queue_length"])
+		assert.Equal(t, 0, stats["processing_length"])
+
+		// The only attempt is still available.
+		released, err := queue.Dequeue(ctx)
+		require.NoError(t, err)
+		require.NotNil(t, released)
+		assert.Equal(t, job.ID, released.ID)
+		assert.Equal(t, 1, released.Attempts)
+	})
+}
+
+func TestJobQueueCompleteRemovesJob(t *testing.T) {
+	forEachJobQueue(t, services.JobQueueConfig{MaxAttempts: 3, VisibilityTimeout: time.Minute}, func(t *testing.T, queue conformantJobQueue) {
+		ctx := context.Background()
+		require.NoError(t, queue.Enqueue(ctx, services.Job{Type: services.JobTypeDataExport}))
+
+		job, err := queue.Dequeue(ctx)
+		require.NoError(t, err)
+		require.NotNil(t, job)
+		require.NoError(t, queue.Complete(ctx, job.ID))
+
+		// Reporting a finished job again is harmless.
+		require.NoError(t, queue.Fail(ctx, job.ID, "late failure"))
+
+		stats, err := queue.GetStats(ctx)
+		require.NoError(t, err)
+		for _, key := range []string{"queue_length", "processing_length", "retry_length", "scheduled_length", "dead_letter_count"} {
+			assert.Equal(t, 0, stats[key], key)
+		}
+	})
+}
+
+func TestJobQueueRequeuesExpiredLease(t *testing.T) {
+	forEachJobQueue(t, services.JobQueueConfig{MaxAttempts: 3, VisibilityTimeout: -time.Second}, func(t *testing.T, queue conformantJobQueue) {
+		ctx := context.Background()
+		require.NoError(t, queue.Enqueue(ctx, services.Job{Type: services.JobTypeDataCleanup}))
+		job, err := queue.Dequeue(ctx)
+		require.NoError(t, err)
+		require.NotNil(t, job)
+
+		// The worker never reports back; its lease is already over.
+		require.NoError(t, queue.Reap(ctx))
+
+		stats, err := queue.GetStats(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 1, stats["queue_length"])
+		assert.Equal(t, 0, stats["processing_length"])
+
+		again, err := queue.Dequeue(ctx)
+		require.NoError(t, err)
+		require.NotNil(t, again)
+		assert.Equal(t, job.ID, again.ID)
+		assert.Equal(t, 2, again.Attempts)
+	})
+}
+
+func TestJobQueueHoldsDelayedJobsUntilDue(t *testing.T) {
+	forEachJobQueue(t, services.JobQueueConfig{MaxAttempts: 1, VisibilityTimeout: time.Minute}, func(t *testing.T, queue conformantJobQueue) {
+		ctx := context.Background()
+		require.NoError(t, queue.Enqueue(ctx, services.Job{Type: services.JobTypeEmailSend, ScheduledAt: time.Now().Add(time.Hour)}))
+		require.NoError(t, queue.Enqueue(ctx, services.Job{Type: services.JobTypeEmailSend, ScheduledAt: time.Now().Add(-time.Second)}))
+
+		require.NoError(t, queue.PromoteDue(ctx))
+		stats, err := queue.GetStats(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 1, stats["scheduled_length"])
+		assert.Equal(t, 1, stats["queue_length"])
+	})
+}
+
+func TestJobQueueRetriesDeadJobs(t *testing.T) {
+	forEachJobQueue(t, services.JobQueueConfig{MaxAttempts: 1, VisibilityTimeout: time.Minute}, func(t *testing.T, queue conformantJobQueue) {
+		ctx := context.Background()
+		require.NoError(t, queue.Enqueue(ctx, services.Job{Type: services.JobTypeEmailSend, Payload: []byte(`{"to":"a@b.com"}`)}))
+		job, err := queue.Dequeue(ctx)
+		require.NoError(t, err)
+		require.NotNil(t, job)
+		require.NoError(t, queue.Fail(ctx, job.ID, "smtp down"))
+
+		dead, total, err := queue.ListJobs(ctx, services.JobStateDead, 0, 10)
+		require.NoError(t, err)
+		assert.EqualValues(t, 1, total)
+		require.Len(t, dead, 1)
+		assert.Equal(t, "smtp down", dead[0].LastError)
+		assert.Equal(t, `{"to":"a@b.com"}`, dead[0].Payload)
+
+		require.NoError(t, queue.RetryDeadJob(ctx, job.ID))
+		assert.ErrorIs(t, queue.RetryDeadJob(ctx, job.ID), services.ErrJobNotFound)
+
+		queued, total, err := queue.ListJobs(ctx, services.JobStateQueued, 0, 10)
+		require.NoError(t, err)
+		assert.EqualValues(t, 1, total)
+		require.Len(t, queued, 1)
+		assert.Equal(t, job.ID, queued[0].ID)
+		assert.Equal(t, 0, queued[0].Attempts)
+	})
+}
+
+func TestJobQueueDiscardsDeadJobsByType(t *testing.T) {
+	forEachJobQueue(t, services.JobQueueConfig{MaxAttempts: 1, VisibilityTimeout: time.Minute}, func(t *testing.T, queue conformantJobQueue) {
+		ctx := context.Background()
+		for _, jobType := range []services.JobType{services.JobTypeEmailSend, services.JobTypeEmailSend, services.JobTypeDataExport} {
+			require.NoError(t, queue.Enqueue(ctx, services.Job{Type: jobType}))
+			job, err := queue.Dequeue(ctx)
+			require.NoError(t, err)
+			require.NotNil(t, job)
+			require.NoError(t, queue.Fail(ctx, job.ID, "broken"))
+		}
+
+		discarded, err := queue.DiscardDeadJobs(ctx, services.JobTypeEmailSend)
+		require.NoError(t, err)
+		assert.Equal(t, 2, discarded)
+
+		dead, total, err := queue.ListJobs(ctx, services.JobStateDead, 0, 10)
+		require.NoError(t, err)
+		assert.EqualValues(t, 1, total)
+		require.Len(t, dead, 1)
+		assert.Equal(t, services.JobTypeDataExport, dead[0].Type)
+		assert.ErrorIs(t, queue.DiscardDeadJob(ctx, "missing"), services.ErrJobNotFound)
+
+		retried, err := queue.RetryDeadJobs(ctx, "")
+		require.NoError(t, err)
+		assert.Equal(t, 1, retried)
+	})
+}
+
+func TestJobQueueSetsPausedTypesAside(t *testing.T) {
+	config := services.JobQueueConfig{MaxAttempts: 3, VisibilityTimeout: time.Minute, PauseRecheck: time.Minute}
+	forEachJobQueue(t, config, func(t *testing.T, queue conformantJobQueue) {
+		ctx := context.Background()
+		require.NoError(t, queue.PauseJobType(ctx, services.JobTypeReportGenerate))
+		require.NoError(t, queue.Enqueue(ctx, services.Job{Type: services.JobTypeReportGenerate}))
+
+		job, err := queue.Dequeue(ctx)
+		require.NoError(t, err)
+		assert.Nil(t, job)
+
+		scheduled, _, err := queue.ListJobs(ctx, services.JobStateScheduled, 0, 10)
+		require.NoError(t, err)
+		require.Len(t, scheduled, 1)
+		assert.Equal(t, 0, scheduled[0].Attempts)
+
+		paused, err := queue.PausedJobTypes(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, []services.JobType{services.JobTypeReportGenerate}, paused)
+
+		require.NoError(t, queue.ResumeJobType(ctx, services.JobTypeReportGenerate))
+		paused, err = queue.PausedJobTypes(ctx)
+		require.NoError(t, err)
+		assert.Empty(t, paused)
+	})
+}
+
+func TestJobQueueRoutesJobsToNamedQueues(t *testing.T) {
+	routing, err := services.NewJobRouting("critical:5,default:1", "email_send:critical")
+	require.NoError(t, err)
+
+	config := services.JobQueueConfig{MaxAttempts: 2, VisibilityTimeout: time.Minute, Routing: routing}
+	forEachJobQueue(t, config, func(t *testing.T, queue conformantJobQueue) {
+		ctx := context.Background()
+		require.NoError(t, queue.Enqueue(ctx, services.Job{Type: services.JobTypeDataExport}))
+		require.NoError(t, queue.Enqueue(ctx, services.Job{Type: services.JobTypeEmailSend}))
+
+		stats, err := queue.GetStats(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 1, stats["queue_length:critical"])
+		assert.Equal(t, 1, stats["queue_length:default"])
+		assert.Equal(t, 2, stats["queue_length"])
+
+		queued, _, err := queue.ListJobs(ctx, services.JobStateQueued, 0, 10)
+		require.NoError(t, err)
+		require.Len(t, queued, 2)
+		assert.Equal(t, "critical", queued[0].Queue)
+		assert.Equal(t, "default", queued[1].Queue)
+
+		job, err := queue.DequeueFrom(ctx, "default")
+		require.NoError(t, err)
+		require.NotNil(t, job)
+		assert.Equal(t, services.JobTypeDataExport, job.Type)
+
+		job, err = queue.DequeueFrom(ctx, "default")
+		require.NoError(t, err)
+		assert.Nil(t, job)
+
+		// Dequeue serves the heaviest queue first.
+		job, err = queue.Dequeue(ctx)
+		require.NoError(t, err)
+		require.NotNil(t, job)
+		assert.Equal(t, "critical", job.Queue)
+	})
+}
+
+func TestCacheStoresJSONValues(t *testing.T) {
+	forEachCache(t, func(t *testing.T, cache services.CacheService, prefix string) {
+		ctx := context.Background()
+		key := prefix + "product"
+
+		require.NoError(t, cache.Set(ctx, key, map[string]interface{}{"name": "Mouse", "price": 59.9}, time.Minute))
+		value, ok := cache.Get(ctx, key)
+		require.True(t, ok)
+		assert.Equal(t, map[string]interface{}{"name": "Mouse", "price": 59.9}, value)
+		assert.True(t, cache.Exists(ctx, key))
+
+		require.NoError(t, cache.Delete(ctx, key))
+		_, ok = cache.Get(ctx, key)
+		assert.False(t, ok)
+		assert.False(t, cache.Exists(ctx, key))
+	})
+}
+
+func TestCacheExpiresEntries(t *testing.T) {
+	forEachCache(t, func(t *testing.T, cache services.CacheService, prefix string) {
+		ctx := context.Background()
+		key := prefix + "short"
+
+		require.NoError(t, cache.Set(ctx, key, "soon gone", 50*time.Millisecond))
+		assert.True(t, cache.Exists(ctx, key))
+
+		time.Sleep(100 * time.Millisecond)
+		_, ok := cache.Get(ctx, key)
+		assert.False(t, ok)
+	})
+}
+
+func TestCacheSetNXOnlySetsMissingKeys(t *testing.T) {
+	forEachCache(t, func(t *testing.T, cache services.CacheService, prefix string) {
+		ctx := context.Background()
+		key := prefix + "lock"
+
+		acquired, err := cache.SetNX(ctx, key, "first", time.Minute)
+		require.NoError(t, err)
+		assert.True(t, acquired)
+
+		acquired, err = cache.SetNX(ctx, key, "second", time.Minute)
+		require.NoError(t, err)
+		assert.False(t, acquired)
+
+		value, _ := cache.Get(ctx, key)
+		assert.Equal(t, "first", value)
+	})
+}
+
+func TestCacheClearsMatchingKeys(t *testing.T) {
+	forEachCache(t, func(t *testing.T, cache services.CacheService, prefix string) {
+		ctx := context.Background()
+		for _, key := range []string{"products:1", "products:2", "users:1"} {
+			require.NoError(t, cache.Set(ctx, prefix+key, key, time.Minute))
+		}
+
+		require.NoError(t, cache.Clear(ctx, prefix+"products:*"))
+		assert.False(t, cache.Exists(ctx, prefix+"products:1"))
+		assert.False(t, cache.Exists(ctx, prefix+"products:2"))
+		assert.True(t, cache.Exists(ctx, prefix+"users:1"))
+	})
+}
+
+func TestRateLimitCapsRequestsPerIP(t *testing.T) {
+	forEachRateLimit(t, func(t *testing.T, limiter services.RateLimitService) {
+		ctx := context.Background()
+		userID := uint(uuid.New().ID())
+		ip := "test-" + uuid.New().String()
+		t.Cleanup(func() { limiter.ResetUserLimits(ctx, userID) })
+
+		// 20 requests per minute per IP.
+		for i := 0; i < 20; i++ {
+			require.True(t, limiter.Allow(ctx, userID, ip), "request %d", i+1)
+		}
+		assert.False(t, limiter.Allow(ctx, userID, ip))
+		assert.True(t, limiter.Allow(ctx, userID, ip+"-other"))
+	})
+}
+
+func TestRateLimitTracksRemainingUserRequests(t *testing.T) {
+	forEachRateLimit(t, func(t *testing.T, limiter services.RateLimitService) {
+		ctx := context.Background()
+		userID := uint(uuid.New().ID())
+		t.Cleanup(func() { limiter.ResetUserLimits(ctx, userID) })
+
+		remaining, reset := limiter.GetRemaining(ctx, userID, "")
+		assert.Equal(t, 100, remaining)
+		assert.Equal(t, time.Hour, reset)
+
+		for i := 0; i < 3; i++ {
+			require.True(t, limiter.Allow(ctx, userID, fmt.Sprintf("test-%s-%d", uuid.New().String(), i)))
+		}
+		remaining, reset = limiter.GetRemaining(ctx, userID, "")
+		assert.Equal(t, 97, remaining)
+		assert.True(t, reset > 59*time.Minute && reset <= time.Hour, reset)
+
+		require.NoError(t, limiter.ResetUserLimits(ctx, userID))
+		remaining, _ = limiter.GetRemaining(ctx, userID, "")
+		assert.Equal(t, 100, remaining)
+	})
+}
@@ -0,0 +1,53 @@
+package tests
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"smart-choice/services"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReceiveWebhookEventRejectsStaleTimestamp(t *testing.T) {
+	_, _, err := services.ReceiveWebhookEvent(services.WebhookEnvelope{
+		Provider:  "pix",
+		Timestamp: time.Now().Add(-time.Hour),
+		Payload:   `{"txid":"abc"}`,
+	})
+	assert.ErrorIs(t, err, services.ErrWebhookTimestampOutOfRange)
+
+	_, _, err = services.ReceiveWebhookEvent(services.WebhookEnvelope{
+		Provider:  "pix",
+		Timestamp: time.Now().Add(time.Hour),
+		Payload:   `{"txid":"abc"}`,
+	})
+	assert.ErrorIs(t, err, services.ErrWebhookTimestampOutOfRange)
+}
+
+func TestReceiveWebhookEventRequiresTimestamp(t *testing.T) {
+	_, _, err := services.ReceiveWebhookEvent(services.WebhookEnvelope{
+		Provider: "boleto",
+		Payload:  `{"nosso_numero":"1"}`,
+	})
+	assert.ErrorIs(t, err, services.ErrWebhookTimestampOutOfRange)
+}
+
+func TestWebhookEventIDComesFromSignedPayload(t *testing.T) {
+	assert.Equal(t, "evt_1", services.WebhookEventID(`{"event_id":"evt_1","txid":"abc"}`))
+
+	// Without an event ID in the body, identical bodies are the same event
+	body := `{"txid":"abc","status":"paid"}`
+	sum := sha256.Sum256([]byte(body))
+	assert.Equal(t, hex.EncodeToString(sum[:]), services.WebhookEventID(body))
+	assert.Equal(t, services.WebhookEventID(body), services.WebhookEventID(body))
+	assert.NotEqual(t, services.WebhookEventID(body), services.WebhookEventID(`{"txid":"abd","status":"paid"}`))
+}
+
+func TestPaymentEventRejectsStatusesOutsideWhitelist(t *testing.T) {
+	for _, status := range []string{"shipped", "delivered", "refunded", "payment_review", ""} {
+		_, err := services.HandlePaymentEvent(nil, `{"order_id":1,"status":"`+status+`"}`)
+		assert.ErrorIs(t, err, services.ErrUnsupportedPaymentStatus, status)
+	}
+}
@@ -0,0 +1,68 @@
+package tests
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"smart-choice/services"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHMACVerifierAcceptsRotatedSecrets(t *testing.T) {
+	body := []byte(`{"txid":"abc"}`)
+	verifier := services.HMACVerifier{Header: "X-Webhook-Signature", Secrets: []string{"new-secret", "old-secret"}}
+
+	// Signed with the old secret, still accepted during rotation
+	header := http.Header{}
+	header.Set("X-Webhook-Signature", hmacHex("old-secret", body))
+	_, err := verifier.Verify(body, header)
+	assert.NoError(t, err)
+
+	header.Set("X-Webhook-Signature", hmacHex("retired-secret", body))
+	_, err = verifier.Verify(body, header)
+	assert.ErrorIs(t, err, services.ErrInvalidSignature)
+
+	_, err = verifier.Verify(body, http.Header{})
+	assert.ErrorIs(t, err, services.ErrMissingSignature)
+}
+
+func hmacHex(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestTimestampedVerifier(t *testing.T) {
+	body := []byte(`{"order_id":1,"status":"paid"}`)
+	sentAt := time.Unix(1760000000, 0)
+	verifier := services.TimestampedVerifier{Header: "X-Webhook-Signature", Secrets: []string{"current", "previous"}}
+
+	header := http.Header{}
+	header.Set("X-Webhook-Signature", services.SignWebhookPayload("previous", sentAt, body))
+	timestamp, err := verifier.Verify(body, header)
+	assert.NoError(t, err)
+	assert.Equal(t, sentAt, timestamp)
+
+	// Altering the timestamp invalidates the signature
+	tampered := services.SignWebhookPayload("previous", sentAt, body)
+	header.Set("X-Webhook-Signature", "t=1760009999"+tampered[len("t=1760000000"):])
+	_, err = verifier.Verify(body, header)
+	assert.ErrorIs(t, err, services.ErrInvalidSignature)
+
+	header.Set("X-Webhook-Signature", services.SignWebhookPayload("unknown", sentAt, body))
+	_, err = verifier.Verify(body, header)
+	assert.ErrorIs(t, err, services.ErrInvalidSignature)
+
+	header.Set("X-Webhook-Signature", "garbage")
+	_, err = verifier.Verify(body, header)
+	assert.ErrorIs(t, err, services.ErrMalformedSignatureHeader)
+}
+
+func TestVerifierWithoutSecretsIsNotConfigured(t *testing.T) {
+	_, err := services.HMACVerifier{Header: "X-Webhook-Signature"}.Verify([]byte("{}"), http.Header{})
+	assert.ErrorIs(t, err, services.ErrWebhookNotConfigured)
+}
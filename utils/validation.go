@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"regexp"
 	"strings"
+	"time"
 	"unicode"
 
 	"github.com/gin-gonic/gin"
@@ -15,6 +16,68 @@ func ValidateEmail(email string) bool {
 	return emailRegex.MatchString(email)
 }
 
+// ValidateCPF validates a CPF, masked or unmasked, including its check digits
+func ValidateCPF(cpf string) bool {
+	digits := OnlyDigits(cpf)
+	if len(digits) != 11 || allSameDigit(digits) {
+		return false
+	}
+
+	first := documentCheckDigit(digits[:9], []int{10, 9, 8, 7, 6, 5, 4, 3, 2})
+	second := documentCheckDigit(digits[:10], []int{11, 10, 9, 8, 7, 6, 5, 4, 3, 2})
+
+	return int(digits[9]-'0') == first && int(digits[10]-'0') == second
+}
+
+// ValidateCNPJ validates a CNPJ, masked or unmasked, including its check digits
+func ValidateCNPJ(cnpj string) bool {
+	digits := OnlyDigits(cnpj)
+	if len(digits) != 14 || allSameDigit(digits) {
+		return false
+	}
+
+	first := documentCheckDigit(digits[:12], []int{5, 4, 3, 2, 9, 8, 7, 6, 5, 4, 3, 2})
+	second := documentCheckDigit(digits[:13], []int{6, 5, 4, 3, 2, 9, 8, 7, 6, 5, 4, 3, 2})
+
+	return int(digits[12]-'0') == first && int(digits[13]-'0') == second
+}
+
+// MaskCPF hides all but the middle digits of a CPF ("***.456.789-**")
+func MaskCPF(cpf string) string {
+	digits := OnlyDigits(cpf)
+	if len(digits) != 11 {
+		return "***"
+	}
+	return fmt.Sprintf("***.%s.%s-**", digits[3:6], digits[6:9])
+}
+
+// MaskCNPJ hides all but the branch digits of a CNPJ ("**.***.***/0001-**")
+func MaskCNPJ(cnpj string) string {
+	digits := OnlyDigits(cnpj)
+	if len(digits) != 14 {
+		return "***"
+	}
+	return fmt.Sprintf("**.***.***/%s-**", digits[8:12])
+}
+
+// documentCheckDigit computes a modulo 11 check digit as used by CPF and CNPJ
+func documentCheckDigit(digits string, weights []int) int {
+	sum := 0
+	for i, char := range digits {
+		sum += int(char-'0') * weights[i]
+	}
+
+	remainder := sum % 11
+	if remainder < 2 {
+		return 0
+	}
+	return 11 - remainder
+}
+
+func allSameDigit(digits string) bool {
+	return strings.Count(digits, digits[:1]) == len(digits)
+}
+
 // ValidatePassword validates password strength
 func ValidatePassword(password string) []string {
 	var errors []string
@@ -117,6 +180,187 @@ func ValidateStock(stock uint) []string {
 	return errors
 }
 
+var couponCodeRegex = regexp.MustCompile(`^[A-Z0-9_-]{3,32}$`)
+
+var couponDiscountTypes = map[string]bool{"percentage": true, "fixed": true, "free_shipping": true}
+
+// ValidateCoupon validates the fields of a coupon. Discount is a percentage
+// for "percentage" coupons, an amount for "fixed" ones and is ignored for
+// "free_shipping".
+func ValidateCoupon(code, discountType string, discount float64, validUntil time.Time, maxUses uint, minAmount float64) []string {
+	var errors []string
+
+	if !couponCodeRegex.MatchString(code) {
+		errors = append(errors, "Code must have 3 to 32 letters, digits, '-' or '_'")
+	}
+
+	switch {
+	case !couponDiscountTypes[discountType]:
+		errors = append(errors, "Discount type must be percentage, fixed or free_shipping")
+	case discountType == "percentage" && (discount <= 0 || discount > 100):
+		errors = append(errors, "Discount must be a percentage between 0 and 100")
+	case discountType == "fixed" && discount <= 0:
+		errors = append(errors, "Discount must be a positive amount")
+	}
+
+	if !validUntil.After(time.Now()) {
+		errors = append(errors, "Valid until must be in the future")
+	}
+
+	if maxUses == 0 {
+		errors = append(errors, "Max uses must be at least 1")
+	}
+
+	if minAmount < 0 {
+		errors = append(errors, "Min amount cannot be negative")
+	}
+
+	return errors
+}
+
+// ValidateCouponLimits validates the optional limits of a coupon.
+func ValidateCouponLimits(maxDiscount float64, startsAt *time.Time, validUntil time.Time) []string {
+	var errors []string
+
+	if maxDiscount < 0 {
+		errors = append(errors, "Max discount cannot be negative")
+	}
+
+	if startsAt != nil && !startsAt.Before(validUntil) {
+		errors = append(errors, "Starts at must be before valid until")
+	}
+
+	return errors
+}
+
+// ValidatePromotion validates the rule of an automatic promotion.
+func ValidatePromotion(name, promotionType string, buyQuantity, payQuantity uint, percentage float64, freeProductID *uint, startsAt, endsAt *time.Time) []string {
+	var errors []string
+
+	if strings.TrimSpace(name) == "" {
+		errors = append(errors, "Promotion name cannot be empty")
+	}
+
+	switch promotionType {
+	case "buy_x_pay_y":
+		if buyQuantity < 2 || payQuantity == 0 || payQuantity >= buyQuantity {
+			errors = append(errors, "Buy quantity must be greater than pay quantity, and pay quantity at least 1")
+		}
+	case "cart_percentage":
+		if percentage <= 0 || percentage > 100 {
+			errors = append(errors, "Percentage must be between 0 and 100")
+		}
+	case "free_item":
+		if freeProductID == nil {
+			errors = append(errors, "Free item promotions need a free product")
+		}
+	default:
+		errors = append(errors, "Promotion type must be buy_x_pay_y, cart_percentage or free_item")
+	}
+
+	if startsAt != nil && endsAt != nil && !startsAt.Before(*endsAt) {
+		errors = append(errors, "Starts at must be before ends at")
+	}
+
+	return errors
+}
+
+// ValidateLoyaltyRule validates a loyalty earn rule.
+func ValidateLoyaltyRule(name, ruleType, category string, bonusPerReal, multiplier float64, startsAt, endsAt *time.Time) []string {
+	var errors []string
+
+	if strings.TrimSpace(name) == "" {
+		errors = append(errors, "Rule name cannot be empty")
+	}
+
+	switch ruleType {
+	case "category_bonus":
+		if strings.TrimSpace(category) == "" {
+			errors = append(errors, "Category bonus rules need a category")
+		}
+		if bonusPerReal <= 0 {
+			errors = append(errors, "Bonus per real must be greater than zero")
+		}
+	case "campaign_multiplier":
+		if multiplier <= 1 || multiplier > 10 {
+			errors = append(errors, "Multiplier must be greater than 1 and at most 10")
+		}
+	default:
+		errors = append(errors, "Rule type must be category_bonus or campaign_multiplier")
+	}
+
+	if startsAt != nil && endsAt != nil && !startsAt.Before(*endsAt) {
+		errors = append(errors, "Starts at must be before ends at")
+	}
+
+	return errors
+}
+
+var brazilianUFs = map[string]bool{
+	"AC": true, "AL": true, "AP": true, "AM": true, "BA": true, "CE": true, "DF": true,
+	"ES": true, "GO": true, "MA": true, "MT": true, "MS": true, "MG": true, "PA": true,
+	"PB": true, "PR": true, "PE": true, "PI": true, "RJ": true, "RN": true, "RS": true,
+	"RO": true, "RR": true, "SC": true, "SP": true, "SE": true, "TO": true,
+}
+
+// OnlyDigits strips every non-digit character from s
+func OnlyDigits(s string) string {
+	var b strings.Builder
+	for _, char := range s {
+		if char >= '0' && char <= '9' {
+			b.WriteRune(char)
+		}
+	}
+	return b.String()
+}
+
+// NormalizeCEP removes the mask from a CEP ("01310-100" -> "01310100")
+func NormalizeCEP(cep string) string {
+	return OnlyDigits(cep)
+}
+
+// ValidateCEP validates CEP format, masked or unmasked
+func ValidateCEP(cep string) bool {
+	cepRegex := regexp.MustCompile(`^\d{5}-?\d{3}$`)
+	return cepRegex.MatchString(strings.TrimSpace(cep)) && NormalizeCEP(cep) != "00000000"
+}
+
+// ValidateUF validates a Brazilian state abbreviation
+func ValidateUF(uf string) bool {
+	return brazilianUFs[strings.ToUpper(strings.TrimSpace(uf))]
+}
+
+// ValidateAddress validates the fields of a Brazilian address
+func ValidateAddress(cep, logradouro, numero, bairro, cidade, uf string) []string {
+	var errors []string
+
+	if !ValidateCEP(cep) {
+		errors = append(errors, "CEP must have 8 digits (e.g. 01310-100)")
+	}
+
+	if !ValidateUF(uf) {
+		errors = append(errors, "UF must be a valid Brazilian state abbreviation (e.g. SP)")
+	}
+
+	if strings.TrimSpace(logradouro) == "" {
+		errors = append(errors, "Logradouro cannot be empty")
+	}
+
+	if strings.TrimSpace(numero) == "" {
+		errors = append(errors, "Número cannot be empty")
+	}
+
+	if strings.TrimSpace(bairro) == "" {
+		errors = append(errors, "Bairro cannot be empty")
+	}
+
+	if strings.TrimSpace(cidade) == "" {
+		errors = append(errors, "Cidade cannot be empty")
+	}
+
+	return errors
+}
+
 // HandleValidationError formats validation errors for API responses
 func HandleValidationError(c *gin.Context, errors []string) {
 	if len(errors) > 0 {